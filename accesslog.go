@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogFields are the fields an access log line can have, besides the
+// time, level and message slog adds.
+var AccessLogFields = []string{"method", "route", "path", "format", "status", "bytes", "duration", "ip", "user_agent", "referer"}
+
+type AccessLogOptions struct {
+	// Fields to log, all of AccessLogFields when empty.
+	Fields []string
+	// Sample logs one in this many requests, server errors are always
+	// logged. Zero and one log every request.
+	Sample int
+}
+
+// AccessLog writes a JSON line per request with log/slog.
+type AccessLog struct {
+	logger *slog.Logger
+	fields []string
+	sample uint64
+	count  atomic.Uint64
+}
+
+func NewAccessLog(w io.Writer, options AccessLogOptions) (*AccessLog, error) {
+	fields := make([]string, 0, len(options.Fields))
+	for _, field := range options.Fields {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(AccessLogFields, field) {
+			return nil, fmt.Errorf("unknown access log field %q, use %s", field, strings.Join(AccessLogFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		fields = AccessLogFields
+	}
+	return &AccessLog{
+		logger: slog.New(slog.NewJSONHandler(w, nil)),
+		fields: fields,
+		sample: uint64(max(options.Sample, 1)),
+	}, nil
+}
+
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		return strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func (accessLog *AccessLog) log(r *http.Request, status int, size int64, duration time.Duration) {
+	if accessLog.count.Add(1)%accessLog.sample != 0 && status < http.StatusInternalServerError {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, len(accessLog.fields))
+	for _, field := range accessLog.fields {
+		switch field {
+		case "method":
+			attrs = append(attrs, slog.String(field, r.Method))
+		case "route":
+			attrs = append(attrs, slog.String(field, r.Pattern))
+		case "path":
+			attrs = append(attrs, slog.String(field, r.URL.RequestURI()))
+		case "format":
+			attrs = append(attrs, slog.String(field, getOutputFormat(r)))
+		case "status":
+			attrs = append(attrs, slog.Int(field, status))
+		case "bytes":
+			attrs = append(attrs, slog.Int64(field, size))
+		case "duration":
+			attrs = append(attrs, slog.Duration(field, duration))
+		case "ip":
+			attrs = append(attrs, slog.String(field, clientIP(r)))
+		case "user_agent":
+			attrs = append(attrs, slog.String(field, r.UserAgent()))
+		case "referer":
+			attrs = append(attrs, slog.String(field, r.Referer()))
+		}
+	}
+
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	accessLog.logger.LogAttrs(context.Background(), level, "request", attrs...)
+}
+
+// BufferedLogWriter buffers log lines and writes them at most every
+// interval, so requests do not wait on a write per line.
+type BufferedLogWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	buffer *bufio.Writer
+	done   chan struct{}
+	closed sync.WaitGroup
+}
+
+func NewBufferedLogWriter(w io.Writer, interval time.Duration) *BufferedLogWriter {
+	bw := &BufferedLogWriter{
+		w:      w,
+		buffer: bufio.NewWriterSize(w, 64*1024),
+		done:   make(chan struct{}),
+	}
+	bw.closed.Add(1)
+	go func() {
+		defer bw.closed.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bw.Flush()
+			case <-bw.done:
+				return
+			}
+		}
+	}()
+	return bw
+}
+
+func (bw *BufferedLogWriter) Write(b []byte) (int, error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	// Flushing first keeps lines whole in each write, so a rotated file does
+	// not end halfway through a line.
+	if len(b) > bw.buffer.Available() && bw.buffer.Buffered() > 0 {
+		if err := bw.buffer.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return bw.buffer.Write(b)
+}
+
+func (bw *BufferedLogWriter) Flush() error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.buffer.Flush()
+}
+
+// Close flushes the buffer and closes the underlying writer when it is an
+// io.Closer.
+func (bw *BufferedLogWriter) Close() error {
+	close(bw.done)
+	bw.closed.Wait()
+	err := bw.Flush()
+	if closer, ok := bw.w.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// RotatingFile is a log file that is renamed to name.1 once it reaches
+// maxSize bytes, moving older files up to name.maxFiles. It is not safe for
+// concurrent use, BufferedLogWriter serialises the writes.
+type RotatingFile struct {
+	name     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func OpenRotatingFile(name string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	rf := &RotatingFile{name: name, maxSize: maxSize, maxFiles: maxFiles}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to open log file: %v", err)
+	}
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", rf.name, rf.maxFiles))
+	for i := rf.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.name, i), fmt.Sprintf("%s.%d", rf.name, i+1))
+	}
+	if rf.maxFiles > 0 {
+		if err := os.Rename(rf.name, rf.name+".1"); err != nil {
+			return fmt.Errorf("unable to rotate log file: %v", err)
+		}
+	} else if err := os.Remove(rf.name); err != nil {
+		return fmt.Errorf("unable to rotate log file: %v", err)
+	}
+	return rf.open()
+}
+
+func (rf *RotatingFile) Write(b []byte) (int, error) {
+	if rf.size > 0 && rf.size+int64(len(b)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(b)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) Close() error {
+	return rf.file.Close()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog, err := NewAccessLog(&buf, AccessLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPI(testQuotes)
+	api.EnableLogging = true
+	api.AccessLog = accessLog
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/quotes/1?format=text", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.RemoteAddr = "[2001:db8::1]:4321"
+	rec := httptest.NewRecorder()
+	api.SetupMiddleware()(mux).ServeHTTP(rec, req)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"level":      "INFO",
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "/quotes/",
+		"path":       "/quotes/1?format=text",
+		"format":     "text",
+		"status":     float64(200),
+		"bytes":      float64(rec.Body.Len()),
+		"ip":         "2001:db8::1",
+		"user_agent": "test-agent",
+		"referer":    "",
+	}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("%s = %v, want %v", key, line[key], value)
+		}
+	}
+	if _, ok := line["duration"]; !ok {
+		t.Error("no duration")
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("expected one line per request\n%s", buf.String())
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog, err := NewAccessLog(&buf, AccessLogOptions{Fields: []string{"status", " path"}, Sample: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
+	for i := 0; i < 6; i++ {
+		accessLog.log(req, http.StatusOK, 0, time.Millisecond)
+	}
+	accessLog.log(req, http.StatusInternalServerError, 0, time.Millisecond)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("logged %d lines, want 2 sampled and the server error\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[2], `"level":"ERROR"`) || strings.Contains(lines[2], "method") {
+		t.Errorf("unexpected line %s", lines[2])
+	}
+
+	if _, err := NewAccessLog(&buf, AccessLogOptions{Fields: []string{"cookie"}}); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access.log")
+	file, err := OpenRotatingFile(name, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewBufferedLogWriter(file, time.Hour)
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		writer.Write([]byte(line))
+		writer.Flush()
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for suffix, want := range map[string]string{"": "fourth line\n", ".1": "third line\n", ".2": "second line\n"} {
+		got, err := os.ReadFile(name + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("access.log%s = %q, want %q", suffix, got, want)
+		}
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Errorf("access.log.3 exists, only 2 rotated files are kept")
+	}
+}
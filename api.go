@@ -2,18 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"html/template"
-	"log"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-openapi/runtime/middleware"
@@ -25,12 +29,32 @@ type API struct {
 	Quotes          Quotes
 	Authors         IndexStructure
 	Tags            IndexStructure
+	Languages       IndexStructure
+	Translations    IndexStructure
+	Similar         *SimilarIndex
+	Semantic        *HNSWIndex
+	Stats           *Stats
+	Metrics         *Metrics
+	AccessLog       *AccessLog
+	Tracer          *Tracer
+	Config          *Config
+	Pprof           bool
+	DebugToken      string
 	DefaultPageSize int
 	MaxPageSize     int
 	Runtime         string
 	EnableLogging   bool
 	PermissiveCORS  bool
 	Swagger         bool
+	DataUpdated     time.Time
+
+	checksumOnce sync.Once
+	checksum     string
+	// loading is set while main loads the data with the server already
+	// listening.
+	loading atomic.Bool
+	// stopping is closed on shutdown to end the long lived streams.
+	stopping chan struct{}
 }
 
 func (api *API) corsMiddleware(next http.Handler) http.Handler {
@@ -50,33 +74,54 @@ func (api *API) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// statusRecorder records the status and size of a response for the access
+// log and metrics. Unwrap keeps http.ResponseController working for the
+// streaming handlers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status code of the response, a handler that wrote
+// nothing responded 200.
+func (w *statusRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
 func (api *API) logMiddleware(next http.Handler) http.Handler {
+	accessLog := api.AccessLog
+	if accessLog == nil {
+		accessLog, _ = NewAccessLog(os.Stdout, AccessLogOptions{})
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		URL := r.URL.String()
-
-		ip := r.Header.Get("X-Real-IP")
-		if ip == "" {
-			if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-				ip = strings.Split(forwardedFor, ",")[0]
-			} else {
-				ip = strings.Split(r.RemoteAddr, ":")[0]
-			}
-		}
-
-		log.Printf("%s UA: %q Referer: %q IP: %s Method: %s URL: %s",
-			start.Format(time.RFC3339),
-			r.UserAgent(),
-			r.Referer(),
-			ip,
-			r.Method,
-			URL,
-		)
-		next.ServeHTTP(w, r)
-		log.Printf("took Duration: %s, URL: %s",
-			time.Since(start).String(),
-			URL,
-		)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		accessLog.log(r, recorder.Status(), recorder.size, time.Since(start))
 	})
 }
 
@@ -93,6 +138,10 @@ func (api *API) attributionMiddleware(next http.Handler) http.Handler {
 
 func (api *API) SetupMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
+		if api.Tracer != nil {
+			next = api.tracingMiddleware(next)
+		}
+		next = api.readinessMiddleware(next)
 		if api.EnableLogging {
 			next = api.logMiddleware(next)
 		}
@@ -100,6 +149,9 @@ func (api *API) SetupMiddleware() func(http.Handler) http.Handler {
 			next = api.corsMiddleware(next)
 		}
 		next = api.attributionMiddleware(next)
+		if api.Metrics != nil {
+			next = api.metricsMiddleware(next)
+		}
 		return next
 	}
 }
@@ -108,6 +160,8 @@ func (api *API) SetupRoutes(mux *http.ServeMux) {
 
 	mux.HandleFunc("/quotes", api.ListQuotesHandler)
 	mux.HandleFunc("/quotes/", api.QuoteHandler)
+	mux.HandleFunc("/quotes/{id}/translations", api.TranslationsHandler)
+	mux.HandleFunc("/quotes/{id}/similar", api.SimilarHandler)
 
 	mux.HandleFunc("/tags", api.ListTagsHandler)
 	mux.HandleFunc("/tags/", api.TagQuotesHandler)
@@ -116,13 +170,28 @@ func (api *API) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/authors/", api.AuthorQuotesHandler)
 
 	mux.HandleFunc("/random-quote", api.QuoteHandler)
-
-	mux.HandleFunc("/debug", func(w http.ResponseWriter, r *http.Request) {
-		PrintMemUsage()
-		fmt.Fprintf(w, "Debug information printed to console")
-	})
+	mux.HandleFunc("/feed", api.FeedHandler)
+	mux.HandleFunc("/export", api.ExportHandler)
+	mux.HandleFunc("/stream/random", api.StreamRandomHandler)
+	mux.HandleFunc("/ws", api.WebSocketHandler)
+	mux.HandleFunc("/query", api.QueryHandler)
+	mux.HandleFunc("/search/semantic", api.SemanticSearchHandler)
+	mux.HandleFunc("/stats", api.StatsHandler)
+	mux.HandleFunc("/graphql", api.GraphQLHandler)
+	mux.HandleFunc("/graphql/schema", api.GraphQLSchemaHandler)
+
+	mux.Handle("/debug", api.debugAuth(http.HandlerFunc(api.DebugHandler), true))
+	if api.Pprof {
+		api.setupPprof(mux)
+	}
+	if api.Metrics != nil {
+		mux.HandleFunc("/metrics", api.MetricsHandler)
+	}
+	mux.HandleFunc("/healthz", api.HealthzHandler)
+	mux.HandleFunc("/readyz", api.ReadyzHandler)
 	mux.HandleFunc("/favicon.ico", api.faviconHandler)
 	mux.HandleFunc("/examples/", api.HandleFormatDocs)
+	mux.HandleFunc("/schema/quotes.proto", api.protoSchemaHandler)
 
 	mux.HandleFunc("/", api.QuoteHandler)
 
@@ -138,8 +207,8 @@ func (api *API) SetupRoutes(mux *http.ServeMux) {
 }
 
 func calculateSafeIndices(total int, pagination Pagination) (startIndex, endIndex, capacity int) {
-	startIndex = (pagination.Page - 1) * pagination.PageSize
-	if startIndex >= total {
+	startIndex = pagination.offset
+	if startIndex < 0 || startIndex >= total {
 		return 0, 0, 0
 	}
 
@@ -190,17 +259,34 @@ type PaginatedTagsResponse struct {
 func (api *API) TagQuotesHandler(w http.ResponseWriter, r *http.Request) {
 	tagName := r.URL.Path[len("/tags/"):]
 
+	lookup := startSpan(r.Context(), "index lookup")
 	quoteIDs, exists := api.Tags.NameToQuotes[tagName]
+	lookup.SetAttr("go_quote.index", "tags")
+	lookup.SetAttr("go_quote.results", len(quoteIDs))
+	lookup.End()
 	if !exists {
 		returnError(w, getOutputFormat(r), http.StatusNotFound, "Tag not found", "Given tag does not exist")
 		return
 	}
 
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get(PAGESIZE))
+	api.serveQuoteList(w, r, quoteIDs, "Quotes tagged with "+tagName, "All quotes tagged with "+tagName, nil)
+}
 
-	pagination := api.paginate(len(quoteIDs), page, pageSize)
+// serveQuoteList writes a page of the given quotes in the requested format,
+// title and description are used by the feed formats. The quotes of an
+// author are wrapped with the author when author is set.
+func (api *API) serveQuoteList(w http.ResponseWriter, r *http.Request, quoteIDs []int, title, description string, author *AuthorResponse) {
+	format := getOutputFormat(r)
+	pagination, err := api.paginateRequest(r, quoteIDs, len(quoteIDs))
+	if err != nil {
+		returnError(w, format, http.StatusBadRequest, "Invalid cursor", err.Error())
+		return
+	}
 	startIndex, endIndex, capacity := calculateSafeIndices(len(quoteIDs), pagination)
+	setLinkHeader(w, buildPageLinks(fmt.Sprintf("%s://%s", scheme(r), r.Host), r.URL, pagination))
+	if capacity > largePage {
+		liftDeadlines(w)
+	}
 
 	quotes := make([]ResponseQuote, 0, capacity)
 	for _, id := range quoteIDs[startIndex:endIndex] {
@@ -212,11 +298,39 @@ func (api *API) TagQuotesHandler(w http.ResponseWriter, r *http.Request) {
 		Pagination: pagination,
 	}
 
-	api.formatResponseQuotes(w, response, getOutputFormat(r))
+	span := startSpan(r.Context(), "format")
+	span.SetAttr("go_quote.format", format)
+	defer span.End()
+	if isFeedFormat(format) {
+		setPaginationHeaders(w, pagination)
+		feed := api.quotesFeed(r, title, description, quotes, pagination)
+		serveFeed(w, feed, format)
+		return
+	}
+	if author != nil {
+		api.formatAuthorQuotes(w, r, PaginatedAuthorResponse{
+			Author:      author.Name,
+			AuthorID:    author.AuthorID,
+			TotalQuotes: author.TotalQuotes,
+			Quotes:      quotes,
+			Pagination:  pagination,
+		}, format, parseFields(r.URL.Query().Get("fields")))
+		return
+	}
+	if format == "hal" {
+		api.serveHALQuotes(w, r, response)
+		return
+	}
+
+	api.formatResponseQuotes(w, response, format, parseFields(r.URL.Query().Get("fields")))
 }
 
 func (api *API) ListTagsHandler(w http.ResponseWriter, r *http.Request) {
 	requestData := createRequestDataList(r, api, TagsTypeRequest)
+	setLinkHeader(w, buildPageLinks(requestData.BaseURL, r.URL, requestData.Pagination))
+	if requestData.Total > largePage {
+		liftDeadlines(w)
+	}
 
 	tags := make([]TagResponse, 0, requestData.Total)
 
@@ -233,12 +347,21 @@ func (api *API) ListTagsHandler(w http.ResponseWriter, r *http.Request) {
 		Pagination: requestData.Pagination,
 	}
 
+	if requestData.Format == "hal" {
+		api.serveHALTags(w, r, response)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
 func (api *API) ListAuthorsHandler(w http.ResponseWriter, r *http.Request) {
 	requestData := createRequestDataList(r, api, AuthorsTypeRequest)
+	setLinkHeader(w, buildPageLinks(requestData.BaseURL, r.URL, requestData.Pagination))
+	if requestData.Total > largePage {
+		liftDeadlines(w)
+	}
 
 	authors := make([]AuthorResponse, 0, requestData.Total)
 	for i := requestData.StartIndex; i < requestData.EndIndex; i++ {
@@ -256,6 +379,11 @@ func (api *API) ListAuthorsHandler(w http.ResponseWriter, r *http.Request) {
 		Pagination: requestData.Pagination,
 	}
 
+	if requestData.Format == "hal" {
+		api.serveHALAuthors(w, r, response)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -263,35 +391,19 @@ func (api *API) ListAuthorsHandler(w http.ResponseWriter, r *http.Request) {
 func (api *API) AuthorQuotesHandler(w http.ResponseWriter, r *http.Request) {
 	authorID := r.URL.Path[len("/authors/"):]
 
+	lookup := startSpan(r.Context(), "index lookup")
 	quoteIDs, exists := api.Authors.NameToQuotes[authorID]
+	lookup.SetAttr("go_quote.index", "authors")
+	lookup.SetAttr("go_quote.results", len(quoteIDs))
+	lookup.End()
 	if !exists {
 		returnError(w, getOutputFormat(r), http.StatusNotFound, "Author not found", "Given author does not exist")
 		return
 	}
 
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get(PAGESIZE))
-
-	pagination := api.paginate(len(quoteIDs), page, pageSize)
-	startIndex, endIndex, capacity := calculateSafeIndices(len(quoteIDs), pagination)
-
-	quotes := make([]ResponseQuote, 0, capacity)
-	for _, id := range quoteIDs[startIndex:endIndex] {
-		quotes = append(quotes, api.Quotes[id].CreateResponseQuote(id))
-	}
-
 	authorName, _ := url.QueryUnescape(authorID)
-
-	response := PaginatedAuthorResponse{
-		Author:      authorName,
-		AuthorID:    authorID,
-		TotalQuotes: len(api.Authors.NameToQuotes[authorID]),
-		Quotes:      quotes,
-		Pagination:  pagination,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	author := &AuthorResponse{Name: authorName, AuthorID: authorID, TotalQuotes: len(quoteIDs)}
+	api.serveQuoteList(w, r, quoteIDs, "Quotes by "+authorName, "All quotes by "+authorName, author)
 }
 
 type RequestDataList struct {
@@ -304,6 +416,10 @@ type RequestDataList struct {
 	EndIndex        int
 	Total           int
 	RequestCategory Category
+	BaseURL         string
+	URL             *url.URL
+	Fields          FieldSet
+	Context         context.Context
 }
 
 func createRequestDataList(r *http.Request, api *API, category Category) *RequestDataList {
@@ -336,12 +452,17 @@ func createRequestDataList(r *http.Request, api *API, category Category) *Reques
 		StartIndex: startIndex,
 		EndIndex:   endIndex,
 		Total:      capacity,
+		BaseURL:    fmt.Sprintf("%s://%s", scheme(r), r.Host),
+		URL:        r.URL,
+		Fields:     parseFields(urlParameters.Get("fields")),
+		Context:    r.Context(),
 	}
 }
 
 type RequestData struct {
 	Gzip   bool
 	Format string
+	Fields FieldSet
 }
 
 func createRequestData(r *http.Request, api *API) *RequestData {
@@ -350,6 +471,7 @@ func createRequestData(r *http.Request, api *API) *RequestData {
 	return &RequestData{
 		Gzip:   gzip,
 		Format: getOutputFormat(r),
+		Fields: parseFields(urlParameters.Get("fields")),
 	}
 }
 
@@ -359,6 +481,8 @@ type ResponseInfo struct {
 	BaseURL  string
 	QuoteURL string
 	Format   string
+	Fields   FieldSet
+	Context  context.Context
 }
 
 func getResponseInfo(r *http.Request, quoteID int, requestdata *RequestData) *ResponseInfo {
@@ -369,11 +493,28 @@ func getResponseInfo(r *http.Request, quoteID int, requestdata *RequestData) *Re
 		QuoteID:  quoteID,
 		BaseURL:  baseURL,
 		QuoteURL: fmt.Sprintf("%s/quotes/%d", baseURL, quoteID),
+		Fields:   requestdata.Fields,
+		Context:  r.Context(),
 	}
 }
 
 func (api *API) ListQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	if lang := requestLanguage(r); lang != "" {
+		api.languageQuotesHandler(w, r, lang)
+		return
+	}
+
 	requestData := createRequestDataList(r, api, QuotesTypeRequest)
+	if r.URL.Query().Has("cursor") {
+		pagination, err := api.paginateRequest(r, nil, len(api.Quotes))
+		if err != nil {
+			returnError(w, requestData.Format, http.StatusBadRequest, "Invalid cursor", err.Error())
+			return
+		}
+		requestData.Pagination = pagination
+		requestData.StartIndex, requestData.EndIndex, requestData.Total = calculateSafeIndices(len(api.Quotes), pagination)
+	}
+	setLinkHeader(w, buildPageLinks(requestData.BaseURL, r.URL, requestData.Pagination))
 	api.formatStreamingResponse(w, requestData)
 }
 
@@ -392,10 +533,22 @@ func (api *API) QuoteHandler(w http.ResponseWriter, r *http.Request) {
 			returnError(w, getOutputFormat(r), http.StatusNotFound, "Quote not found", fmt.Sprintf("Invalid quote ID"))
 			return
 		}
+		quoteID = api.negotiateTranslation(w, r, quoteID)
+	} else if lang := api.randomQuoteLanguage(w, r); lang != "" {
+		quoteIDs := api.Languages.NameToQuotes[lang]
+		if len(quoteIDs) == 0 {
+			returnError(w, getOutputFormat(r), http.StatusNotFound, "Language not found", "There are no quotes in the given language")
+			return
+		}
+		setLanguageHeaders(w, lang)
+		quoteID = quoteIDs[rand.Intn(len(quoteIDs))]
 	}
 	quote := api.Quotes[quoteID].CreateResponseQuote(quoteID)
 
 	responseInfo := getResponseInfo(r, quoteID, requestData)
+	span := startSpan(r.Context(), "format")
+	span.SetAttr("go_quote.format", responseInfo.Format)
+	defer span.End()
 	api.formatResponseQuote(w, quote, responseInfo)
 }
 
@@ -426,6 +579,7 @@ func (api *API) HandleFormatDocs(w http.ResponseWriter, r *http.Request) {
 		ContentType string
 		Example     string
 		IsAudio     bool
+		IsBinary    bool
 	}
 
 	examples := make([]FormatExample, 0, len(OutputFormats))
@@ -443,13 +597,20 @@ func (api *API) HandleFormatDocs(w http.ResponseWriter, r *http.Request) {
 		api.formatResponseQuote(respWriter, quote, responseInfo)
 
 		isAudio := format == "ogg" || format == "mp3" || format == "aiff" || format == "wav"
+		isBinary := format == "protobuf" || format == "msgpack"
+
+		example := buf.String()
+		if isBinary {
+			example = hex.Dump(buf.Bytes())
+		}
 
 		examples = append(examples, FormatExample{
 			Name:        format,
 			Format:      format,
 			ContentType: contentType,
-			Example:     buf.String(),
+			Example:     example,
 			IsAudio:     isAudio,
+			IsBinary:    isBinary,
 		})
 	}
 
@@ -606,6 +767,8 @@ func (api *API) HandleFormatDocs(w http.ResponseWriter, r *http.Request) {
                             <source src="/quotes/1?format={{.Format}}" type="{{.ContentType}}">
                             Your browser does not support the audio element.
                         </audio>
+                    {{else if .IsBinary}}
+                        <pre>{{.Example}}</pre>
                     {{else}}
                         <iframe src="/quotes/1?format={{.Format}}"></iframe>
                     {{end}}
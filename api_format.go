@@ -17,11 +17,14 @@ var OutputFormats = map[string]string{
 	"csv":          "text/csv",
 	"embed":        "text/html",
 	"embed.js":     "application/javascript",
+	"hal":          "application/hal+json",
 	"html":         "text/html",
 	"json":         "application/json",
 	"markdown":     "text/markdown",
+	"msgpack":      "application/x-msgpack",
 	"oembed":       "application/json+oembed",
 	"oembed.xml":   "text/xml+oembed",
+	"protobuf":     "application/x-protobuf",
 	"rss":          "application/rss+xml",
 	"svg":          "image/svg+xml",
 	"svg-download": "image/svg+xml",
@@ -61,6 +64,12 @@ func getOutputFormat(r *http.Request) string {
 	accept := strings.ToLower(r.Header.Get("Accept"))
 	if accept != "" {
 		switch {
+		case strings.Contains(accept, "application/hal+json"):
+			format = "hal"
+		case strings.Contains(accept, "application/x-protobuf"), strings.Contains(accept, "application/protobuf"):
+			format = "protobuf"
+		case strings.Contains(accept, "application/x-msgpack"), strings.Contains(accept, "application/msgpack"):
+			format = "msgpack"
 		case strings.Contains(accept, "text/html"):
 			format = "html"
 		case strings.Contains(accept, "application/xml"):
@@ -77,11 +86,30 @@ func getOutputFormat(r *http.Request) string {
 }
 
 type XMLQuote struct {
-	XMLName xml.Name `xml:"response"`
-	ID      int      `xml:"id"`
-	Text    string   `xml:"text"`
-	Author  string   `xml:"author"`
-	Tags    []string `xml:"tags>tag"`
+	XMLName  xml.Name `xml:"response"`
+	ID       int      `xml:"id"`
+	Text     string   `xml:"text"`
+	Author   string   `xml:"author"`
+	Tags     []string `xml:"tags>tag"`
+	Source   string   `xml:"source,omitempty"`
+	Year     int      `xml:"year,omitempty"`
+	Language string   `xml:"language,omitempty"`
+	URL      string   `xml:"url,omitempty"`
+	Verified bool     `xml:"verified,omitempty"`
+}
+
+func newXMLQuote(quote ResponseQuote) XMLQuote {
+	return XMLQuote{
+		ID:       quote.ID,
+		Text:     quote.Text,
+		Author:   quote.Author,
+		Tags:     quote.Tags,
+		Source:   quote.Source,
+		Year:     quote.Year,
+		Language: quote.Language,
+		URL:      quote.URL,
+		Verified: quote.Verified,
+	}
 }
 
 func setPaginationHeaders(w http.ResponseWriter, pagination Pagination) {
@@ -94,24 +122,30 @@ func setPaginationHeaders(w http.ResponseWriter, pagination Pagination) {
 	}
 }
 
-func (api *API) formatResponseQuotes(w http.ResponseWriter, response PaginatedQuotesResponse, format string) {
+func (api *API) formatResponseQuotes(w http.ResponseWriter, response PaginatedQuotesResponse, format string, fields FieldSet) {
 	setPaginationHeaders(w, response.Pagination)
 
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
+		if !fields.All() {
+			json.NewEncoder(w).Encode(SparseQuotesResponse{
+				Quotes:     newSparseQuotes(response.Quotes, fields),
+				Pagination: response.Pagination,
+			})
+			return
+		}
 		json.NewEncoder(w).Encode(response)
 	case "csv":
 		w.Header().Set("Content-Type", "text/csv")
-		fmt.Fprint(w, quotesToCSV(response.Quotes))
+		fmt.Fprint(w, quotesToCSV(response.Quotes, fields))
 	case "html":
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		fmt.Fprint(w, quotesToHTML(response, ""))
 	case "text":
 		w.Header().Set("Content-Type", "text/plain")
 		for _, quote := range response.Quotes {
-			fmt.Fprintf(w, "Quote: %s\nAuthor: %s\nTags: %s\nID: %d\n\n",
-				quote.Text, quote.Author, strings.Join(quote.Tags, ", "), quote.ID)
+			fmt.Fprint(w, quoteToText(quote)+"\n")
 		}
 	case "markdown":
 		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
@@ -120,17 +154,61 @@ func (api *API) formatResponseQuotes(w http.ResponseWriter, response PaginatedQu
 		}
 	case "yaml":
 		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
-		fmt.Fprint(w, quotesToYAML(response.Quotes))
+		fmt.Fprint(w, quotesToYAML(response.Quotes, fields))
+	case "xml":
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, quotesToXML(response.Quotes, fields))
+	case "protobuf":
+		w.Header().Set("Content-Type", OutputFormats["protobuf"])
+		writeProtobufQuotes(w, response.Quotes, fields)
+	case "msgpack":
+		w.Header().Set("Content-Type", OutputFormats["msgpack"])
+		writeMsgpackQuotes(w, response.Quotes, response.Pagination, fields)
 	default:
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 	}
 }
 
+// formatAuthorQuotes writes the quotes of an author. JSON, HAL and msgpack
+// carry the author next to the quotes, the other formats are the plain list.
+func (api *API) formatAuthorQuotes(w http.ResponseWriter, r *http.Request, response PaginatedAuthorResponse, format string, fields FieldSet) {
+	switch format {
+	case "hal":
+		api.serveHALAuthorQuotes(w, r, response)
+	case "msgpack":
+		setPaginationHeaders(w, response.Pagination)
+		w.Header().Set("Content-Type", OutputFormats["msgpack"])
+		writeMsgpackAuthorQuotes(w, response, fields)
+	case "csv", "html", "text", "markdown", "yaml", "xml", "protobuf":
+		api.formatResponseQuotes(w, PaginatedQuotesResponse{Quotes: response.Quotes, Pagination: response.Pagination}, format, fields)
+	default:
+		setPaginationHeaders(w, response.Pagination)
+		w.Header().Set("Content-Type", "application/json")
+		if !fields.All() {
+			json.NewEncoder(w).Encode(SparseAuthorResponse{
+				Author:      response.Author,
+				AuthorID:    response.AuthorID,
+				TotalQuotes: response.TotalQuotes,
+				Quotes:      newSparseQuotes(response.Quotes, fields),
+				Pagination:  response.Pagination,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
 func (api *API) formatResponseQuote(w http.ResponseWriter, quote ResponseQuote, responseInfo *ResponseInfo) {
 	switch responseInfo.Format {
 	case "json":
 		serveJSONQuote(w, quote, api, responseInfo)
+	case "hal":
+		serveHALQuote(w, quote, api, responseInfo)
+	case "protobuf":
+		serveProtobufQuote(w, quote, api, responseInfo)
+	case "msgpack":
+		serveMsgpackQuote(w, quote, api, responseInfo)
 	case "xml":
 		serveXMLQuote(w, quote, api, responseInfo)
 	case "html":
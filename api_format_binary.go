@@ -0,0 +1,340 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/bits"
+	"net/http"
+)
+
+// QuotesProto is the schema of the protobuf output format, served at
+// /schema/quotes.proto so consumers can generate their clients.
+//
+//go:embed proto/quotes.proto
+var QuotesProto string
+
+const (
+	protoWireVarint = 0
+	protoWireBytes  = 2
+)
+
+func appendProtoVarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+func appendProtoTag(b []byte, num int, wireType int) []byte {
+	return appendProtoVarint(b, uint64(num)<<3|uint64(wireType))
+}
+
+// appendProtoString appends a string field, empty strings are left out as
+// proto3 does for default values.
+func appendProtoString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendProtoTag(b, num, protoWireBytes)
+	b = appendProtoVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendProtoInt64(b []byte, num int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendProtoTag(b, num, protoWireVarint)
+	return appendProtoVarint(b, uint64(v))
+}
+
+// appendProtoQuote appends the goquote.v1.Quote message for the selected
+// fields of the quote.
+func appendProtoQuote(b []byte, q *ResponseQuote, fields FieldSet) []byte {
+	if fields.Has(FieldID) {
+		b = appendProtoInt64(b, 1, int64(q.ID))
+	}
+	if fields.Has(FieldText) {
+		b = appendProtoString(b, 2, q.Text)
+	}
+	if fields.Has(FieldAuthor) {
+		b = appendProtoString(b, 3, q.Author)
+	}
+	if fields.Has(FieldAuthorID) {
+		b = appendProtoString(b, 4, q.AuthorID)
+	}
+	if fields.Has(FieldTags) {
+		for _, tag := range q.Tags {
+			// Repeated fields keep empty elements, unlike singular ones.
+			b = appendProtoTag(b, 5, protoWireBytes)
+			b = appendProtoVarint(b, uint64(len(tag)))
+			b = append(b, tag...)
+		}
+	}
+	fields = fields.For(&q.Quote)
+	if fields.Has(FieldSource) {
+		b = appendProtoString(b, 6, q.Source)
+	}
+	if fields.Has(FieldYear) {
+		b = appendProtoInt64(b, 7, int64(q.Year))
+	}
+	if fields.Has(FieldLanguage) {
+		b = appendProtoString(b, 8, q.Language)
+	}
+	if fields.Has(FieldURL) {
+		b = appendProtoString(b, 9, q.URL)
+	}
+	if fields.Has(FieldVerified) {
+		b = appendProtoInt64(b, 10, 1)
+	}
+	return b
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v < 128:
+		return append(b, byte(v))
+	case v >= 0 && v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v >= 0 && v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v >= 0 && v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	case v >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+// appendMsgpackQuote appends the quote as a map with the same keys as the
+// JSON representation.
+func appendMsgpackQuote(b []byte, q *ResponseQuote, fields FieldSet) []byte {
+	fields = fields.For(&q.Quote)
+	b = appendMsgpackMapHeader(b, bits.OnesCount16(uint16(fields&AllFields)))
+	if fields.Has(FieldText) {
+		b = appendMsgpackString(appendMsgpackString(b, "text"), q.Text)
+	}
+	if fields.Has(FieldAuthor) {
+		b = appendMsgpackString(appendMsgpackString(b, "author"), q.Author)
+	}
+	if fields.Has(FieldTags) {
+		b = appendMsgpackArrayHeader(appendMsgpackString(b, "tags"), len(q.Tags))
+		for _, tag := range q.Tags {
+			b = appendMsgpackString(b, tag)
+		}
+	}
+	if fields.Has(FieldID) {
+		b = appendMsgpackInt(appendMsgpackString(b, "id"), int64(q.ID))
+	}
+	if fields.Has(FieldAuthorID) {
+		b = appendMsgpackString(appendMsgpackString(b, "author_id"), q.AuthorID)
+	}
+	if fields.Has(FieldSource) {
+		b = appendMsgpackString(appendMsgpackString(b, "source"), q.Source)
+	}
+	if fields.Has(FieldYear) {
+		b = appendMsgpackInt(appendMsgpackString(b, "year"), int64(q.Year))
+	}
+	if fields.Has(FieldLanguage) {
+		b = appendMsgpackString(appendMsgpackString(b, "language"), q.Language)
+	}
+	if fields.Has(FieldURL) {
+		b = appendMsgpackString(appendMsgpackString(b, "url"), q.URL)
+	}
+	if fields.Has(FieldVerified) {
+		// 0xc3 is msgpack true, unverified quotes leave the key out.
+		b = append(appendMsgpackString(b, "verified"), 0xc3)
+	}
+	return b
+}
+
+func appendMsgpackPagination(b []byte, p Pagination) []byte {
+	optional := []struct {
+		key   string
+		value string
+	}{
+		{"next", p.Next},
+		{"cursor", p.Cursor},
+		{"next_cursor", p.NextCursor},
+	}
+
+	size := 4
+	for _, field := range optional {
+		if field.value != "" {
+			size++
+		}
+	}
+
+	b = appendMsgpackMapHeader(b, size)
+	b = appendMsgpackInt(appendMsgpackString(b, "page"), int64(p.Page))
+	b = appendMsgpackInt(appendMsgpackString(b, "page_size"), int64(p.PageSize))
+	b = appendMsgpackInt(appendMsgpackString(b, "total"), int64(p.Total))
+	b = appendMsgpackInt(appendMsgpackString(b, "pages"), int64(p.Pages))
+	for _, field := range optional {
+		if field.value != "" {
+			b = appendMsgpackString(appendMsgpackString(b, field.key), field.value)
+		}
+	}
+	return b
+}
+
+func serveProtobufQuote(w http.ResponseWriter, q ResponseQuote, api *API, responseInfo *ResponseInfo) {
+	w.Header().Set("Content-Type", OutputFormats["protobuf"])
+	w.Write(appendProtoQuote(nil, &q, responseInfo.Fields))
+}
+
+func serveMsgpackQuote(w http.ResponseWriter, q ResponseQuote, api *API, responseInfo *ResponseInfo) {
+	w.Header().Set("Content-Type", OutputFormats["msgpack"])
+	w.Write(appendMsgpackQuote(nil, &q, responseInfo.Fields))
+}
+
+// writeProtobufQuotes writes the quotes as a stream of length-delimited
+// messages.
+func writeProtobufQuotes(w io.Writer, quotes []ResponseQuote, fields FieldSet) error {
+	var buf, message []byte
+	for i := range quotes {
+		message = appendProtoQuote(message[:0], &quotes[i], fields)
+		buf = appendProtoVarint(buf[:0], uint64(len(message)))
+		buf = append(buf, message...)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMsgpackQuotes(w io.Writer, quotes []ResponseQuote, pagination Pagination, fields FieldSet) error {
+	buf := appendMsgpackMapHeader(nil, 2)
+	buf = appendMsgpackArrayHeader(appendMsgpackString(buf, "quotes"), len(quotes))
+	for i := range quotes {
+		buf = appendMsgpackQuote(buf, &quotes[i], fields)
+	}
+	buf = appendMsgpackPagination(appendMsgpackString(buf, "pagination"), pagination)
+	_, err := w.Write(buf)
+	return err
+}
+
+func writeMsgpackAuthorQuotes(w io.Writer, response PaginatedAuthorResponse, fields FieldSet) error {
+	buf := appendMsgpackMapHeader(nil, 5)
+	buf = appendMsgpackString(appendMsgpackString(buf, "author"), response.Author)
+	buf = appendMsgpackString(appendMsgpackString(buf, "author_id"), response.AuthorID)
+	buf = appendMsgpackInt(appendMsgpackString(buf, "total_quotes"), int64(response.TotalQuotes))
+	buf = appendMsgpackArrayHeader(appendMsgpackString(buf, "quotes"), len(response.Quotes))
+	for i := range response.Quotes {
+		buf = appendMsgpackQuote(buf, &response.Quotes[i], fields)
+	}
+	buf = appendMsgpackPagination(appendMsgpackString(buf, "pagination"), response.Pagination)
+	_, err := w.Write(buf)
+	return err
+}
+
+func streamQuotesProtobuf(w http.ResponseWriter, api *API, RequestDataList *RequestDataList) {
+	setPaginationHeaders(w, RequestDataList.Pagination)
+
+	approximateSize := RequestDataList.Total*150 + 100
+	writer, closeWriter := api.streamWriter(w, RequestDataList, approximateSize)
+	defer closeWriter()
+
+	w.Header().Set("Content-Type", OutputFormats["protobuf"])
+	w.WriteHeader(http.StatusOK)
+
+	var buf, message []byte
+	for i := RequestDataList.StartIndex; i < RequestDataList.EndIndex; i++ {
+		quote := api.Quotes[i].CreateResponseQuote(i)
+		message = appendProtoQuote(message[:0], &quote, RequestDataList.Fields)
+		buf = appendProtoVarint(buf[:0], uint64(len(message)))
+		buf = append(buf, message...)
+		if _, err := writer.Write(buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func streamQuotesMsgpack(w http.ResponseWriter, api *API, RequestDataList *RequestDataList) {
+	setPaginationHeaders(w, RequestDataList.Pagination)
+
+	approximateSize := RequestDataList.Total*180 + 100
+	writer, closeWriter := api.streamWriter(w, RequestDataList, approximateSize)
+	defer closeWriter()
+
+	w.Header().Set("Content-Type", OutputFormats["msgpack"])
+	w.WriteHeader(http.StatusOK)
+
+	buf := appendMsgpackMapHeader(nil, 2)
+	buf = appendMsgpackArrayHeader(appendMsgpackString(buf, "quotes"), RequestDataList.EndIndex-RequestDataList.StartIndex)
+	if _, err := writer.Write(buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := RequestDataList.StartIndex; i < RequestDataList.EndIndex; i++ {
+		quote := api.Quotes[i].CreateResponseQuote(i)
+		buf = appendMsgpackQuote(buf[:0], &quote, RequestDataList.Fields)
+		if _, err := writer.Write(buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	buf = appendMsgpackPagination(appendMsgpackString(buf[:0], "pagination"), RequestDataList.Pagination)
+	if _, err := writer.Write(buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := writer.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (api *API) protoSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	io.WriteString(w, QuotesProto)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeMsgpack decodes the subset of MessagePack written by the encoders.
+func decodeMsgpack(t *testing.T, b []byte) (interface{}, []byte) {
+	t.Helper()
+	if len(b) == 0 {
+		t.Fatal("unexpected end of msgpack data")
+	}
+	c, b := b[0], b[1:]
+
+	readMap := func(n int, b []byte) (interface{}, []byte) {
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			var key, value interface{}
+			key, b = decodeMsgpack(t, b)
+			value, b = decodeMsgpack(t, b)
+			m[key.(string)] = value
+		}
+		return m, b
+	}
+	readArray := func(n int, b []byte) (interface{}, []byte) {
+		a := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			var value interface{}
+			value, b = decodeMsgpack(t, b)
+			a = append(a, value)
+		}
+		return a, b
+	}
+
+	switch {
+	case c < 0x80:
+		return int64(c), b
+	case c&0xf0 == 0x80:
+		return readMap(int(c&0x0f), b)
+	case c&0xf0 == 0x90:
+		return readArray(int(c&0x0f), b)
+	case c&0xe0 == 0xa0:
+		n := int(c & 0x1f)
+		return string(b[:n]), b[n:]
+	case c == 0xd9:
+		n := int(b[0])
+		return string(b[1 : 1+n]), b[1+n:]
+	case c == 0xc3:
+		return true, b
+	case c == 0xcc:
+		return int64(b[0]), b[1:]
+	case c == 0xcd:
+		return int64(binary.BigEndian.Uint16(b)), b[2:]
+	case c == 0xdc:
+		return readArray(int(binary.BigEndian.Uint16(b)), b[2:])
+	}
+	t.Fatalf("unsupported msgpack type 0x%x", c)
+	return nil, nil
+}
+
+// decodeProtoQuotes decodes a stream of length-delimited Quote messages.
+func decodeProtoQuotes(t *testing.T, b []byte) []ResponseQuote {
+	t.Helper()
+	var quotes []ResponseQuote
+	for len(b) > 0 {
+		size, n := binary.Uvarint(b)
+		message := b[n : n+int(size)]
+		b = b[n+int(size):]
+
+		var q ResponseQuote
+		for len(message) > 0 {
+			tag, n := binary.Uvarint(message)
+			message = message[n:]
+			value, n := binary.Uvarint(message)
+			message = message[n:]
+			if tag&7 == protoWireVarint {
+				switch tag >> 3 {
+				case 1:
+					q.ID = int(value)
+				case 7:
+					q.Year = int(value)
+				case 10:
+					q.Verified = value == 1
+				default:
+					t.Fatalf("unexpected varint field %d", tag>>3)
+				}
+				continue
+			}
+			s := string(message[:value])
+			message = message[value:]
+			switch tag >> 3 {
+			case 2:
+				q.Text = s
+			case 3:
+				q.Author = s
+			case 4:
+				q.AuthorID = s
+			case 5:
+				q.Tags = append(q.Tags, s)
+			case 6:
+				q.Source = s
+			case 8:
+				q.Language = s
+			case 9:
+				q.URL = s
+			default:
+				t.Fatalf("unexpected field %d", tag>>3)
+			}
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes
+}
+
+func TestProtobufQuote(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	rec := serveTestRequestAccept(api, "/quotes/1", "application/x-protobuf")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	b := rec.Body.Bytes()
+	got := decodeProtoQuotes(t, append(binary.AppendUvarint(nil, uint64(len(b))), b...))
+	want := []ResponseQuote{testQuotes[1].CreateResponseQuote(1)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestProtobufStream(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	rec := serveTestRequest(api, "/quotes?format=protobuf&fields=id,text")
+	got := decodeProtoQuotes(t, rec.Body.Bytes())
+	want := []ResponseQuote{
+		{Quote: Quote{Text: testQuotes[0].Text}},
+		{Quote: Quote{Text: testQuotes[1].Text}, ID: 1},
+		{Quote: Quote{Text: testQuotes[2].Text}, ID: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMsgpackQuotes(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"stream", "/quotes?format=msgpack&page_size=2&fields=text,tags"},
+		{"tag", "/tags/love?format=msgpack&page_size=2&fields=text,tags"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveTestRequest(api, tt.url)
+			if ct := rec.Header().Get("Content-Type"); ct != "application/x-msgpack" {
+				t.Fatalf("Content-Type = %q", ct)
+			}
+
+			value, rest := decodeMsgpack(t, rec.Body.Bytes())
+			if len(rest) != 0 {
+				t.Fatalf("%d trailing bytes", len(rest))
+			}
+			response := value.(map[string]interface{})
+			quotes := response["quotes"].([]interface{})
+			if len(quotes) != 2 {
+				t.Fatalf("got %d quotes, want 2", len(quotes))
+			}
+			want := map[string]interface{}{
+				"text": testQuotes[0].Text,
+				"tags": []interface{}{"food", "love"},
+			}
+			if !reflect.DeepEqual(quotes[0], want) {
+				t.Errorf("got %+v, want %+v", quotes[0], want)
+			}
+			pagination := response["pagination"].(map[string]interface{})
+			if pagination["page_size"] != int64(2) || pagination["page"] != int64(1) {
+				t.Errorf("unexpected pagination %+v", pagination)
+			}
+		})
+	}
+}
+
+func TestBinaryMetadata(t *testing.T) {
+	quotes := append(Quotes{}, testQuotes...)
+	quotes[1].Source = "Collected Works"
+	quotes[1].Year = 1890
+	quotes[1].Language = "en"
+	quotes[1].URL = "https://example.com/works"
+	quotes[1].Verified = true
+	api := newTestAPI(quotes)
+
+	rec := serveTestRequest(api, "/quotes?format=protobuf&page_size=2")
+	got := decodeProtoQuotes(t, rec.Body.Bytes())
+	want := []ResponseQuote{quotes[0].CreateResponseQuote(0), quotes[1].CreateResponseQuote(1)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("protobuf got %+v, want %+v", got, want)
+	}
+
+	rec = serveTestRequest(api, "/quotes/1?format=msgpack&fields=id,source,year,language,url,verified")
+	value, _ := decodeMsgpack(t, rec.Body.Bytes())
+	wantMsgpack := map[string]interface{}{
+		"id":       int64(1),
+		"source":   "Collected Works",
+		"year":     int64(1890),
+		"language": "en",
+		"url":      "https://example.com/works",
+		"verified": true,
+	}
+	if !reflect.DeepEqual(value, wantMsgpack) {
+		t.Errorf("msgpack got %+v, want %+v", value, wantMsgpack)
+	}
+
+	// Quotes without metadata leave the keys out.
+	rec = serveTestRequest(api, "/quotes/0?format=msgpack&fields=id,source,verified")
+	if value, _ := decodeMsgpack(t, rec.Body.Bytes()); !reflect.DeepEqual(value, map[string]interface{}{"id": int64(0)}) {
+		t.Errorf("msgpack without metadata got %+v", value)
+	}
+}
+
+func TestMsgpackInt(t *testing.T) {
+	for _, v := range []int64{0, 127, 128, 255, 256, 65535} {
+		got, _ := decodeMsgpack(t, appendMsgpackInt(nil, v))
+		if got != v {
+			t.Errorf("appendMsgpackInt(%d) decoded to %v", v, got)
+		}
+	}
+}
+
+func TestProtoSchema(t *testing.T) {
+	rec := serveTestRequest(newTestAPI(testQuotes), "/schema/quotes.proto")
+	if !strings.Contains(rec.Body.String(), "message Quote") {
+		t.Errorf("schema not served: %q", rec.Body.String())
+	}
+}
@@ -9,29 +9,35 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
-	"strings"
 )
 
+// streamWriter returns the buffered writer of a streamed response. With gzip
+// the body is compressed, counted in the gzip metrics and traced in a gzip
+// span. closeWriter flushes the buffer and ends the compression, defer it
+// before any encoder that flushes into the writer.
+func (api *API) streamWriter(w http.ResponseWriter, RequestDataList *RequestDataList, size int) (writer *bufio.Writer, closeWriter func()) {
+	if !RequestDataList.Gzip {
+		writer = bufio.NewWriterSize(w, size)
+		return writer, func() { writer.Flush() }
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	span := startSpan(RequestDataList.Context, "gzip")
+	gw, _ := gzip.NewWriterLevel(api.Metrics.gzipOutput(w), gzip.BestSpeed)
+	writer = bufio.NewWriterSize(api.Metrics.gzipInput(gw), size)
+	return writer, func() {
+		writer.Flush()
+		gw.Close()
+		span.End()
+	}
+}
+
 func streamQuotesJSON(w http.ResponseWriter, api *API, RequestDataList *RequestDataList) {
 	setPaginationHeaders(w, RequestDataList.Pagination)
 
-	var writer io.Writer
-	var bufWriter *bufio.Writer
-
 	approximateSize := RequestDataList.Total*200 + 100
-	if RequestDataList.Gzip {
-		w.Header().Set("Content-Encoding", "gzip")
-		gw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
-		bufWriter = bufio.NewWriterSize(gw, approximateSize)
-		defer bufWriter.Flush()
-		defer gw.Close()
-		writer = bufWriter
-	} else {
-		bufWriter = bufio.NewWriterSize(w, approximateSize)
-		defer bufWriter.Flush()
-		writer = bufWriter
-	}
+	writer, closeWriter := api.streamWriter(w, RequestDataList, approximateSize)
+	defer closeWriter()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -44,8 +50,17 @@ func streamQuotesJSON(w http.ResponseWriter, api *API, RequestDataList *RequestD
 		return
 	}
 
+	fields := RequestDataList.Fields
+	encodeQuote := func(i int) error {
+		quote := api.Quotes[i].CreateResponseQuote(i)
+		if fields.All() {
+			return enc.Encode(quote)
+		}
+		return enc.Encode(newSparseQuote(&quote, fields))
+	}
+
 	for i := RequestDataList.StartIndex; i < RequestDataList.EndIndex-1; i++ {
-		if err := enc.Encode(api.Quotes[i].CreateResponseQuote(i)); err != nil {
+		if err := encodeQuote(i); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -58,7 +73,7 @@ func streamQuotesJSON(w http.ResponseWriter, api *API, RequestDataList *RequestD
 
 	// Handle the last item without a trailing comma
 	if RequestDataList.EndIndex > RequestDataList.StartIndex {
-		if err := enc.Encode(api.Quotes[RequestDataList.EndIndex-1].CreateResponseQuote(RequestDataList.EndIndex - 1)); err != nil {
+		if err := encodeQuote(RequestDataList.EndIndex - 1); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -79,7 +94,7 @@ func streamQuotesJSON(w http.ResponseWriter, api *API, RequestDataList *RequestD
 		return
 	}
 
-	if err := bufWriter.Flush(); err != nil {
+	if err := writer.Flush(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -90,47 +105,29 @@ func streamQuotesCSV(w http.ResponseWriter, api *API, RequestDataList *RequestDa
 	w.Header().Set("Content-Disposition", "attachment; filename=quotes.csv")
 	setPaginationHeaders(w, RequestDataList.Pagination)
 
-	var writer io.Writer
-	var bufWriter *bufio.Writer
-
 	approximateSize := RequestDataList.Total*200 + 100
-	if RequestDataList.Gzip {
-		w.Header().Set("Content-Encoding", "gzip")
-		gw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
-		bufWriter = bufio.NewWriterSize(gw, approximateSize)
-		defer bufWriter.Flush()
-		defer gw.Close()
-		writer = bufWriter
-	} else {
-		bufWriter = bufio.NewWriterSize(w, approximateSize)
-		defer bufWriter.Flush()
-		writer = bufWriter
-	}
-
-	defer bufWriter.Flush()
+	writer, closeWriter := api.streamWriter(w, RequestDataList, approximateSize)
+	defer closeWriter()
 
 	w.WriteHeader(http.StatusOK)
 
 	csvWriter := csv.NewWriter(writer)
 	defer csvWriter.Flush()
 
+	columns := csvColumns(RequestDataList.Fields, FieldID, FieldText, FieldAuthor, FieldAuthorID, FieldTags,
+		FieldSource, FieldYear, FieldLanguage, FieldURL, FieldVerified)
+
 	// Write CSV header
-	header := []string{"ID", "Text", "Author", "AuthorID", "Tags"}
-	if err := csvWriter.Write(header); err != nil {
+	if err := csvWriter.Write(csvHeader(columns)); err != nil {
 		http.Error(w, "Failed to write header row: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	row := make([]string, 5)
+	row := make([]string, len(columns))
 	fmt.Println(RequestDataList)
 	for i := RequestDataList.StartIndex; i < RequestDataList.EndIndex; i++ {
 		quote := api.Quotes[i].CreateResponseQuote(i)
-
-		row[0] = strconv.Itoa(quote.ID)
-		row[1] = quote.Text
-		row[2] = quote.Author
-		row[3] = quote.AuthorID
-		row[4] = strings.Join(quote.Tags, "|")
+		csvRow(row, columns, &quote)
 
 		if err := csvWriter.Write(row); err != nil {
 			http.Error(w, "Failed to write data row: "+err.Error(), http.StatusInternalServerError)
@@ -142,22 +139,9 @@ func streamQuotesCSV(w http.ResponseWriter, api *API, RequestDataList *RequestDa
 func streamQuotesXML(w http.ResponseWriter, api *API, RequestDataList *RequestDataList) {
 	w.Header().Set("Content-Type", "application/xml")
 
-	var writer io.Writer
-	var bufWriter *bufio.Writer
 	approximateSize := RequestDataList.Total*200 + 100
-
-	if RequestDataList.Gzip {
-		w.Header().Set("Content-Encoding", "gzip")
-		gw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
-		bufWriter = bufio.NewWriterSize(gw, approximateSize)
-		defer bufWriter.Flush()
-		defer gw.Close()
-		writer = bufWriter
-	} else {
-		bufWriter = bufio.NewWriterSize(w, approximateSize)
-		defer bufWriter.Flush()
-		writer = bufWriter
-	}
+	writer, closeWriter := api.streamWriter(w, RequestDataList, approximateSize)
+	defer closeWriter()
 
 	w.WriteHeader(http.StatusOK)
 
@@ -170,11 +154,9 @@ func streamQuotesXML(w http.ResponseWriter, api *API, RequestDataList *RequestDa
 
 	for i := RequestDataList.StartIndex; i < RequestDataList.EndIndex; i++ {
 		quote := api.Quotes[i].CreateResponseQuote(i)
-		xmlQuote := XMLQuote{
-			Text:   quote.Text,
-			Author: quote.Author,
-			Tags:   quote.Tags,
-			ID:     quote.ID,
+		var xmlQuote interface{} = newXMLQuote(quote)
+		if !RequestDataList.Fields.All() {
+			xmlQuote = newXMLSparseQuote(&quote, RequestDataList.Fields)
 		}
 
 		if err := xmlEncoder.Encode(xmlQuote); err != nil {
@@ -198,24 +180,9 @@ func streamQuotesYAML(w http.ResponseWriter, api *API, RequestDataList *RequestD
 	w.Header().Set("Content-Type", "application/yaml")
 	w.Header().Set("Content-Disposition", "attachment; filename=quotes.yaml")
 
-	var writer io.Writer
-	var bufWriter *bufio.Writer
-
 	approximateSize := RequestDataList.Total*200 + 100
-	if RequestDataList.Gzip {
-		w.Header().Set("Content-Encoding", "gzip")
-		gw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
-		bufWriter = bufio.NewWriterSize(gw, approximateSize)
-		defer bufWriter.Flush()
-		defer gw.Close()
-		writer = bufWriter
-	} else {
-		bufWriter = bufio.NewWriterSize(w, approximateSize)
-		defer bufWriter.Flush()
-		writer = bufWriter
-	}
-
-	defer bufWriter.Flush()
+	writer, closeWriter := api.streamWriter(w, RequestDataList, approximateSize)
+	defer closeWriter()
 
 	w.WriteHeader(http.StatusOK)
 
@@ -227,36 +194,28 @@ func streamQuotesYAML(w http.ResponseWriter, api *API, RequestDataList *RequestD
 	for i := RequestDataList.StartIndex; i < RequestDataList.EndIndex; i++ {
 		quote := api.Quotes[i].CreateResponseQuote(i)
 
-		if _, err := fmt.Fprintf(writer, "  - id: %d\n", quote.ID); err != nil {
-			http.Error(w, "Failed to write quote ID: "+err.Error(), http.StatusInternalServerError)
+		if err := writeYAMLQuote(writer, &quote, RequestDataList.Fields, "  - ", "    "); err != nil {
+			http.Error(w, "Failed to write quote: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if _, err := fmt.Fprintf(writer, "    text: \"%s\"\n", strings.ReplaceAll(quote.Text, "\"", "\\\"")); err != nil {
-			http.Error(w, "Failed to write quote text: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if _, err := fmt.Fprintf(writer, "    author: \"%s\"\n", strings.ReplaceAll(quote.Author, "\"", "\\\"")); err != nil {
-			http.Error(w, "Failed to write quote author: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if _, err := fmt.Fprintln(writer, "    tags:"); err != nil {
-			http.Error(w, "Failed to write tags header: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		for _, tag := range quote.Tags {
-			if _, err := fmt.Fprintf(writer, "      - %s\n", tag); err != nil {
-				http.Error(w, "Failed to write tag: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
 	}
 }
 
 func (api *API) formatStreamingResponse(w http.ResponseWriter, RequestDataList *RequestDataList) {
+	span := startSpan(RequestDataList.Context, "format")
+	span.SetAttr("go_quote.format", RequestDataList.Format)
+	defer span.End()
+	liftDeadlines(w)
 	setPaginationHeaders(w, RequestDataList.Pagination)
 	switch RequestDataList.Format {
 	case "json":
 		streamQuotesJSON(w, api, RequestDataList)
+	case "hal":
+		streamQuotesHAL(w, api, RequestDataList)
+	case "protobuf":
+		streamQuotesProtobuf(w, api, RequestDataList)
+	case "msgpack":
+		streamQuotesMsgpack(w, api, RequestDataList)
 	case "csv":
 		streamQuotesCSV(w, api, RequestDataList)
 	case "yaml":
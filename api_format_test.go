@@ -105,6 +105,18 @@ func TestGetOutputFormat(t *testing.T) {
 			acceptHeader:   "application/x-yaml",
 			expectedFormat: "yaml",
 		},
+		{
+			name:           "HAL Accept header",
+			url:            "http://go-quote.com",
+			acceptHeader:   "application/hal+json",
+			expectedFormat: "hal",
+		},
+		{
+			name:           "HAL format parameter",
+			url:            "http://go-quote.com?format=hal",
+			acceptHeader:   "",
+			expectedFormat: "hal",
+		},
 		{
 			name:           "Atom format parameter",
 			url:            "http://go-quote.com?format=atom",
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// The bulk export writes the corpus in the Apache Arrow IPC format as a table
+// of id, text, author, author_id and tags, with tags as list<utf8>, followed
+// by the source, year, language, url and verified metadata. Unknown metadata is
+// an empty string, year 0 or false rather than null. The
+// flatbuffers metadata is built by hand, the same as the protobuf and msgpack
+// encoders, so the API does not pull in the Arrow dependency tree.
+
+const (
+	arrowBatchSize = 64 * 1024
+
+	arrowMetadataV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt  = 2
+	arrowTypeUtf8 = 5
+	arrowTypeBool = 6
+	arrowTypeList = 12
+)
+
+var arrowMagic = []byte("ARROW1")
+
+// fbBuilder builds a flatbuffer back to front, offsets are measured from the
+// end of the buffer like the reference implementation does.
+type fbBuilder struct {
+	buf       []byte
+	minAlign  int
+	fields    []uint32
+	objectEnd uint32
+}
+
+func (b *fbBuilder) offset() uint32 {
+	return uint32(len(b.buf))
+}
+
+func (b *fbBuilder) prepend(p []byte) {
+	buf := make([]byte, len(p)+len(b.buf))
+	copy(buf, p)
+	copy(buf[len(p):], b.buf)
+	b.buf = buf
+}
+
+// align pads the buffer so it is aligned to size after additional bytes are
+// prepended.
+func (b *fbBuilder) align(size, additional int) {
+	if size > b.minAlign {
+		b.minAlign = size
+	}
+	pad := (size - (len(b.buf)+additional)%size) % size
+	b.prepend(make([]byte, pad))
+}
+
+func (b *fbBuilder) prependUint8(v uint8) {
+	b.prepend([]byte{v})
+}
+
+func (b *fbBuilder) prependUint16(v uint16) {
+	b.align(2, 0)
+	b.prepend(binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func (b *fbBuilder) prependUint32(v uint32) {
+	b.align(4, 0)
+	b.prepend(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (b *fbBuilder) prependUint64(v uint64) {
+	b.align(8, 0)
+	b.prepend(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+func (b *fbBuilder) prependUOffset(off uint32) {
+	b.align(4, 0)
+	b.prependUint32(b.offset() + 4 - off)
+}
+
+func (b *fbBuilder) createString(s string) uint32 {
+	b.align(4, len(s)+1)
+	b.prepend(append([]byte(s), 0))
+	b.prependUint32(uint32(len(s)))
+	return b.offset()
+}
+
+func (b *fbBuilder) createOffsetVector(offsets []uint32) uint32 {
+	b.align(4, 4*len(offsets))
+	for i := len(offsets) - 1; i >= 0; i-- {
+		b.prependUOffset(offsets[i])
+	}
+	b.prependUint32(uint32(len(offsets)))
+	return b.offset()
+}
+
+// createStructVector prepends a vector of n structs that are already encoded
+// in data, the Arrow structs all have an 8 byte alignment.
+func (b *fbBuilder) createStructVector(n int, data []byte) uint32 {
+	b.align(8, len(data))
+	b.prepend(data)
+	b.prependUint32(uint32(n))
+	return b.offset()
+}
+
+func (b *fbBuilder) startTable(numFields int) {
+	b.fields = make([]uint32, numFields)
+	b.objectEnd = b.offset()
+}
+
+func (b *fbBuilder) addUint8(slot int, v uint8) {
+	b.prependUint8(v)
+	b.fields[slot] = b.offset()
+}
+
+func (b *fbBuilder) addInt16(slot int, v int16) {
+	b.prependUint16(uint16(v))
+	b.fields[slot] = b.offset()
+}
+
+func (b *fbBuilder) addInt32(slot int, v int32) {
+	b.prependUint32(uint32(v))
+	b.fields[slot] = b.offset()
+}
+
+func (b *fbBuilder) addInt64(slot int, v int64) {
+	b.prependUint64(uint64(v))
+	b.fields[slot] = b.offset()
+}
+
+func (b *fbBuilder) addOffset(slot int, off uint32) {
+	b.prependUOffset(off)
+	b.fields[slot] = b.offset()
+}
+
+// endTable writes the table start and its vtable, which is placed directly
+// in front of the table.
+func (b *fbBuilder) endTable() uint32 {
+	b.prependUint32(0)
+	table := b.offset()
+
+	n := len(b.fields)
+	for n > 0 && b.fields[n-1] == 0 {
+		n--
+	}
+	for i := n - 1; i >= 0; i-- {
+		var fieldOffset uint16
+		if b.fields[i] != 0 {
+			fieldOffset = uint16(table - b.fields[i])
+		}
+		b.prependUint16(fieldOffset)
+	}
+	b.prependUint16(uint16(table - b.objectEnd))
+	b.prependUint16(uint16((n + 2) * 2))
+
+	vtable := b.offset()
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-int(table):], vtable-table)
+	b.fields = nil
+	return table
+}
+
+func (b *fbBuilder) finish(root uint32) []byte {
+	b.align(b.minAlign, 4)
+	b.prependUOffset(root)
+	return b.buf
+}
+
+func arrowField(b *fbBuilder, name string, typeType uint8, children ...uint32) uint32 {
+	nameOffset := b.createString(name)
+
+	b.startTable(2)
+	if typeType == arrowTypeInt {
+		b.addInt32(0, 64)
+		b.addUint8(1, 1)
+	}
+	typeOffset := b.endTable()
+
+	childrenOffset := b.createOffsetVector(children)
+
+	b.startTable(6)
+	b.addOffset(0, nameOffset)
+	b.addOffset(3, typeOffset)
+	b.addOffset(5, childrenOffset)
+	b.addUint8(1, 0)
+	b.addUint8(2, typeType)
+	return b.endTable()
+}
+
+func arrowSchema(b *fbBuilder) uint32 {
+	item := arrowField(b, "item", arrowTypeUtf8)
+	fields := []uint32{
+		arrowField(b, "id", arrowTypeInt),
+		arrowField(b, "text", arrowTypeUtf8),
+		arrowField(b, "author", arrowTypeUtf8),
+		arrowField(b, "author_id", arrowTypeUtf8),
+		arrowField(b, "tags", arrowTypeList, item),
+		arrowField(b, "source", arrowTypeUtf8),
+		arrowField(b, "year", arrowTypeInt),
+		arrowField(b, "language", arrowTypeUtf8),
+		arrowField(b, "url", arrowTypeUtf8),
+		arrowField(b, "verified", arrowTypeBool),
+	}
+	fieldsOffset := b.createOffsetVector(fields)
+
+	b.startTable(2)
+	b.addOffset(1, fieldsOffset)
+	return b.endTable()
+}
+
+func arrowMessage(headerType uint8, bodyLength int64, header func(b *fbBuilder) uint32) []byte {
+	b := &fbBuilder{}
+	headerOffset := header(b)
+
+	b.startTable(4)
+	b.addInt64(3, bodyLength)
+	b.addOffset(2, headerOffset)
+	b.addInt16(0, arrowMetadataV5)
+	b.addUint8(1, headerType)
+	return b.finish(b.endTable())
+}
+
+// arrowBatch collects the body, field nodes and buffer locations of a record
+// batch. Columns are never null so validity buffers are left empty.
+type arrowBatch struct {
+	length  int
+	body    []byte
+	nodes   []byte
+	buffers []byte
+}
+
+func (ab *arrowBatch) addNode(length int) {
+	ab.nodes = binary.LittleEndian.AppendUint64(ab.nodes, uint64(length))
+	ab.nodes = binary.LittleEndian.AppendUint64(ab.nodes, 0)
+}
+
+func (ab *arrowBatch) addBuffer(data []byte) {
+	offset := len(ab.body)
+	ab.body = append(ab.body, data...)
+	for len(ab.body)%8 != 0 {
+		ab.body = append(ab.body, 0)
+	}
+	ab.buffers = binary.LittleEndian.AppendUint64(ab.buffers, uint64(offset))
+	ab.buffers = binary.LittleEndian.AppendUint64(ab.buffers, uint64(len(data)))
+}
+
+func (ab *arrowBatch) addStrings(values iter.Seq[string]) {
+	offsets := binary.LittleEndian.AppendUint32(nil, 0)
+	var data []byte
+	n := 0
+	for value := range values {
+		data = append(data, value...)
+		offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+		n++
+	}
+	ab.addNode(n)
+	ab.addBuffer(nil)
+	ab.addBuffer(offsets)
+	ab.addBuffer(data)
+}
+
+func (ab *arrowBatch) addInt64s(values iter.Seq[int64]) {
+	var data []byte
+	n := 0
+	for value := range values {
+		data = binary.LittleEndian.AppendUint64(data, uint64(value))
+		n++
+	}
+	ab.addNode(n)
+	ab.addBuffer(nil)
+	ab.addBuffer(data)
+}
+
+func newArrowBatch(quotes Quotes, start, end int) *arrowBatch {
+	ab := &arrowBatch{length: end - start}
+	column := func(value func(q *Quote) string) iter.Seq[string] {
+		return func(yield func(string) bool) {
+			for i := start; i < end; i++ {
+				if !yield(value(&quotes[i])) {
+					return
+				}
+			}
+		}
+	}
+
+	ab.addInt64s(func(yield func(int64) bool) {
+		for i := start; i < end; i++ {
+			if !yield(int64(i)) {
+				return
+			}
+		}
+	})
+
+	ab.addStrings(column(func(q *Quote) string { return q.Text }))
+	ab.addStrings(column(func(q *Quote) string { return q.Author }))
+	ab.addStrings(column(func(q *Quote) string { return url.QueryEscape(q.Author) }))
+
+	tagOffsets := binary.LittleEndian.AppendUint32(nil, 0)
+	tagCount := 0
+	for i := start; i < end; i++ {
+		tagCount += len(quotes[i].Tags)
+		tagOffsets = binary.LittleEndian.AppendUint32(tagOffsets, uint32(tagCount))
+	}
+	ab.addNode(ab.length)
+	ab.addBuffer(nil)
+	ab.addBuffer(tagOffsets)
+	ab.addStrings(func(yield func(string) bool) {
+		for i := start; i < end; i++ {
+			for _, tag := range quotes[i].Tags {
+				if !yield(tag) {
+					return
+				}
+			}
+		}
+	})
+
+	ab.addStrings(column(func(q *Quote) string { return q.Source }))
+	ab.addInt64s(func(yield func(int64) bool) {
+		for i := start; i < end; i++ {
+			if !yield(int64(quotes[i].Year)) {
+				return
+			}
+		}
+	})
+	ab.addStrings(column(func(q *Quote) string { return q.Language }))
+	ab.addStrings(column(func(q *Quote) string { return q.URL }))
+
+	// Booleans are a bitmap, least significant bit first.
+	verified := make([]byte, (ab.length+7)/8)
+	for i := start; i < end; i++ {
+		if quotes[i].Verified {
+			verified[(i-start)/8] |= 1 << ((i - start) % 8)
+		}
+	}
+	ab.addNode(ab.length)
+	ab.addBuffer(nil)
+	ab.addBuffer(verified)
+	return ab
+}
+
+func (ab *arrowBatch) header(b *fbBuilder) uint32 {
+	nodes := b.createStructVector(len(ab.nodes)/16, ab.nodes)
+	buffers := b.createStructVector(len(ab.buffers)/16, ab.buffers)
+
+	b.startTable(3)
+	b.addInt64(0, int64(ab.length))
+	b.addOffset(1, nodes)
+	b.addOffset(2, buffers)
+	return b.endTable()
+}
+
+type arrowWriter struct {
+	w      io.Writer
+	pos    int64
+	blocks []byte
+	err    error
+}
+
+func (aw *arrowWriter) write(p []byte) {
+	if aw.err != nil {
+		return
+	}
+	n, err := aw.w.Write(p)
+	aw.pos += int64(n)
+	aw.err = err
+}
+
+// writeMessage writes an encapsulated message and returns the length of its
+// metadata including the prefix and padding.
+func (aw *arrowWriter) writeMessage(metadata, body []byte) int32 {
+	pad := (8 - len(metadata)%8) % 8
+	prefix := binary.LittleEndian.AppendUint32(nil, 0xFFFFFFFF)
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(metadata)+pad))
+
+	aw.write(prefix)
+	aw.write(metadata)
+	aw.write(make([]byte, pad))
+	aw.write(body)
+	return int32(len(prefix) + len(metadata) + pad)
+}
+
+func writeArrow(w io.Writer, quotes Quotes, file bool) error {
+	aw := &arrowWriter{w: w}
+	if file {
+		aw.write(arrowMagic)
+		aw.write([]byte{0, 0})
+	}
+
+	aw.writeMessage(arrowMessage(arrowHeaderSchema, 0, arrowSchema), nil)
+
+	for start := 0; start < len(quotes); start += arrowBatchSize {
+		end := min(start+arrowBatchSize, len(quotes))
+		batch := newArrowBatch(quotes, start, end)
+
+		offset := aw.pos
+		metadataLength := aw.writeMessage(arrowMessage(arrowHeaderRecordBatch, int64(len(batch.body)), batch.header), batch.body)
+
+		aw.blocks = binary.LittleEndian.AppendUint64(aw.blocks, uint64(offset))
+		aw.blocks = binary.LittleEndian.AppendUint32(aw.blocks, uint32(metadataLength))
+		aw.blocks = binary.LittleEndian.AppendUint32(aw.blocks, 0)
+		aw.blocks = binary.LittleEndian.AppendUint64(aw.blocks, uint64(len(batch.body)))
+	}
+
+	// End of stream marker
+	aw.write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+
+	if file {
+		b := &fbBuilder{}
+		schema := arrowSchema(b)
+		dictionaries := b.createStructVector(0, nil)
+		recordBatches := b.createStructVector(len(aw.blocks)/24, aw.blocks)
+
+		b.startTable(4)
+		b.addOffset(1, schema)
+		b.addOffset(2, dictionaries)
+		b.addOffset(3, recordBatches)
+		b.addInt16(0, arrowMetadataV5)
+		footer := b.finish(b.endTable())
+
+		aw.write(footer)
+		aw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+		aw.write(arrowMagic)
+	}
+	return aw.err
+}
+
+// WriteArrowFile writes the quotes in the Arrow IPC file format, also known
+// as Feather v2.
+func WriteArrowFile(w io.Writer, quotes Quotes) error {
+	return writeArrow(w, quotes, true)
+}
+
+// WriteArrowStream writes the quotes in the Arrow IPC streaming format.
+func WriteArrowStream(w io.Writer, quotes Quotes) error {
+	return writeArrow(w, quotes, false)
+}
+
+// SaveQuotesToArrow saves quotes to an Arrow IPC file
+func SaveQuotesToArrow(quotes Quotes, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %v", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := WriteArrowFile(writer, quotes); err != nil {
+		return fmt.Errorf("error writing arrow file: %v", err)
+	}
+	return writer.Flush()
+}
+
+var arrowExportFormats = map[string]struct {
+	contentType string
+	filename    string
+	file        bool
+}{
+	"arrow":  {"application/vnd.apache.arrow.file", "quotes.arrow", true},
+	"arrows": {"application/vnd.apache.arrow.stream", "quotes.arrows", false},
+}
+
+// ExportHandler serves the whole corpus as a single Arrow table, the file
+// format by default and the streaming format with format=arrows.
+func (api *API) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "arrow"
+	}
+	export, ok := arrowExportFormats[format]
+	if !ok {
+		returnError(w, "json", http.StatusBadRequest, "Unsupported export format", "Supported formats are arrow and arrows")
+		return
+	}
+
+	w.Header().Set("Content-Type", export.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.filename))
+	liftDeadlines(w)
+
+	bufWriter := bufio.NewWriterSize(w, 64*1024)
+	if err := writeArrow(bufWriter, api.Quotes, export.file); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := bufWriter.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// fbField returns the position of a field of the table at pos, or 0 when the
+// field is not present.
+func fbField(buf []byte, pos int, slot int) int {
+	vtable := pos - int(int32(binary.LittleEndian.Uint32(buf[pos:])))
+	vtableSize := int(binary.LittleEndian.Uint16(buf[vtable:]))
+	entry := 4 + 2*slot
+	if entry >= vtableSize {
+		return 0
+	}
+	fieldOffset := int(binary.LittleEndian.Uint16(buf[vtable+entry:]))
+	if fieldOffset == 0 {
+		return 0
+	}
+	return pos + fieldOffset
+}
+
+type arrowTestMessage struct {
+	headerType uint8
+	length     int64
+	body       []byte
+}
+
+// readArrowStream walks the encapsulated messages of an IPC stream.
+func readArrowStream(t *testing.T, data []byte) []arrowTestMessage {
+	t.Helper()
+	var messages []arrowTestMessage
+	for {
+		if binary.LittleEndian.Uint32(data) != 0xFFFFFFFF {
+			t.Fatalf("missing continuation marker")
+		}
+		size := int(binary.LittleEndian.Uint32(data[4:]))
+		if size == 0 {
+			return messages
+		}
+		metadata := data[8 : 8+size]
+		message := int(binary.LittleEndian.Uint32(metadata))
+
+		var m arrowTestMessage
+		m.headerType = metadata[fbField(metadata, message, 1)]
+		bodyLength := int(binary.LittleEndian.Uint64(metadata[fbField(metadata, message, 3):]))
+		if m.headerType == arrowHeaderRecordBatch {
+			header := fbField(metadata, message, 2)
+			header += int(binary.LittleEndian.Uint32(metadata[header:]))
+			m.length = int64(binary.LittleEndian.Uint64(metadata[fbField(metadata, header, 0):]))
+		}
+		m.body = data[8+size : 8+size+bodyLength]
+		messages = append(messages, m)
+		data = data[8+size+bodyLength:]
+	}
+}
+
+func TestArrowStream(t *testing.T) {
+	rec := serveTestRequest(newTestAPI(testQuotes), "/export?format=arrows")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.apache.arrow.stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	messages := readArrowStream(t, rec.Body.Bytes())
+	if len(messages) != 2 || messages[0].headerType != arrowHeaderSchema || messages[1].headerType != arrowHeaderRecordBatch {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	if messages[1].length != int64(len(testQuotes)) {
+		t.Errorf("record batch length = %d, want %d", messages[1].length, len(testQuotes))
+	}
+
+	body := messages[1].body
+	for _, want := range []string{"Ann ExampleBob ExampleAnn Example", "Ann+ExampleBob+Example", "foodlovelovelife"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("record batch body is missing %q", want)
+		}
+	}
+}
+
+func TestArrowFile(t *testing.T) {
+	rec := serveTestRequest(newTestAPI(testQuotes), "/export")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.apache.arrow.file" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	data := rec.Body.Bytes()
+	if !bytes.HasPrefix(data, []byte("ARROW1\x00\x00")) || !bytes.HasSuffix(data, []byte("ARROW1")) {
+		t.Fatalf("missing Arrow magic")
+	}
+
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+	footer := data[len(data)-10-footerLength : len(data)-10]
+	root := int(binary.LittleEndian.Uint32(footer))
+	recordBatches := fbField(footer, root, 3)
+	recordBatches += int(binary.LittleEndian.Uint32(footer[recordBatches:]))
+	if n := binary.LittleEndian.Uint32(footer[recordBatches:]); n != 1 {
+		t.Fatalf("footer lists %d record batches, want 1", n)
+	}
+
+	offset := binary.LittleEndian.Uint64(footer[recordBatches+4:])
+	messages := readArrowStream(t, data[offset:])
+	if len(messages) != 1 || messages[0].length != int64(len(testQuotes)) {
+		t.Errorf("footer block does not point at the record batch: %+v", messages)
+	}
+}
+
+func TestArrowBatches(t *testing.T) {
+	quotes := make(Quotes, arrowBatchSize+1)
+	for i := range quotes {
+		quotes[i] = Quote{Text: "text", Author: "author", Tags: []string{"tag"}}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArrowStream(&buf, quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := readArrowStream(t, buf.Bytes())
+	if len(messages) != 3 || messages[1].length != arrowBatchSize || messages[2].length != 1 {
+		t.Errorf("unexpected record batches: %d messages", len(messages))
+	}
+}
+
+func TestExportUnsupportedFormat(t *testing.T) {
+	rec := serveTestRequest(newTestAPI(testQuotes), "/export?format=parquet")
+	if rec.Code != 400 {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go_quote/client"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiItalic = "\x1b[3m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+
+	defaultTerminalWidth = 80
+)
+
+// runQuoteCommand implements `go_quote quote`, a fortune replacement that
+// prints a random quote from a data file or a remote server.
+func runQuoteCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("quote", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	filename := flags.String("file", "data/quotes.bytesz", "Path of the quotes file")
+	storage := flags.String("storage", "bytesz", "Storage type of the quotes file")
+	server := flags.String("server", "", "Base URL of a go_quote server, used instead of the file")
+	tag := flags.String("tag", "", "Only pick quotes with this tag")
+	author := flags.String("author", "", "Only pick quotes by this author")
+	format := flags.String("format", "text", "Output format, text or markdown")
+	width := flags.Int("width", 0, "Wrap lines at this width, 0 uses $COLUMNS or 80")
+	color := flags.String("color", "auto", "Colour output: auto, always or never")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *format != "text" && *format != "markdown" {
+		fmt.Fprintf(stderr, "unsupported format %q, expected text or markdown\n", *format)
+		return 2
+	}
+	if *tag != "" && *author != "" {
+		fmt.Fprintln(stderr, "use either -tag or -author")
+		return 2
+	}
+
+	var quote ResponseQuote
+	var err error
+	if *server != "" {
+		quote, err = remoteRandomQuote(*server, *tag, *author)
+	} else {
+		quote, err = localRandomQuote(*filename, *storage, *tag, *author)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	useColor := *color == "always" || *color == "auto" && isTerminal(stdout) && os.Getenv("NO_COLOR") == ""
+	fmt.Fprint(stdout, renderQuote(quote, *format, terminalWidth(*width), useColor))
+	return 0
+}
+
+func localRandomQuote(filename, storage, tag, author string) (ResponseQuote, error) {
+	quotes, err := LoadQuotes(filename, storage)
+	if err != nil {
+		return ResponseQuote{}, fmt.Errorf("error loading quotes: %v", err)
+	}
+
+	var ids []int
+	for i, quote := range quotes {
+		switch {
+		case tag != "":
+			for _, t := range quote.Tags {
+				if strings.EqualFold(strings.TrimSpace(t), tag) {
+					ids = append(ids, i)
+					break
+				}
+			}
+		case author != "":
+			if strings.EqualFold(quote.Author, author) {
+				ids = append(ids, i)
+			}
+		default:
+			ids = append(ids, i)
+		}
+	}
+	if len(ids) == 0 {
+		return ResponseQuote{}, fmt.Errorf("no quotes found")
+	}
+	id := ids[rand.Intn(len(ids))]
+	return quotes[id].CreateResponseQuote(id), nil
+}
+
+// remoteRandomQuote asks the server for the number of matching quotes and
+// then fetches a random one of them as a page of one.
+func remoteRandomQuote(server, tag, author string) (ResponseQuote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := client.New(server)
+
+	page := func(opts client.ListOptions) (*client.QuotePage, error) {
+		if tag != "" {
+			return c.TagQuotes(ctx, tag, opts)
+		}
+		authorPage, err := c.AuthorQuotes(ctx, url.QueryEscape(author), opts)
+		if err != nil {
+			return nil, err
+		}
+		return &client.QuotePage{Quotes: authorPage.Quotes, Pagination: authorPage.Pagination}, nil
+	}
+
+	var quote *client.Quote
+	var err error
+	if tag == "" && author == "" {
+		quote, err = c.RandomQuote(ctx)
+	} else {
+		var first *client.QuotePage
+		first, err = page(client.ListOptions{PageSize: 1})
+		if err == nil && first.Pagination.Total > 0 {
+			var random *client.QuotePage
+			random, err = page(client.ListOptions{Page: rand.Intn(first.Pagination.Total) + 1, PageSize: 1})
+			if err == nil && len(random.Quotes) > 0 {
+				quote = &random.Quotes[0]
+			}
+		}
+	}
+	if err != nil && !client.IsNotFound(err) {
+		return ResponseQuote{}, err
+	}
+	if quote == nil {
+		return ResponseQuote{}, fmt.Errorf("no quotes found")
+	}
+
+	return ResponseQuote{
+		Quote:    Quote{Text: quote.Text, Author: quote.Author, Tags: quote.Tags},
+		ID:       quote.ID,
+		AuthorID: quote.AuthorID,
+	}, nil
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func terminalWidth(width int) int {
+	if width > 0 {
+		return width
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultTerminalWidth
+}
+
+// wrapLine breaks line at spaces so no line is wider than width, words
+// longer than width get a line of their own. Continuation lines start with
+// indent.
+func wrapLine(line string, width int, indent string) []string {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return []string{line}
+	}
+
+	var lines []string
+	current := words[0]
+	for _, word := range words[1:] {
+		if utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, current)
+			current = indent + word
+			continue
+		}
+		current += " " + word
+	}
+	return append(lines, current)
+}
+
+// renderQuote formats the quote with the text or markdown formatter, wraps it
+// to width and optionally colours it for a terminal.
+func renderQuote(quote ResponseQuote, format string, width int, color bool) string {
+	formatted := quoteToText(quote)
+	if format == "markdown" {
+		formatted = quoteToMarkdown(quote) + "\n"
+	}
+
+	paint := func(style, s string) string {
+		if !color || s == "" {
+			return s
+		}
+		return style + s + ansiReset
+	}
+
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(formatted, "\n"), "\n") {
+		var indent, label, style string
+		switch {
+		case format == "markdown" && strings.HasPrefix(line, "> "):
+			indent, style = "> ", ansiItalic
+		case format == "markdown" && strings.HasPrefix(line, "— "):
+			style = ansiBold + ansiYellow
+		case format == "markdown":
+			style = ansiDim
+		default:
+			if name, _, ok := strings.Cut(line, ": "); ok {
+				label = name + ": "
+				indent = strings.Repeat(" ", utf8.RuneCountInString(label))
+			}
+			switch label {
+			case "Quote: ":
+				style = ansiBold
+			case "Author: ":
+				style = ansiYellow
+			default:
+				style = ansiDim
+			}
+		}
+
+		for i, wrapped := range wrapLine(line, width, indent) {
+			if rest, ok := strings.CutPrefix(wrapped, label); i == 0 && label != "" && ok {
+				sb.WriteString(paint(ansiCyan, label) + paint(style, rest))
+			} else if i == 0 && label != "" {
+				sb.WriteString(paint(ansiCyan, wrapped))
+			} else {
+				sb.WriteString(paint(style, wrapped))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderQuoteWrap(t *testing.T) {
+	quote := ResponseQuote{
+		Quote: Quote{Text: "The quick brown fox jumps over the lazy dog", Author: "Ann Example", Tags: []string{"animals"}},
+		ID:    7,
+	}
+
+	want := "Quote: The quick\n       brown fox\n       jumps over\n       the lazy dog\nAuthor: Ann Example\nTags: animals\nID: 7\n"
+	if got := renderQuote(quote, "text", 20, false); got != want {
+		t.Errorf("text:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = "> The quick brown\n> fox jumps over the\n> lazy dog\n\n— Ann Example\n\nQuote ID: 7\n\n#animals\n"
+	if got := renderQuote(quote, "markdown", 20, false); got != want {
+		t.Errorf("markdown:\n%s\nwant:\n%s", got, want)
+	}
+
+	got := renderQuote(quote, "text", 80, true)
+	if !strings.HasPrefix(got, ansiCyan+"Quote: "+ansiReset+ansiBold+"The quick") {
+		t.Errorf("missing colour: %q", got)
+	}
+}
+
+func TestQuoteCommand(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "quotes.bytesz")
+	if err := SaveQuotes(testQuotes, filename, "bytesz"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runQuoteCommand([]string{"-file", filename, "-author", "bob example", "-color", "never"}, &stdout, &stderr)
+	if code != 0 || stdout.String() != "Quote: Second quote\nAuthor: Bob Example\nTags: love\nID: 1\n" {
+		t.Errorf("local: exit %d, stdout %q, stderr %q", code, stdout.String(), stderr.String())
+	}
+
+	api := newTestAPI(testQuotes)
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	stdout.Reset()
+	code = runQuoteCommand([]string{"-server", server.URL, "-tag", "life", "-format", "markdown"}, &stdout, &stderr)
+	if code != 0 || !strings.HasPrefix(stdout.String(), "> Third quote\n\n— Ann Example\n") {
+		t.Errorf("remote: exit %d, stdout %q, stderr %q", code, stdout.String(), stderr.String())
+	}
+
+	stderr.Reset()
+	code = runQuoteCommand([]string{"-server", server.URL, "-tag", "missing"}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "no quotes found") {
+		t.Errorf("missing tag: exit %d, stderr %q", code, stderr.String())
+	}
+}
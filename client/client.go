@@ -0,0 +1,660 @@
+// Package client is the Go client of the Go-quote API.
+//
+//	c := client.New("http://127.0.0.1:8000")
+//	quote, err := c.Quote(ctx, 42)
+//
+//	for quote, err := range c.AllTagQuotes(ctx, "love", client.ListOptions{PageSize: 100}) {
+//		...
+//	}
+//
+// Typed methods request JSON, Format fetches any other output format as raw
+// bytes. Requests are retried on network errors, 429 and 5xx responses, and
+// gzip responses are decoded transparently. Dial opens a session on the /ws
+// endpoint.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultRetryWait  = 200 * time.Millisecond
+	maxRetryWait      = 10 * time.Second
+)
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// MaxRetries is the number of times a failed request is retried.
+	MaxRetries int
+	// RetryWait is the wait before the first retry, doubled on every next
+	// one. A Retry-After header from the server takes precedence.
+	RetryWait time.Duration
+	// Gzip asks the server for gzip compressed responses.
+	Gzip      bool
+	UserAgent string
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: DefaultMaxRetries,
+		RetryWait:  DefaultRetryWait,
+		Gzip:       true,
+		UserAgent:  "go_quote-client",
+	}
+}
+
+type Quote struct {
+	ID       int      `json:"id"`
+	Text     string   `json:"text"`
+	Author   string   `json:"author"`
+	AuthorID string   `json:"author_id"`
+	Tags     []string `json:"tags"`
+	Source   string   `json:"source,omitempty"`
+	Year     int      `json:"year,omitempty"`
+	Language string   `json:"language,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Verified bool     `json:"verified,omitempty"`
+}
+
+type Pagination struct {
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Total      int    `json:"total"`
+	Pages      int    `json:"pages"`
+	Next       string `json:"next,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type QuotePage struct {
+	Quotes     []Quote    `json:"quotes"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type AuthorQuotePage struct {
+	Author      string     `json:"author"`
+	AuthorID    string     `json:"author_id"`
+	TotalQuotes int        `json:"total_quotes"`
+	Quotes      []Quote    `json:"quotes"`
+	Pagination  Pagination `json:"pagination"`
+}
+
+type Author struct {
+	Name        string `json:"name"`
+	AuthorID    string `json:"author_id"`
+	TotalQuotes int    `json:"total_quotes"`
+}
+
+type AuthorPage struct {
+	Authors    []Author   `json:"authors"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	TagID       string `json:"tag_id"`
+	TotalQuotes int    `json:"total_quotes"`
+}
+
+type TagPage struct {
+	Tags       []Tag      `json:"tags"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type StatsCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type StatsBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+type StatsSummary struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median int     `json:"median"`
+}
+
+type StatsTagPair struct {
+	Tags  [2]string `json:"tags"`
+	Count int       `json:"count"`
+}
+
+type HealthTTS struct {
+	Backend   string `json:"backend"`
+	Available bool   `json:"available"`
+}
+
+type HealthDataset struct {
+	Version string    `json:"version,omitempty"`
+	Updated time.Time `json:"updated"`
+	Quotes  int       `json:"quotes"`
+}
+
+// Health is the body of the /healthz and /readyz probes.
+type Health struct {
+	Status  string         `json:"status"`
+	Dataset *HealthDataset `json:"dataset,omitempty"`
+	TTS     *HealthTTS     `json:"tts,omitempty"`
+}
+
+// Stats are the corpus statistics of /stats.
+type Stats struct {
+	Quotes          int            `json:"quotes"`
+	Authors         int            `json:"authors"`
+	Tags            int            `json:"tags"`
+	TextLength      StatsSummary   `json:"text_length"`
+	LengthHistogram []StatsBucket  `json:"length_histogram"`
+	WordHistogram   []StatsBucket  `json:"word_histogram"`
+	TopAuthors      []StatsCount   `json:"top_authors"`
+	TopTags         []StatsCount   `json:"top_tags"`
+	TagCooccurrence []StatsTagPair `json:"tag_cooccurrence"`
+	QuotesPerAuthor []StatsBucket  `json:"quotes_per_author"`
+}
+
+// ListOptions selects a page. Cursor pagination is used when Cursor is set,
+// Page is ignored then.
+type ListOptions struct {
+	Page     int
+	PageSize int
+	Cursor   string
+	// Fields limits the quote fields in the response, empty returns all.
+	Fields []string
+	// Lang limits /quotes to a language, such as "es". It is ignored by the
+	// other lists.
+	Lang string
+}
+
+func (o ListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Cursor != "" {
+		query.Set("cursor", o.Cursor)
+	} else if o.Page > 0 {
+		query.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	if len(o.Fields) > 0 {
+		query.Set("fields", strings.Join(o.Fields, ","))
+	}
+	if o.Lang != "" {
+		query.Set("lang", o.Lang)
+	}
+	return query
+}
+
+// Error is a non 2xx response, with the fields of the API's error body.
+type Error struct {
+	StatusCode int    `json:"status"`
+	Message    string `json:"message"`
+	Err        string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != "" {
+		return fmt.Sprintf("go_quote: %d %s: %s", e.StatusCode, e.Message, e.Err)
+	}
+	return fmt.Sprintf("go_quote: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500 && status != http.StatusNotImplemented
+}
+
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxRetryWait)
+		}
+	}
+	return min(c.RetryWait<<attempt, maxRetryWait)
+}
+
+// do sends the request, retrying failures, and returns a successful
+// response with a body that is already decompressed.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, reader)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if c.UserAgent != "" {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+		if c.Gzip {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		if err == nil && !retryable(resp.StatusCode) {
+			if resp.StatusCode >= 300 {
+				defer resp.Body.Close()
+				return nil, readError(resp)
+			}
+			if err := decompress(resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		if attempt >= c.MaxRetries || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			return nil, readError(resp)
+		}
+
+		wait := c.backoff(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// decompress replaces the body of a gzip response. Go's transport only does
+// this itself when it added the Accept-Encoding header.
+func decompress(resp *http.Response) error {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{gr, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	return nil
+}
+
+func readError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if decompress(resp) == nil {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		json.Unmarshal(body, apiErr)
+	}
+	apiErr.StatusCode = resp.StatusCode
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v any) error {
+	header := http.Header{"Accept": {"application/json"}}
+	resp, err := c.do(ctx, http.MethodGet, path, query, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *Client) getBytes(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) Quote(ctx context.Context, id int) (*Quote, error) {
+	var quote Quote
+	if err := c.getJSON(ctx, "/quotes/"+strconv.Itoa(id), nil, &quote); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// Translations returns the translations of a quote, the quote itself is not
+// included.
+func (c *Client) Translations(ctx context.Context, id int, opts ListOptions) (*QuotePage, error) {
+	var page QuotePage
+	if err := c.getJSON(ctx, "/quotes/"+strconv.Itoa(id)+"/translations", opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Similar returns the quotes most similar to a quote, best first.
+func (c *Client) Similar(ctx context.Context, id int, opts ListOptions) (*QuotePage, error) {
+	var page QuotePage
+	if err := c.getJSON(ctx, "/quotes/"+strconv.Itoa(id)+"/similar", opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *Client) RandomQuote(ctx context.Context) (*Quote, error) {
+	var quote Quote
+	if err := c.getJSON(ctx, "/random-quote", nil, &quote); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+func (c *Client) Quotes(ctx context.Context, opts ListOptions) (*QuotePage, error) {
+	var page QuotePage
+	if err := c.getJSON(ctx, "/quotes", opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *Client) Authors(ctx context.Context, opts ListOptions) (*AuthorPage, error) {
+	var page AuthorPage
+	if err := c.getJSON(ctx, "/authors", opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// AuthorQuotes lists the quotes of an author, authorID is Quote.AuthorID.
+func (c *Client) AuthorQuotes(ctx context.Context, authorID string, opts ListOptions) (*AuthorQuotePage, error) {
+	var page AuthorQuotePage
+	if err := c.getJSON(ctx, "/authors/"+authorID, opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *Client) Tags(ctx context.Context, opts ListOptions) (*TagPage, error) {
+	var page TagPage
+	if err := c.getJSON(ctx, "/tags", opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *Client) TagQuotes(ctx context.Context, tag string, opts ListOptions) (*QuotePage, error) {
+	var page QuotePage
+	if err := c.getJSON(ctx, "/tags/"+url.PathEscape(tag), opts.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Query returns the quotes matching a query of the /query language, such as
+// "tag:science AND len<100".
+func (c *Client) Query(ctx context.Context, q string, opts ListOptions) (*QuotePage, error) {
+	query := opts.values()
+	query.Set("q", q)
+	var page QuotePage
+	if err := c.getJSON(ctx, "/query", query, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// SemanticSearch returns the k quotes nearest to a quote in the embedding
+// space, best first. A k of 0 uses the server default. Semantic results
+// only support page pagination.
+func (c *Client) SemanticSearch(ctx context.Context, id, k int, opts ListOptions) (*QuotePage, error) {
+	query := opts.values()
+	query.Set("id", strconv.Itoa(id))
+	if k > 0 {
+		query.Set("k", strconv.Itoa(k))
+	}
+	var page QuotePage
+	if err := c.getJSON(ctx, "/search/semantic", query, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// SemanticSearchVector returns the k quotes nearest to an embedding vector,
+// which has the dimensions of the server's embeddings.
+func (c *Client) SemanticSearchVector(ctx context.Context, vector []float32, k int, opts ListOptions) (*QuotePage, error) {
+	query := opts.values()
+	if k > 0 {
+		query.Set("k", strconv.Itoa(k))
+	}
+	body, err := json.Marshal(struct {
+		Vector []float32 `json:"vector"`
+	}{vector})
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{"Accept": {"application/json"}, "Content-Type": {"application/json"}}
+	resp, err := c.do(ctx, http.MethodPost, "/search/semantic", query, header, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var page QuotePage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if err := c.getJSON(ctx, "/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// nextQuery applies Pagination.Next, a relative query such as
+// "?page=2&page_size=10", to the query of the current page. Other parameters
+// such as fields are kept.
+func nextQuery(query url.Values, next string) (url.Values, bool) {
+	if next == "" {
+		return nil, false
+	}
+	nextValues, err := url.ParseQuery(strings.TrimPrefix(next, "?"))
+	if err != nil {
+		return nil, false
+	}
+	merged := url.Values{}
+	for key, values := range query {
+		if key != "page" && key != "cursor" {
+			merged[key] = values
+		}
+	}
+	for key, values := range nextValues {
+		merged[key] = values
+	}
+	return merged, true
+}
+
+// paginate yields the items of every page, following Pagination.Next until
+// the last page.
+func paginate[P any, T any](ctx context.Context, c *Client, path string, opts ListOptions, items func(*P) ([]T, Pagination)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		query := opts.values()
+		for {
+			var page P
+			if err := c.getJSON(ctx, path, query, &page); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			list, pagination := items(&page)
+			for _, item := range list {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			var ok bool
+			if query, ok = nextQuery(query, pagination.Next); !ok || len(list) == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) AllQuotes(ctx context.Context, opts ListOptions) iter.Seq2[Quote, error] {
+	return paginate(ctx, c, "/quotes", opts, func(p *QuotePage) ([]Quote, Pagination) {
+		return p.Quotes, p.Pagination
+	})
+}
+
+func (c *Client) AllAuthors(ctx context.Context, opts ListOptions) iter.Seq2[Author, error] {
+	return paginate(ctx, c, "/authors", opts, func(p *AuthorPage) ([]Author, Pagination) {
+		return p.Authors, p.Pagination
+	})
+}
+
+func (c *Client) AllAuthorQuotes(ctx context.Context, authorID string, opts ListOptions) iter.Seq2[Quote, error] {
+	return paginate(ctx, c, "/authors/"+authorID, opts, func(p *AuthorQuotePage) ([]Quote, Pagination) {
+		return p.Quotes, p.Pagination
+	})
+}
+
+func (c *Client) AllTags(ctx context.Context, opts ListOptions) iter.Seq2[Tag, error] {
+	return paginate(ctx, c, "/tags", opts, func(p *TagPage) ([]Tag, Pagination) {
+		return p.Tags, p.Pagination
+	})
+}
+
+func (c *Client) AllTagQuotes(ctx context.Context, tag string, opts ListOptions) iter.Seq2[Quote, error] {
+	return paginate(ctx, c, "/tags/"+url.PathEscape(tag), opts, func(p *QuotePage) ([]Quote, Pagination) {
+		return p.Quotes, p.Pagination
+	})
+}
+
+// Healthz asks the liveness probe, which answers as soon as the server
+// listens.
+func (c *Client) Healthz(ctx context.Context) (*Health, error) {
+	return c.health(ctx, "/healthz")
+}
+
+// Readyz asks the readiness probe. While the quotes are loading it returns
+// the health with status "loading" together with a 503 *Error.
+func (c *Client) Readyz(ctx context.Context) (*Health, error) {
+	return c.health(ctx, "/readyz")
+}
+
+// health is not retried, a probe that is not ready answers 503 and that is
+// the answer.
+func (c *Client) health(ctx context.Context, path string) (*Health, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var health Health
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		}
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return &health, &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode), Err: health.Status}
+	}
+	return &health, nil
+}
+
+// Metrics returns the Prometheus text exposition of /metrics, the server
+// only serves it when metrics are enabled.
+func (c *Client) Metrics(ctx context.Context) (string, error) {
+	body, err := c.getBytes(ctx, "/metrics", nil)
+	return string(body), err
+}
+
+// Format fetches path in one of the API's output formats, such as xml,
+// yaml, csv, protobuf or msgpack, and returns the raw body.
+func (c *Client) Format(ctx context.Context, format string, path string, query url.Values) ([]byte, error) {
+	values := url.Values{}
+	for key, v := range query {
+		values[key] = v
+	}
+	values.Set("format", format)
+	return c.getBytes(ctx, path, values)
+}
+
+// Feed returns the quote of the day feed, format is atom, rss or jsonfeed.
+func (c *Client) Feed(ctx context.Context, format string, days int) ([]byte, error) {
+	query := url.Values{"format": {format}}
+	if days > 0 {
+		query.Set("page_size", strconv.Itoa(days))
+	}
+	return c.getBytes(ctx, "/feed", query)
+}
+
+// Export returns the whole corpus as an Arrow table, format is arrow for
+// the IPC file format or arrows for the stream format. The caller closes the
+// reader.
+func (c *Client) Export(ctx context.Context, format string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/export", url.Values{"format": {format}}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) ProtoSchema(ctx context.Context) (string, error) {
+	body, err := c.getBytes(ctx, "/schema/quotes.proto", nil)
+	return string(body), err
+}
+
+func (c *Client) GraphQLSchema(ctx context.Context) (string, error) {
+	body, err := c.getBytes(ctx, "/graphql/schema", nil)
+	return string(body), err
+}
+
+// Swagger returns the OpenAPI document, the server only serves it when
+// swagger is enabled.
+func (c *Client) Swagger(ctx context.Context) ([]byte, error) {
+	return c.getBytes(ctx, "/swagger.json", nil)
+}
@@ -0,0 +1,350 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StreamOptions struct {
+	// Interval between quotes, the server default is used when zero.
+	Interval time.Duration
+	Tag      string
+	// Seed makes the sequence reproducible and resumable, see LastEventID.
+	Seed *int64
+	// LastEventID resumes a seeded stream after this event.
+	LastEventID string
+}
+
+type StreamEvent struct {
+	ID    string
+	Quote Quote
+}
+
+// StreamRandom follows the Server-Sent Events stream of random quotes until
+// ctx is cancelled or the connection ends. Errors end the sequence.
+func (c *Client) StreamRandom(ctx context.Context, opts StreamOptions) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		query := url.Values{}
+		if opts.Interval > 0 {
+			query.Set("interval", opts.Interval.String())
+		}
+		if opts.Tag != "" {
+			query.Set("tag", opts.Tag)
+		}
+		if opts.Seed != nil {
+			query.Set("seed", strconv.FormatInt(*opts.Seed, 10))
+		}
+		header := http.Header{"Accept": {"text/event-stream"}}
+		if opts.LastEventID != "" {
+			header.Set("Last-Event-ID", opts.LastEventID)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, "/stream/random", query, header, nil)
+		if err != nil {
+			yield(StreamEvent{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		var event StreamEvent
+		var data strings.Builder
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+
+			switch field {
+			case "id":
+				event.ID = value
+			case "data":
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(value)
+			case "":
+				if line != "" || data.Len() == 0 {
+					continue
+				}
+				err := json.Unmarshal([]byte(data.String()), &event.Quote)
+				if !yield(event, err) || err != nil {
+					return
+				}
+				event = StreamEvent{}
+				data.Reset()
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			yield(StreamEvent{}, err)
+		}
+	}
+}
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal = 1000
+
+	// wsMaxMessageSize bounds the messages read from the server, a quote
+	// is a few hundred bytes.
+	wsMaxMessageSize = 1 << 20
+)
+
+var errWSProtocol = errors.New("go_quote: websocket protocol error")
+
+// Session is a WebSocket session on /ws. A session is not safe for
+// concurrent use.
+type Session struct {
+	conn   io.ReadWriteCloser
+	reader *bufio.Reader
+	stop   func() bool
+}
+
+type wsCommand struct {
+	Cmd    string `json:"cmd"`
+	ID     *int   `json:"id,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Author string `json:"author,omitempty"`
+}
+
+type wsResponse struct {
+	Cmd   string `json:"cmd"`
+	Quote *Quote `json:"quote"`
+	Error *Error `json:"error"`
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Dial opens a WebSocket session on /ws. The session ends when ctx is
+// cancelled or Close is called.
+func (c *Client) Dial(ctx context.Context) (*Session, error) {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	header := http.Header{
+		"Connection":            {"Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Key":     {key},
+		"Sec-Websocket-Version": {"13"},
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/ws", nil, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		resp.Body.Close()
+		return nil, &Error{StatusCode: resp.StatusCode, Message: "Not a WebSocket handshake"}
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		conn.Close()
+		return nil, errWSProtocol
+	}
+
+	s := &Session{conn: conn, reader: bufio.NewReader(conn)}
+	s.stop = context.AfterFunc(ctx, func() { conn.Close() })
+	return s, nil
+}
+
+// Next returns a random quote, limited to a tag or an author when one of
+// them is set. author is Quote.AuthorID.
+func (s *Session) Next(tag, author string) (*Quote, error) {
+	return s.command(wsCommand{Cmd: "next", Tag: tag, Author: author})
+}
+
+// Get returns the quote with the given ID.
+func (s *Session) Get(id int) (*Quote, error) {
+	return s.command(wsCommand{Cmd: "get", ID: &id})
+}
+
+// Close ends the session with a close frame.
+func (s *Session) Close() error {
+	s.stop()
+	s.writeFrame(wsOpClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	return s.conn.Close()
+}
+
+// command sends a command and waits for its response, the server answers
+// every command in order. Failed commands return an *Error.
+func (s *Session) command(command wsCommand) (*Quote, error) {
+	payload, err := json.Marshal(command)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.writeFrame(wsOpText, payload); err != nil {
+		return nil, err
+	}
+	message, err := s.readMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	var response wsResponse
+	if err := json.Unmarshal(message, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	if response.Quote == nil {
+		return nil, errWSProtocol
+	}
+	return response.Quote, nil
+}
+
+// writeFrame writes a single frame, client frames are always masked.
+func (s *Session) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(n))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, 0x80|127), uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+// readMessage returns the next data message, answering pings on the way. A
+// close frame from the server ends the session with io.EOF.
+func (s *Session) readMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(s.reader, header[:]); err != nil {
+			return nil, err
+		}
+		fin := header[0]&0x80 != 0
+		opcode := header[0] & 0x0F
+		if header[1]&0x80 != 0 {
+			return nil, errWSProtocol
+		}
+
+		length := uint64(header[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(s.reader, ext[:]); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(s.reader, ext[:]); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		if length > wsMaxMessageSize || uint64(len(message))+length > wsMaxMessageSize {
+			return nil, errWSProtocol
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(s.reader, payload); err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := s.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			s.writeFrame(wsOpClose, payload)
+			return nil, io.EOF
+		case wsOpText, wsOpContinuation:
+			if (opcode == wsOpText) == started {
+				return nil, errWSProtocol
+			}
+			started = true
+			message = append(message, payload...)
+		default:
+			return nil, errWSProtocol
+		}
+
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+type SubscribeOptions struct {
+	// Interval between quotes, one second when zero. The server allows
+	// five commands a second.
+	Interval time.Duration
+	// Tag or Author limit the quotes, at most one of them is set. Author
+	// is Quote.AuthorID.
+	Tag    string
+	Author string
+}
+
+// SubscribeRandom yields a random quote every interval over a WebSocket
+// session, until ctx is cancelled or the session ends. Errors end the
+// sequence.
+func (c *Client) SubscribeRandom(ctx context.Context, opts SubscribeOptions) iter.Seq2[Quote, error] {
+	return func(yield func(Quote, error) bool) {
+		interval := opts.Interval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		session, err := c.Dial(ctx)
+		if err != nil {
+			yield(Quote{}, err)
+			return
+		}
+		defer session.Close()
+
+		for {
+			quote, err := session.Next(opts.Tag, opts.Author)
+			if err != nil {
+				if ctx.Err() == nil {
+					yield(Quote{}, err)
+				}
+				return
+			}
+			if !yield(*quote, nil) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_quote/client"
+)
+
+func newTestClient(t *testing.T, handler func(http.Handler) http.Handler) *client.Client {
+	t.Helper()
+	return newAPITestClient(t, newTestAPI(testQuotes), handler)
+}
+
+func newAPITestClient(t *testing.T, api *API, handler func(http.Handler) http.Handler) *client.Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+
+	var h http.Handler = api.SetupMiddleware()(mux)
+	if handler != nil {
+		h = handler(h)
+	}
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	c := client.New(server.URL)
+	c.RetryWait = time.Millisecond
+	return c
+}
+
+func TestClientQuote(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	quote, err := c.Quote(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.ID != 1 || quote.Author != "Bob Example" || quote.AuthorID != "Bob+Example" {
+		t.Errorf("unexpected quote %+v", quote)
+	}
+
+	_, err = c.Quote(ctx, 99)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !client.IsNotFound(err) || apiErr.Message != "Quote not found" {
+		t.Errorf("Quote(99) error = %v, want a 404 Quote not found", err)
+	}
+
+	page, err := c.AuthorQuotes(ctx, "Ann+Example", client.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Author != "Ann Example" || page.TotalQuotes != 2 || len(page.Quotes) != 2 {
+		t.Errorf("unexpected author page %+v", page)
+	}
+}
+
+func TestClientPagination(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	var ids []int
+	for quote, err := range c.AllQuotes(ctx, client.ListOptions{PageSize: 2, Fields: []string{"id", "author"}}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quote.Text != "" {
+			t.Errorf("fields were dropped while paging: %+v", quote)
+		}
+		ids = append(ids, quote.ID)
+	}
+	if len(ids) != 3 || ids[0] != 0 || ids[2] != 2 {
+		t.Errorf("AllQuotes ids = %v", ids)
+	}
+
+	ids = nil
+	for quote, err := range c.AllTagQuotes(ctx, "love", client.ListOptions{PageSize: 1, Cursor: ""}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, quote.ID)
+	}
+	if len(ids) != 2 || ids[0] != 0 || ids[1] != 1 {
+		t.Errorf("AllTagQuotes ids = %v", ids)
+	}
+
+	var tags []string
+	for tag, err := range c.AllTags(ctx, client.ListOptions{PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags = append(tags, tag.Name)
+	}
+	if strings.Join(tags, ",") != "food,love,life" {
+		t.Errorf("AllTags = %v", tags)
+	}
+
+	for _, err := range c.AllTagQuotes(ctx, "missing", client.ListOptions{}) {
+		if !client.IsNotFound(err) {
+			t.Errorf("AllTagQuotes(missing) error = %v, want 404", err)
+		}
+	}
+}
+
+func TestClientQueryAndStats(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	page, err := c.Query(ctx, `tag:love AND author:"Ann Example"`, client.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Quotes) != 1 || page.Quotes[0].ID != 0 || page.Pagination.Total != 1 {
+		t.Errorf("unexpected query page %+v", page)
+	}
+	_, err = c.Query(ctx, "tag:love AND", client.ListOptions{})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Query(invalid) error = %v, want a 400", err)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Quotes != 3 || stats.Authors != 2 || stats.Tags != 3 || len(stats.TopAuthors) == 0 || stats.TopAuthors[0].Name != "Ann Example" {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestClientSemanticSearch(t *testing.T) {
+	c := newAPITestClient(t, newSemanticTestAPI(t), nil)
+	ctx := context.Background()
+
+	page, err := c.SemanticSearch(ctx, 0, 0, client.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Quotes) != 2 || page.Quotes[0].ID != 1 || page.Quotes[1].ID != 2 {
+		t.Errorf("unexpected semantic page %+v", page)
+	}
+	page, err = c.SemanticSearchVector(ctx, []float32{0, 1, 2}, 1, client.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Quotes) != 1 || page.Quotes[0].ID != 2 {
+		t.Errorf("unexpected vector page %+v", page)
+	}
+	if _, err := c.SemanticSearch(ctx, 3, 0, client.ListOptions{}); !client.IsNotFound(err) {
+		t.Errorf("SemanticSearch(3) error = %v, want a 404", err)
+	}
+	_, err = c.SemanticSearchVector(ctx, []float32{1, 0}, 0, client.ListOptions{})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("SemanticSearchVector(wrong dimensions) error = %v, want a 400", err)
+	}
+}
+
+func TestClientHealthAndMetrics(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	api.Metrics = NewMetrics()
+	api.loading.Store(true)
+	c := newAPITestClient(t, api, nil)
+	ctx := context.Background()
+
+	if health, err := c.Healthz(ctx); err != nil || health.Status != "ok" {
+		t.Errorf("Healthz while loading = %+v, %v", health, err)
+	}
+	health, err := c.Readyz(ctx)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || health == nil || health.Status != "loading" {
+		t.Errorf("Readyz while loading = %+v, %v, want loading with a 503", health, err)
+	}
+
+	api.loading.Store(false)
+	health, err = c.Readyz(ctx)
+	if err != nil || health.Status != "ready" || health.Dataset == nil || health.Dataset.Quotes != 3 {
+		t.Errorf("Readyz = %+v, %v", health, err)
+	}
+
+	metrics, err := c.Metrics(ctx)
+	if err != nil || !strings.Contains(metrics, "# TYPE") {
+		t.Errorf("Metrics = %q, %v", metrics, err)
+	}
+}
+
+func TestClientLang(t *testing.T) {
+	quotes := append(Quotes{}, testQuotes...)
+	quotes[0].Language = "en"
+	quotes[1].Language = "es"
+	quotes[2].Language = "en"
+	c := newAPITestClient(t, newTestAPI(quotes), nil)
+
+	page, err := c.Quotes(context.Background(), client.ListOptions{Lang: "es"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Quotes) != 1 || page.Quotes[0].ID != 1 {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestClientWebSocket(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	session, err := c.Dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	quote, err := session.Get(1)
+	if err != nil || quote.ID != 1 || quote.Author != "Bob Example" {
+		t.Errorf("Get(1) = %+v, %v", quote, err)
+	}
+	quote, err = session.Next("life", "")
+	if err != nil || quote.ID != 2 {
+		t.Errorf("Next(life) = %+v, %v", quote, err)
+	}
+	if _, err := session.Get(99); !client.IsNotFound(err) {
+		t.Errorf("Get(99) error = %v, want a 404", err)
+	}
+
+	subscribeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	received := 0
+	for quote, err := range c.SubscribeRandom(subscribeCtx, client.SubscribeOptions{Interval: time.Millisecond, Author: "Ann+Example"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quote.Author != "Ann Example" {
+			t.Errorf("unexpected quote %+v", quote)
+		}
+		if received++; received == 3 {
+			break
+		}
+	}
+	if received != 3 {
+		t.Errorf("received %d quotes, want 3", received)
+	}
+}
+
+func TestClientRetriesAndGzip(t *testing.T) {
+	var requests atomic.Int32
+	var gzipRequested atomic.Bool
+	c := newTestClient(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gzipRequested.Store(r.Header.Get("Accept-Encoding") == "gzip")
+			if requests.Add(1) <= 2 {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	page, err := c.Quotes(context.Background(), client.ListOptions{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 3 || !gzipRequested.Load() {
+		t.Errorf("requests = %d, gzip requested = %v", requests.Load(), gzipRequested.Load())
+	}
+	if len(page.Quotes) != 2 || page.Pagination.Total != 3 {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	c.MaxRetries = 0
+	requests.Store(0)
+	if _, err := c.Quote(context.Background(), 0); err == nil {
+		t.Error("expected the 503 without retries")
+	}
+}
+
+func TestClientFormatsGraphQLAndStream(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	body, err := c.Format(ctx, "xml", "/quotes/1", nil)
+	if err != nil || !strings.Contains(string(body), "<author>Bob Example</author>") {
+		t.Errorf("Format(xml) = %s, %v", body, err)
+	}
+
+	var data struct {
+		Quote struct {
+			Author struct {
+				TotalQuotes int `json:"totalQuotes"`
+			} `json:"author"`
+		} `json:"quote"`
+	}
+	err = c.GraphQL(ctx, `query ($id: Int!) { quote(id: $id) { author { totalQuotes } } }`, map[string]any{"id": 2}, &data)
+	if err != nil || data.Quote.Author.TotalQuotes != 2 {
+		t.Errorf("GraphQL = %+v, %v", data, err)
+	}
+	var gqlErrs client.GraphQLErrors
+	if err := c.GraphQL(ctx, `{ nope }`, nil, nil); !errors.As(err, &gqlErrs) || len(gqlErrs) != 1 {
+		t.Errorf("GraphQL(invalid) error = %v", err)
+	}
+
+	streamCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	seed := int64(7)
+	for event, err := range c.StreamRandom(streamCtx, client.StreamOptions{Seed: &seed, Tag: "love", LastEventID: "4"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.ID != "5" || len(event.Quote.Tags) == 0 {
+			t.Errorf("unexpected event %+v", event)
+		}
+		break
+	}
+}
@@ -53,12 +53,609 @@ const SwaggerSpec = `
           {
             "$ref": "#/components/parameters/FormatParam"
           },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          },
+          {
+            "$ref": "#/components/parameters/LangParam"
+          },
           {
             "$ref": "#/components/parameters/AcceptHeader"
           }
         ]
       }
     },
+    "/feed": {
+      "get": {
+        "summary": "Quote of the day history as an RSS or Atom feed",
+        "parameters": [
+          {
+            "name": "format",
+            "in": "query",
+            "schema": {
+              "type": "string",
+              "enum": ["atom", "rss"],
+              "default": "atom"
+            },
+            "description": "Feed format"
+          },
+          {
+            "name": "page_size",
+            "in": "query",
+            "schema": {
+              "type": "integer",
+              "default": 10,
+              "maximum": 365
+            },
+            "description": "Number of days of history"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "application/atom+xml": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "application/rss+xml": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/export": {
+      "get": {
+        "summary": "Export all quotes as an Apache Arrow table",
+        "description": "Columns are id (int64), text, author, author_id (utf8) and tags (list<utf8>).",
+        "parameters": [
+          {
+            "name": "format",
+            "in": "query",
+            "schema": {
+              "type": "string",
+              "enum": ["arrow", "arrows"],
+              "default": "arrow"
+            },
+            "description": "arrow for the IPC file format, arrows for the IPC streaming format"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "application/vnd.apache.arrow.file": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              },
+              "application/vnd.apache.arrow.stream": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Unsupported export format"
+          }
+        }
+      }
+    },
+    "/stream/random": {
+      "get": {
+        "summary": "Server-Sent Events stream of random quotes",
+        "description": "Keeps the connection open and sends a quote event on every tick. With a seed the events are numbered and a reconnecting client resumes after its Last-Event-ID.",
+        "parameters": [
+          {
+            "name": "interval",
+            "in": "query",
+            "schema": {
+              "type": "string",
+              "default": "10s"
+            },
+            "description": "Time between events as a Go duration, between 1s and 1h"
+          },
+          {
+            "name": "tag",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            },
+            "description": "Only send quotes with this tag"
+          },
+          {
+            "name": "seed",
+            "in": "query",
+            "schema": {
+              "type": "integer"
+            },
+            "description": "Seed for a deterministic, resumable sequence"
+          },
+          {
+            "name": "format",
+            "in": "query",
+            "schema": {
+              "type": "string",
+              "enum": ["json", "html"],
+              "default": "json"
+            },
+            "description": "Format of the event data"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          },
+          {
+            "name": "Last-Event-ID",
+            "in": "header",
+            "schema": {
+              "type": "string"
+            },
+            "description": "Last event received, only used with a seed"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Event stream",
+            "content": {
+              "text/event-stream": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid parameters"
+          },
+          "404": {
+            "description": "Tag not found"
+          }
+        }
+      }
+    },
+    "/ws": {
+      "get": {
+        "summary": "WebSocket session for interactive quote lookups",
+        "description": "After the upgrade clients send JSON commands such as {\"cmd\":\"next\",\"tag\":\"love\"}, {\"cmd\":\"next\",\"author\":\"<authorId>\"} or {\"cmd\":\"get\",\"id\":42}. Each command is answered with {\"cmd\":...,\"quote\":{...}} or {\"cmd\":...,\"error\":{...}}. Connections are limited to 5 commands per second with bursts of 10 and are kept alive with pings every 30 seconds.",
+        "responses": {
+          "101": {
+            "description": "Switching to the WebSocket protocol"
+          },
+          "400": {
+            "description": "Not a WebSocket handshake"
+          },
+          "426": {
+            "description": "Unsupported WebSocket version"
+          }
+        }
+      }
+    },
+    "/query": {
+      "get": {
+        "summary": "Filter quotes with a query",
+        "description": "Predicates are tag:, author:, text: and source: (substring), language:, verified:true|false and the numeric fields id, len, year, tag_count and author_count with =, !=, <, <=, > and >=. They combine with AND, OR, NOT and parentheses. The X-Query-Plan header shows how the query was evaluated.",
+        "parameters": [
+          {
+            "name": "q",
+            "in": "query",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "example": "author_count>50 AND tag:science AND len<100"
+          },
+          {
+            "$ref": "#/components/parameters/PageParam"
+          },
+          {
+            "$ref": "#/components/parameters/PageSizeParam"
+          },
+          {
+            "$ref": "#/components/parameters/CursorParam"
+          },
+          {
+            "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PaginatedQuotes"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid query",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/stats": {
+      "get": {
+        "summary": "Statistics of the quote corpus",
+        "description": "Totals, histograms of quote length in characters and words, the top authors and tags, the tags used together most and the distribution of quotes per author. Computed once at start up.",
+        "parameters": [
+          {
+            "name": "format",
+            "in": "query",
+            "schema": {
+              "type": "string",
+              "enum": ["json", "html", "csv", "markdown"],
+              "default": "json"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              },
+              "text/html": {},
+              "text/csv": {},
+              "text/markdown": {}
+            }
+          }
+        }
+      }
+    },
+    "/debug": {
+      "get": {
+        "summary": "Runtime and dataset introspection",
+        "description": "Memory and GC statistics, goroutines, build information, the redacted configuration and the checksum and index sizes of the data set. Requires the debug token as a bearer token when one is configured, otherwise only local clients are served.",
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Missing or invalid debug token"
+          },
+          "403": {
+            "description": "Not a local client and no debug token configured"
+          }
+        }
+      }
+    },
+    "/healthz": {
+      "get": {
+        "summary": "Liveness probe",
+        "description": "Answers as soon as the server listens, also while the quotes are loading.",
+        "responses": {
+          "200": {
+            "description": "The server is alive",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "summary": "Readiness probe",
+        "description": "Ready once the quotes are loaded and indexed, with the dataset version (the SHA-256 of the data file), its modification time and quote count. Also reports whether the speech backend (pico2wave, or say on macOS) is installed, which does not affect readiness. Other routes answer 503 until the server is ready.",
+        "responses": {
+          "200": {
+            "description": "Ready to serve quotes",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "The quotes are still loading"
+          }
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "summary": "Prometheus metrics",
+        "description": "Request counts by route, format and status, latency and response size histograms, in-flight requests, the gzip compression ratio, Go runtime and GC statistics and the size of the dataset, in the Prometheus text format. Disabled with -METRICS=false.",
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "text/plain": {}
+            }
+          }
+        }
+      }
+    },
+    "/search/semantic": {
+      "get": {
+        "summary": "Find quotes with embeddings near a quote",
+        "description": "Approximate nearest neighbours by cosine similarity over the embeddings loaded next to the data file. Only available when embeddings are loaded.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "query",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "k",
+            "in": "query",
+            "description": "Number of nearest quotes, 1 to 100",
+            "schema": {
+              "type": "integer",
+              "default": 10
+            }
+          },
+          {
+            "$ref": "#/components/parameters/PageParam"
+          },
+          {
+            "$ref": "#/components/parameters/PageSizeParam"
+          },
+          {
+            "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Successful response, nearest first",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PaginatedQuotes"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid parameters or vector",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "No embeddings loaded or the quote has no embedding"
+          }
+        }
+      },
+      "post": {
+        "summary": "Find quotes with embeddings near a vector",
+        "description": "The vector must have as many dimensions as the loaded embeddings.",
+        "parameters": [
+          {
+            "name": "k",
+            "in": "query",
+            "description": "Number of nearest quotes, 1 to 100",
+            "schema": {
+              "type": "integer",
+              "default": 10
+            }
+          },
+          {
+            "$ref": "#/components/parameters/PageParam"
+          },
+          {
+            "$ref": "#/components/parameters/PageSizeParam"
+          },
+          {
+            "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["vector"],
+                "properties": {
+                  "vector": {
+                    "type": "array",
+                    "items": {
+                      "type": "number"
+                    }
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Successful response, nearest first",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PaginatedQuotes"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid parameters or vector",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "No embeddings loaded or the quote has no embedding"
+          }
+        }
+      }
+    },
+    "/graphql": {
+      "get": {
+        "summary": "Run a GraphQL query",
+        "description": "Queries over quotes, authors and tags, see /graphql/schema. Queries are limited to a depth of 10, 2000 selections once fragments are expanded and an estimated 5000 resolved fields.",
+        "parameters": [
+          {
+            "name": "query",
+            "in": "query",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "GraphQL query document"
+          },
+          {
+            "name": "operationName",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            },
+            "description": "Operation to run when the document has several"
+          },
+          {
+            "name": "variables",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            },
+            "description": "Variables as a JSON object"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "GraphQL response with data and/or errors",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        }
+      },
+      "post": {
+        "summary": "Run a GraphQL query",
+        "requestBody": {
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "query": {
+                    "type": "string"
+                  },
+                  "operationName": {
+                    "type": "string"
+                  },
+                  "variables": {
+                    "type": "object"
+                  }
+                }
+              }
+            },
+            "application/graphql": {
+              "schema": {
+                "type": "string"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "GraphQL response with data and/or errors",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid request body"
+          }
+        }
+      }
+    },
+    "/graphql/schema": {
+      "get": {
+        "summary": "GraphQL schema in SDL",
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/schema/quotes.proto": {
+      "get": {
+        "summary": "Protocol Buffers schema of the protobuf output format",
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
     "/quotes": {
       "get": {
         "summary": "List all quotes",
@@ -81,8 +678,17 @@ const SwaggerSpec = `
           {
             "$ref": "#/components/parameters/PageSizeParam"
           },
+          {
+            "$ref": "#/components/parameters/CursorParam"
+          },
           {
             "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          },
+          {
+            "$ref": "#/components/parameters/LangParam"
           }
         ]
       }
@@ -102,6 +708,18 @@ const SwaggerSpec = `
           {
             "$ref": "#/components/parameters/FormatParam"
           },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          },
+          {
+            "name": "lang",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            },
+            "example": "es",
+            "description": "Serve the translation of the quote in this language. Without one the Accept-Language languages are tried, then the quote itself is served. Content-Location points at the translation."
+          },
           {
             "$ref": "#/components/parameters/AcceptHeader"
           }
@@ -180,6 +798,95 @@ const SwaggerSpec = `
         }
       }
     },
+    "/quotes/{quoteId}/translations": {
+      "get": {
+        "summary": "List the translations of a quote",
+        "description": "Quotes in the same translation group of the data file, without the quote itself. /quotes/{quoteId}?lang= serves the translation in a language directly.",
+        "parameters": [
+          {
+            "name": "quoteId",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/PageParam"
+          },
+          {
+            "$ref": "#/components/parameters/PageSizeParam"
+          },
+          {
+            "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PaginatedQuotes"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Quote not found"
+          }
+        }
+      }
+    },
+    "/quotes/{quoteId}/similar": {
+      "get": {
+        "summary": "List quotes similar to a quote",
+        "description": "Up to 10 quotes ranked by the TF-IDF similarity of their text, shared tags and a shared author, best first. Cursor pagination is not supported.",
+        "parameters": [
+          {
+            "name": "quoteId",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/PageParam"
+          },
+          {
+            "$ref": "#/components/parameters/PageSizeParam"
+          },
+          {
+            "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Successful response",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PaginatedQuotes"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Cursor pagination requested"
+          },
+          "404": {
+            "description": "Quote not found"
+          }
+        }
+      }
+    },
     "/tags": {
       "get": {
         "summary": "List all tags",
@@ -226,8 +933,14 @@ const SwaggerSpec = `
           {
             "$ref": "#/components/parameters/PageSizeParam"
           },
+          {
+            "$ref": "#/components/parameters/CursorParam"
+          },
           {
             "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
           }
         ],
         "responses": {
@@ -300,8 +1013,14 @@ const SwaggerSpec = `
           {
             "$ref": "#/components/parameters/PageSizeParam"
           },
+          {
+            "$ref": "#/components/parameters/CursorParam"
+          },
           {
             "$ref": "#/components/parameters/FormatParam"
+          },
+          {
+            "$ref": "#/components/parameters/FieldsParam"
           }
         ],
         "responses": {
@@ -421,6 +1140,26 @@ const SwaggerSpec = `
             "items": {
               "type": "string"
             }
+          },
+          "source": {
+            "type": "string",
+            "description": "Work the quote comes from, left out when unknown"
+          },
+          "year": {
+            "type": "integer",
+            "description": "Year of the source, left out when unknown"
+          },
+          "language": {
+            "type": "string",
+            "description": "Language code of the quote text, left out when unknown"
+          },
+          "url": {
+            "type": "string",
+            "description": "Link to the source, left out when unknown"
+          },
+          "verified": {
+            "type": "boolean",
+            "description": "Whether the attribution is verified, left out when false"
           }
         }
       },
@@ -456,6 +1195,15 @@ const SwaggerSpec = `
           },
           "totalItems": {
             "type": "integer"
+          },
+          "next": {
+            "type": "string"
+          },
+          "cursor": {
+            "type": "string"
+          },
+          "next_cursor": {
+            "type": "string"
           }
         }
       },
@@ -578,6 +1326,14 @@ const SwaggerSpec = `
         },
         "description": "Page number for pagination"
       },
+      "CursorParam": {
+        "name": "cursor",
+        "in": "query",
+        "schema": {
+          "type": "string"
+        },
+        "description": "Opaque cursor for deep paging, send an empty value to start and follow next_cursor"
+      },
       "PageSizeParam": {
         "name": "page_size",
         "in": "query",
@@ -587,6 +1343,24 @@ const SwaggerSpec = `
         },
         "description": "Number of items per page"
       },
+      "FieldsParam": {
+        "name": "fields",
+        "in": "query",
+        "schema": {
+          "type": "string"
+        },
+        "example": "text,author",
+        "description": "Comma separated quote fields to return: id, text, author, author_id, tags, source, year, language, url, verified"
+      },
+      "LangParam": {
+        "name": "lang",
+        "in": "query",
+        "schema": {
+          "type": "string"
+        },
+        "example": "es",
+        "description": "Only quotes in this language, detected when the quotes are loaded. all lists every language. Without it /random-quote picks from the first Accept-Language language that has quotes"
+      },
       "FormatParam": {
         "name": "format",
         "in": "query",
@@ -597,11 +1371,14 @@ const SwaggerSpec = `
 			"csv",
 			"embed",
 			"embed.js",
+			"hal",
 			"html",
 			"json",
 			"markdown",
+			"msgpack",
 			"oembed",
 			"oembed.xml",
+			"protobuf",
 			"rss",
 			"svg",
 			"svg-download",
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// debugGCPauses is the number of most recent GC pauses in /debug.
+const debugGCPauses = 10
+
+type DebugMemory struct {
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"total_alloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapIdle     uint64 `json:"heap_idle"`
+	HeapReleased uint64 `json:"heap_released"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	StackInuse   uint64 `json:"stack_inuse"`
+	Mallocs      uint64 `json:"mallocs"`
+	Frees        uint64 `json:"frees"`
+}
+
+type DebugGC struct {
+	NumGC         uint32    `json:"num_gc"`
+	NextGC        uint64    `json:"next_gc"`
+	LastGC        time.Time `json:"last_gc"`
+	PauseTotal    string    `json:"pause_total"`
+	RecentPauses  []string  `json:"recent_pauses"`
+	CPUFraction   float64   `json:"cpu_fraction"`
+	GOGC          string    `json:"gogc"`
+	MemoryLimitMB int64     `json:"memory_limit_mb"`
+}
+
+type DebugBuild struct {
+	GoVersion string `json:"go_version"`
+	Path      string `json:"path,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	CPUs      int    `json:"cpus"`
+}
+
+type DebugDataset struct {
+	File    string         `json:"file,omitempty"`
+	SHA256  string         `json:"sha256,omitempty"`
+	Updated time.Time      `json:"updated"`
+	Quotes  int            `json:"quotes"`
+	Indexes map[string]int `json:"indexes"`
+}
+
+type DebugInfo struct {
+	Goroutines int                    `json:"goroutines"`
+	Memory     DebugMemory            `json:"memory"`
+	GC         DebugGC                `json:"gc"`
+	Build      DebugBuild             `json:"build"`
+	Config     map[string]interface{} `json:"config,omitempty"`
+	Dataset    DebugDataset           `json:"dataset"`
+}
+
+// redactedConfig returns the fields of the config by name. Fields whose
+// name mentions a token, secret, password or key are replaced when set, and
+// credentials are stripped from URLs such as the trace endpoint.
+func redactedConfig(config interface{}) map[string]interface{} {
+	value := reflect.Indirect(reflect.ValueOf(config))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := make(map[string]interface{}, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Name
+		field := value.Field(i)
+		lower := strings.ToLower(name)
+		secret := strings.Contains(lower, "token") || strings.Contains(lower, "secret") ||
+			strings.Contains(lower, "password") || strings.HasSuffix(lower, "key")
+		if secret && !field.IsZero() {
+			fields[name] = "[redacted]"
+			continue
+		}
+		if field.Kind() == reflect.String {
+			if u, err := url.Parse(field.String()); err == nil && u.User != nil {
+				u.User = nil
+				fields[name] = u.String()
+				continue
+			}
+		}
+		fields[name] = field.Interface()
+	}
+	return fields
+}
+
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func debugBuild() DebugBuild {
+	build := DebugBuild{
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CPUs:      runtime.NumCPU(),
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	build.Path = info.Main.Path
+	build.Version = info.Main.Version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.Time = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+func (api *API) debugInfo() DebugInfo {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	// PauseNs is a circular buffer with the most recent pause at
+	// (NumGC+255)%256.
+	pauses := make([]string, 0, debugGCPauses)
+	for i := uint32(0); i < min(stats.NumGC, debugGCPauses); i++ {
+		pauses = append(pauses, time.Duration(stats.PauseNs[(stats.NumGC-1-i)%256]).String())
+	}
+	gogc := os.Getenv("GOGC")
+	if gogc == "" {
+		gogc = "100"
+	}
+
+	info := DebugInfo{
+		Goroutines: runtime.NumGoroutine(),
+		Memory: DebugMemory{
+			Alloc:        stats.Alloc,
+			TotalAlloc:   stats.TotalAlloc,
+			Sys:          stats.Sys,
+			HeapAlloc:    stats.HeapAlloc,
+			HeapInuse:    stats.HeapInuse,
+			HeapIdle:     stats.HeapIdle,
+			HeapReleased: stats.HeapReleased,
+			HeapObjects:  stats.HeapObjects,
+			StackInuse:   stats.StackInuse,
+			Mallocs:      stats.Mallocs,
+			Frees:        stats.Frees,
+		},
+		GC: DebugGC{
+			NumGC:         stats.NumGC,
+			NextGC:        stats.NextGC,
+			LastGC:        time.Unix(0, int64(stats.LastGC)).UTC(),
+			PauseTotal:    time.Duration(stats.PauseTotalNs).String(),
+			RecentPauses:  pauses,
+			CPUFraction:   stats.GCCPUFraction,
+			GOGC:          gogc,
+			MemoryLimitMB: debug.SetMemoryLimit(-1) >> 20,
+		},
+		Build: debugBuild(),
+		Dataset: DebugDataset{
+			Updated: api.DataUpdated,
+			Quotes:  len(api.Quotes),
+			Indexes: map[string]int{
+				"authors":      api.Authors.Len(),
+				"tags":         api.Tags.Len(),
+				"languages":    api.Languages.Len(),
+				"translations": api.Translations.Len(),
+			},
+		},
+	}
+	if api.Similar != nil {
+		info.Dataset.Indexes["similar_terms"] = len(api.Similar.postings)
+	}
+	if api.Semantic != nil {
+		info.Dataset.Indexes["embeddings"] = api.Semantic.Len()
+	}
+	if api.Config != nil {
+		info.Config = redactedConfig(api.Config)
+		info.Dataset.File = api.Config.Filename
+		info.Dataset.SHA256 = api.dataChecksum()
+	}
+	return info
+}
+
+// dataChecksum returns the SHA-256 of the data file, computed on first use.
+func (api *API) dataChecksum() string {
+	api.checksumOnce.Do(func() {
+		checksum, err := fileSHA256(api.Config.Filename)
+		if err != nil {
+			api.checksum = fmt.Sprintf("unavailable: %v", err)
+			return
+		}
+		api.checksum = checksum
+	})
+	return api.checksum
+}
+
+func (api *API) DebugHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(api.debugInfo())
+}
+
+// debugAuth protects the debug routes. With a debug token the request must
+// carry it as a bearer token, without one only local clients are let through
+// when local is set.
+func (api *API) debugAuth(next http.Handler, local bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.DebugToken != "" {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(api.DebugToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="debug"`)
+				returnError(w, "json", http.StatusUnauthorized, "Unauthorized", "A valid debug token is required")
+				return
+			}
+		} else if local {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+				returnError(w, "json", http.StatusForbidden, "Forbidden", "Debugging is only served to local clients without a debug token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setupPprof mounts net/http/pprof under /debug/pprof/.
+func (api *API) setupPprof(mux *http.ServeMux) {
+	mux.Handle("/debug/pprof/", api.debugAuth(http.HandlerFunc(pprof.Index), true))
+	mux.Handle("/debug/pprof/cmdline", api.debugAuth(http.HandlerFunc(pprof.Cmdline), true))
+	mux.Handle("/debug/pprof/profile", api.debugAuth(http.HandlerFunc(pprof.Profile), true))
+	mux.Handle("/debug/pprof/symbol", api.debugAuth(http.HandlerFunc(pprof.Symbol), true))
+	mux.Handle("/debug/pprof/trace", api.debugAuth(http.HandlerFunc(pprof.Trace), true))
+}
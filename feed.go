@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxFeedDays = 365
+
+// FeedInfo is the format independent description of a feed, rendered as
+// either RSS or Atom by serveFeed.
+type FeedInfo struct {
+	// ID identifies the feed across pages and query parameters, the URL
+	// without its query.
+	ID          string
+	Title       string
+	Description string
+	SelfURL     string
+	NextURL     string
+	Updated     time.Time
+	Entries     []FeedEntry
+}
+
+type FeedEntry struct {
+	Quote   ResponseQuote
+	URL     string
+	GUID    string
+	Updated time.Time
+}
+
+type RSSFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []RSSItem `xml:"item"`
+}
+
+type RSSItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+	GUID        RSSGUID  `xml:"guid"`
+}
+
+type RSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (rf *RSSFeed) Create(feed FeedInfo) {
+	rf.Version = "2.0"
+	rf.Channel = RSSChannel{
+		Title:         feed.Title,
+		Link:          feed.SelfURL,
+		Description:   feed.Description,
+		LastBuildDate: feed.Updated.Format(time.RFC1123Z),
+		Items:         make([]RSSItem, 0, len(feed.Entries)),
+	}
+	for _, entry := range feed.Entries {
+		rf.Channel.Items = append(rf.Channel.Items, RSSItem{
+			Title:       "Quote by " + entry.Quote.Author,
+			Link:        entry.URL,
+			Description: entry.Quote.Text,
+			Author:      entry.Quote.Author,
+			Categories:  entry.Quote.Tags,
+			PubDate:     entry.Updated.Format(time.RFC1123Z),
+			GUID:        RSSGUID{IsPermaLink: entry.GUID == entry.URL, Value: entry.GUID},
+		})
+	}
+}
+
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Links   []AtomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  AtomAuthor  `xml:"author"`
+	ID      string      `xml:"id"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type AtomEntry struct {
+	Title      string         `xml:"title"`
+	Links      []AtomLink     `xml:"link"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Content    AtomContent    `xml:"content"`
+	Author     AtomAuthor     `xml:"author"`
+	Categories []AtomCategory `xml:"category"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type AtomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func (af *AtomFeed) Create(feed FeedInfo) {
+	af.XMLNS = "http://www.w3.org/2005/Atom"
+	af.Title = feed.Title
+	af.Updated = feed.Updated.Format(time.RFC3339)
+	af.Author = AtomAuthor{Name: "Quotes API"}
+	af.ID = feed.ID
+	af.Links = []AtomLink{{Href: feed.SelfURL, Rel: "self"}}
+	if feed.NextURL != "" {
+		af.Links = append(af.Links, AtomLink{Href: feed.NextURL, Rel: "next"})
+	}
+
+	af.Entries = make([]AtomEntry, 0, len(feed.Entries))
+	for _, entry := range feed.Entries {
+		categories := make([]AtomCategory, 0, len(entry.Quote.Tags))
+		for _, tag := range entry.Quote.Tags {
+			categories = append(categories, AtomCategory{Term: tag})
+		}
+		links := []AtomLink{{Href: entry.URL, Rel: "alternate"}}
+		if entry.Quote.URL != "" {
+			links = append(links, AtomLink{Href: entry.Quote.URL, Rel: "related"})
+		}
+		updated := entry.Updated.Format(time.RFC3339)
+		af.Entries = append(af.Entries, AtomEntry{
+			Title:      "Quote by " + entry.Quote.Author,
+			Links:      links,
+			ID:         entry.GUID,
+			Updated:    updated,
+			Published:  updated,
+			Content:    AtomContent{Type: "text", Value: entry.Quote.Text},
+			Author:     AtomAuthor{Name: entry.Quote.Author},
+			Categories: categories,
+		})
+	}
+}
+
+func isFeedFormat(format string) bool {
+	return format == "rss" || format == "atom"
+}
+
+// serveFeed encodes the feed before writing anything, so an encoding error
+// can still be answered with a 500.
+func serveFeed(w http.ResponseWriter, feed FeedInfo, format string) {
+	var doc interface{}
+	contentType := "application/atom+xml"
+	if format == "rss" {
+		contentType = "application/rss+xml"
+		rss := &RSSFeed{}
+		rss.Create(feed)
+		doc = rss
+	} else {
+		atom := &AtomFeed{}
+		atom.Create(feed)
+		doc = atom
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(doc); err != nil {
+		http.Error(w, "Failed to encode feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
+
+// dataUpdated is the timestamp used for entries that have no date of their
+// own, the moment the dataset was last modified.
+func (api *API) dataUpdated() time.Time {
+	if api.DataUpdated.IsZero() {
+		return time.Unix(0, 0).UTC()
+	}
+	return api.DataUpdated.UTC()
+}
+
+func (api *API) singleQuoteFeed(quote ResponseQuote, responseInfo *ResponseInfo) FeedInfo {
+	updated := api.dataUpdated()
+	return FeedInfo{
+		ID:          responseInfo.QuoteURL,
+		Title:       "Random Quote",
+		Description: "Random Quote of the Moment",
+		SelfURL:     responseInfo.QuoteURL,
+		Updated:     updated,
+		Entries: []FeedEntry{{
+			Quote:   quote,
+			URL:     responseInfo.QuoteURL,
+			GUID:    responseInfo.QuoteURL,
+			Updated: updated,
+		}},
+	}
+}
+
+func (api *API) quotesFeed(r *http.Request, title, description string, quotes []ResponseQuote, pagination Pagination) FeedInfo {
+	baseURL := fmt.Sprintf("%s://%s", scheme(r), r.Host)
+	updated := api.dataUpdated()
+
+	feed := FeedInfo{
+		ID:          baseURL + r.URL.EscapedPath(),
+		Title:       title,
+		Description: description,
+		SelfURL:     baseURL + r.URL.RequestURI(),
+		NextURL:     buildPageLinks(baseURL, r.URL, pagination).Next,
+		Updated:     updated,
+		Entries:     make([]FeedEntry, 0, len(quotes)),
+	}
+
+	for _, quote := range quotes {
+		quoteURL := fmt.Sprintf("%s/quotes/%d", baseURL, quote.ID)
+		feed.Entries = append(feed.Entries, FeedEntry{
+			Quote:   quote,
+			URL:     quoteURL,
+			GUID:    quoteURL,
+			Updated: updated,
+		})
+	}
+	return feed
+}
+
+// quoteOfTheDay deterministically picks the quote for the given UTC day, so
+// every instance of the API agrees on it without keeping state.
+func (api *API) quoteOfTheDay(day time.Time) int {
+	h := fnv.New32a()
+	h.Write([]byte(day.UTC().Format(time.DateOnly)))
+	return int(h.Sum32() % uint32(len(api.Quotes)))
+}
+
+func (api *API) quoteOfTheDayFeed(baseURL, selfURL string, today time.Time, days int) FeedInfo {
+	today = today.UTC().Truncate(24 * time.Hour)
+	feed := FeedInfo{
+		ID:          baseURL + "/feed",
+		Title:       "Quote of the Day",
+		Description: "Daily quote history",
+		SelfURL:     selfURL,
+		Updated:     today,
+		Entries:     make([]FeedEntry, 0, days),
+	}
+
+	for i := 0; i < days; i++ {
+		day := today.AddDate(0, 0, -i)
+		id := api.quoteOfTheDay(day)
+		quoteURL := fmt.Sprintf("%s/quotes/%d", baseURL, id)
+		feed.Entries = append(feed.Entries, FeedEntry{
+			Quote:   api.Quotes[id].CreateResponseQuote(id),
+			URL:     quoteURL,
+			GUID:    quoteURL + "#qotd-" + day.Format(time.DateOnly),
+			Updated: day,
+		})
+	}
+	return feed
+}
+
+func (api *API) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	format := getOutputFormat(r)
+	if !isFeedFormat(format) {
+		format = "atom"
+	}
+
+	if len(api.Quotes) == 0 {
+		returnError(w, format, http.StatusNotFound, "No quotes available", "The quote database is empty")
+		return
+	}
+
+	days, _ := strconv.Atoi(r.URL.Query().Get(PAGESIZE))
+	if days < 1 {
+		days = api.DefaultPageSize
+	}
+	if days > maxFeedDays {
+		days = maxFeedDays
+	}
+
+	baseURL := fmt.Sprintf("%s://%s", scheme(r), r.Host)
+	feed := api.quoteOfTheDayFeed(baseURL, baseURL+r.URL.RequestURI(), time.Now(), days)
+	serveFeed(w, feed, format)
+}
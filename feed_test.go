@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAPI(quotes Quotes) *API {
+	authors := BuildAuthorIndex(quotes)
+	tags := BuildTagIndex(quotes)
+	return &API{
+		Quotes:          quotes,
+		Authors:         authors,
+		Tags:            tags,
+		Languages:       BuildLanguageIndex(quotes),
+		Translations:    BuildTranslationIndex(quotes),
+		Similar:         BuildSimilarIndex(quotes),
+		Stats:           BuildStats(quotes, authors, tags),
+		DefaultPageSize: 10,
+		MaxPageSize:     1000,
+		Runtime:         runtime.GOOS,
+		DataUpdated:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+var testQuotes = Quotes{
+	{Text: "Fish & <chips> are \"great\"", Author: "Ann Example", Tags: []string{"food", "love"}},
+	{Text: "Second quote", Author: "Bob Example", Tags: []string{"love"}},
+	{Text: "Third quote", Author: "Ann Example", Tags: []string{"life"}},
+}
+
+func serveTestRequest(api *API, url string) *httptest.ResponseRecorder {
+	return serveTestRequestAccept(api, url, "")
+}
+
+func serveTestRequestAccept(api *API, url string, accept string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", url, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTagFeedRSS(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	rec := serveTestRequest(api, "http://go-quote.com/tags/love?format=rss")
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/rss+xml" {
+		t.Fatalf("Content-Type = %q, want application/rss+xml", ct)
+	}
+
+	var feed RSSFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Failed to parse RSS: %v\n%s", err, rec.Body.String())
+	}
+
+	if len(feed.Channel.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(feed.Channel.Items))
+	}
+	if got := feed.Channel.Items[0].Description; got != testQuotes[0].Text {
+		t.Errorf("Description = %q, want %q", got, testQuotes[0].Text)
+	}
+	if got := feed.Channel.Items[1].GUID.Value; got != "http://go-quote.com/quotes/1" {
+		t.Errorf("GUID = %q, want http://go-quote.com/quotes/1", got)
+	}
+	if got := feed.Channel.Items[0].PubDate; got != "Wed, 01 May 2024 12:00:00 +0000" {
+		t.Errorf("PubDate = %q, want the dataset timestamp", got)
+	}
+}
+
+func TestAuthorFeedAtom(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	rec := serveTestRequest(api, "http://go-quote.com/authors/Ann+Example?format=atom&page_size=1")
+
+	var feed AtomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Failed to parse Atom: %v\n%s", err, rec.Body.String())
+	}
+
+	if len(feed.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(feed.Entries))
+	}
+	if feed.Entries[0].ID != "http://go-quote.com/quotes/0" {
+		t.Errorf("Entry ID = %q, want http://go-quote.com/quotes/0", feed.Entries[0].ID)
+	}
+	if feed.Entries[0].Updated != "2024-05-01T12:00:00Z" {
+		t.Errorf("Entry updated = %q, want 2024-05-01T12:00:00Z", feed.Entries[0].Updated)
+	}
+
+	var next string
+	for _, link := range feed.Links {
+		if link.Rel == "next" {
+			next = link.Href
+		}
+	}
+	if !strings.Contains(next, "page=2") || !strings.Contains(next, "format=atom") {
+		t.Errorf("Next link = %q, want page 2 in atom format", next)
+	}
+
+	// The feed id stays the same on every page.
+	if feed.ID != "http://go-quote.com/authors/Ann+Example" {
+		t.Errorf("Feed ID = %q, want the URL without the query", feed.ID)
+	}
+	rec = serveTestRequest(api, next)
+	var page2 AtomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &page2); err != nil {
+		t.Fatalf("Failed to parse Atom: %v\n%s", err, rec.Body.String())
+	}
+	if page2.ID != feed.ID {
+		t.Errorf("Feed ID of page 2 = %q, want %q", page2.ID, feed.ID)
+	}
+}
+
+func TestQuoteOfTheDayFeed(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	today := time.Date(2024, 5, 3, 15, 30, 0, 0, time.UTC)
+
+	feed := api.quoteOfTheDayFeed("http://go-quote.com", "http://go-quote.com/feed", today, 3)
+	again := api.quoteOfTheDayFeed("http://go-quote.com", "http://go-quote.com/feed", today, 3)
+
+	if len(feed.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(feed.Entries))
+	}
+
+	seen := make(map[string]bool)
+	for i, entry := range feed.Entries {
+		if entry.GUID != again.Entries[i].GUID {
+			t.Errorf("GUID for entry %d is not stable: %q != %q", i, entry.GUID, again.Entries[i].GUID)
+		}
+		if seen[entry.GUID] {
+			t.Errorf("Duplicate GUID %q", entry.GUID)
+		}
+		seen[entry.GUID] = true
+
+		want := time.Date(2024, 5, 3-i, 0, 0, 0, 0, time.UTC)
+		if !entry.Updated.Equal(want) {
+			t.Errorf("Entry %d updated = %v, want %v", i, entry.Updated, want)
+		}
+	}
+}
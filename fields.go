@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FieldSet selects which quote fields are written by the encoders, parsed
+// from the fields query parameter.
+type FieldSet uint16
+
+const (
+	FieldID FieldSet = 1 << iota
+	FieldText
+	FieldAuthor
+	FieldAuthorID
+	FieldTags
+	FieldSource
+	FieldYear
+	FieldLanguage
+	FieldURL
+	FieldVerified
+
+	MetadataFields = FieldSource | FieldYear | FieldLanguage | FieldURL | FieldVerified
+	AllFields      = FieldID | FieldText | FieldAuthor | FieldAuthorID | FieldTags | MetadataFields
+)
+
+var fieldNames = map[string]FieldSet{
+	"id":        FieldID,
+	"text":      FieldText,
+	"author":    FieldAuthor,
+	"author_id": FieldAuthorID,
+	"tags":      FieldTags,
+	"source":    FieldSource,
+	"year":      FieldYear,
+	"language":  FieldLanguage,
+	"url":       FieldURL,
+	"verified":  FieldVerified,
+}
+
+// parseFields parses a comma separated list of field names. Unknown names are
+// ignored, and when nothing valid is selected all fields are returned.
+func parseFields(param string) FieldSet {
+	var fields FieldSet
+	for _, name := range strings.Split(param, ",") {
+		fields |= fieldNames[strings.ToLower(strings.TrimSpace(name))]
+	}
+	if fields == 0 {
+		return AllFields
+	}
+	return fields
+}
+
+// For drops the metadata fields the quote has no value for, the encoders
+// leave unknown metadata out like the JSON encoding does.
+func (fs FieldSet) For(q *Quote) FieldSet {
+	present := fs &^ MetadataFields
+	if q.Source != "" {
+		present |= fs & FieldSource
+	}
+	if q.Year != 0 {
+		present |= fs & FieldYear
+	}
+	if q.Language != "" {
+		present |= fs & FieldLanguage
+	}
+	if q.URL != "" {
+		present |= fs & FieldURL
+	}
+	if q.Verified {
+		present |= fs & FieldVerified
+	}
+	return present
+}
+
+func (fs FieldSet) Has(field FieldSet) bool {
+	return fs&field != 0
+}
+
+func (fs FieldSet) All() bool {
+	return fs == AllFields
+}
+
+// SparseQuote is the JSON representation of a quote limited to a FieldSet.
+// Unselected fields are nil pointers and left out by omitempty.
+type SparseQuote struct {
+	Text     *string   `json:"text,omitempty"`
+	Author   *string   `json:"author,omitempty"`
+	Tags     *[]string `json:"tags,omitempty"`
+	ID       *int      `json:"id,omitempty"`
+	AuthorID *string   `json:"author_id,omitempty"`
+	Source   *string   `json:"source,omitempty"`
+	Year     *int      `json:"year,omitempty"`
+	Language *string   `json:"language,omitempty"`
+	URL      *string   `json:"url,omitempty"`
+	Verified *bool     `json:"verified,omitempty"`
+}
+
+func newSparseQuote(q *ResponseQuote, fields FieldSet) SparseQuote {
+	var sparse SparseQuote
+	if fields.Has(FieldText) {
+		sparse.Text = &q.Text
+	}
+	if fields.Has(FieldAuthor) {
+		sparse.Author = &q.Author
+	}
+	if fields.Has(FieldTags) {
+		sparse.Tags = &q.Tags
+	}
+	if fields.Has(FieldID) {
+		sparse.ID = &q.ID
+	}
+	if fields.Has(FieldAuthorID) {
+		sparse.AuthorID = &q.AuthorID
+	}
+	fields = fields.For(&q.Quote)
+	if fields.Has(FieldSource) {
+		sparse.Source = &q.Source
+	}
+	if fields.Has(FieldYear) {
+		sparse.Year = &q.Year
+	}
+	if fields.Has(FieldLanguage) {
+		sparse.Language = &q.Language
+	}
+	if fields.Has(FieldURL) {
+		sparse.URL = &q.URL
+	}
+	if fields.Has(FieldVerified) {
+		sparse.Verified = &q.Verified
+	}
+	return sparse
+}
+
+func newSparseQuotes(quotes []ResponseQuote, fields FieldSet) []SparseQuote {
+	sparse := make([]SparseQuote, 0, len(quotes))
+	for i := range quotes {
+		sparse = append(sparse, newSparseQuote(&quotes[i], fields))
+	}
+	return sparse
+}
+
+type SparseQuotesResponse struct {
+	Quotes     []SparseQuote `json:"quotes"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+type SparseAuthorResponse struct {
+	Author      string        `json:"author"`
+	AuthorID    string        `json:"author_id"`
+	TotalQuotes int           `json:"total_quotes"`
+	Quotes      []SparseQuote `json:"quotes"`
+	Pagination  Pagination    `json:"pagination"`
+}
+
+// XMLSparseQuote mirrors XMLQuote with optional elements.
+type XMLSparseQuote struct {
+	XMLName  xml.Name  `xml:"response"`
+	ID       *int      `xml:"id,omitempty"`
+	Text     *string   `xml:"text,omitempty"`
+	Author   *string   `xml:"author,omitempty"`
+	AuthorID *string   `xml:"author_id,omitempty"`
+	Tags     *[]string `xml:"tags>tag,omitempty"`
+	Source   *string   `xml:"source,omitempty"`
+	Year     *int      `xml:"year,omitempty"`
+	Language *string   `xml:"language,omitempty"`
+	URL      *string   `xml:"url,omitempty"`
+	Verified *bool     `xml:"verified,omitempty"`
+}
+
+func newXMLSparseQuote(q *ResponseQuote, fields FieldSet) XMLSparseQuote {
+	var sparse XMLSparseQuote
+	if fields.Has(FieldID) {
+		sparse.ID = &q.ID
+	}
+	if fields.Has(FieldText) {
+		sparse.Text = &q.Text
+	}
+	if fields.Has(FieldAuthor) {
+		sparse.Author = &q.Author
+	}
+	if fields.Has(FieldAuthorID) {
+		sparse.AuthorID = &q.AuthorID
+	}
+	if fields.Has(FieldTags) {
+		sparse.Tags = &q.Tags
+	}
+	fields = fields.For(&q.Quote)
+	if fields.Has(FieldSource) {
+		sparse.Source = &q.Source
+	}
+	if fields.Has(FieldYear) {
+		sparse.Year = &q.Year
+	}
+	if fields.Has(FieldLanguage) {
+		sparse.Language = &q.Language
+	}
+	if fields.Has(FieldURL) {
+		sparse.URL = &q.URL
+	}
+	if fields.Has(FieldVerified) {
+		sparse.Verified = &q.Verified
+	}
+	return sparse
+}
+
+// csvColumns returns the selected columns out of the given ones, keeping
+// their order.
+func csvColumns(fields FieldSet, columns ...FieldSet) []FieldSet {
+	selected := make([]FieldSet, 0, len(columns))
+	for _, column := range columns {
+		if fields.Has(column) {
+			selected = append(selected, column)
+		}
+	}
+	return selected
+}
+
+var csvColumnNames = map[FieldSet]string{
+	FieldID:       "ID",
+	FieldText:     "Text",
+	FieldAuthor:   "Author",
+	FieldAuthorID: "AuthorID",
+	FieldTags:     "Tags",
+	FieldSource:   "Source",
+	FieldYear:     "Year",
+	FieldLanguage: "Language",
+	FieldURL:      "URL",
+	FieldVerified: "Verified",
+}
+
+func csvHeader(columns []FieldSet) []string {
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = csvColumnNames[column]
+	}
+	return header
+}
+
+// csvRow fills row, which must have the length of columns, with the values
+// of the quote.
+func csvRow(row []string, columns []FieldSet, quote *ResponseQuote) {
+	for i, column := range columns {
+		switch column {
+		case FieldID:
+			row[i] = strconv.Itoa(quote.ID)
+		case FieldText:
+			row[i] = quote.Text
+		case FieldAuthor:
+			row[i] = quote.Author
+		case FieldAuthorID:
+			row[i] = quote.AuthorID
+		case FieldTags:
+			row[i] = strings.Join(quote.Tags, "|")
+		case FieldSource:
+			row[i] = quote.Source
+		case FieldYear:
+			row[i] = yearString(quote.Year)
+		case FieldLanguage:
+			row[i] = quote.Language
+		case FieldURL:
+			row[i] = quote.URL
+		case FieldVerified:
+			row[i] = strconv.FormatBool(quote.Verified)
+		}
+	}
+}
+
+// yearString formats a year for the CSV encoders, unknown years are empty.
+func yearString(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+// quotedCSVLine renders values the way the non streaming CSV encoders do,
+// every value quoted.
+func quotedCSVLine(values []string) string {
+	var sb strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(`"`)
+		sb.WriteString(strings.ReplaceAll(value, `"`, `""`))
+		sb.WriteString(`"`)
+	}
+	return sb.String()
+}
+
+// writeYAMLQuote writes the selected fields of a quote as YAML mapping
+// entries. The first line is prefixed by first, so list items can start with
+// "- ", and the following lines by indent.
+func writeYAMLQuote(w io.Writer, quote *ResponseQuote, fields FieldSet, first, indent string) error {
+	prefix := first
+	writeLine := func(line string) error {
+		_, err := io.WriteString(w, prefix+line+"\n")
+		prefix = indent
+		return err
+	}
+
+	if fields.Has(FieldID) {
+		if err := writeLine(fmt.Sprintf("id: %d", quote.ID)); err != nil {
+			return err
+		}
+	}
+	if fields.Has(FieldText) {
+		if err := writeLine(fmt.Sprintf("text: \"%s\"", strings.ReplaceAll(quote.Text, "\"", "\\\""))); err != nil {
+			return err
+		}
+	}
+	if fields.Has(FieldAuthor) {
+		if err := writeLine(fmt.Sprintf("author: \"%s\"", strings.ReplaceAll(quote.Author, "\"", "\\\""))); err != nil {
+			return err
+		}
+	}
+	if fields.Has(FieldAuthorID) {
+		if err := writeLine(fmt.Sprintf("author_id: \"%s\"", strings.ReplaceAll(quote.AuthorID, "\"", "\\\""))); err != nil {
+			return err
+		}
+	}
+	if fields.Has(FieldTags) {
+		if err := writeLine("tags:"); err != nil {
+			return err
+		}
+		for _, tag := range quote.Tags {
+			if _, err := fmt.Fprintf(w, "%s  - %s\n", indent, tag); err != nil {
+				return err
+			}
+		}
+	}
+
+	fields = fields.For(&quote.Quote)
+	var metadata []string
+	if fields.Has(FieldSource) {
+		metadata = append(metadata, fmt.Sprintf("source: \"%s\"", strings.ReplaceAll(quote.Source, "\"", "\\\"")))
+	}
+	if fields.Has(FieldYear) {
+		metadata = append(metadata, fmt.Sprintf("year: %d", quote.Year))
+	}
+	if fields.Has(FieldLanguage) {
+		metadata = append(metadata, fmt.Sprintf("language: %s", quote.Language))
+	}
+	if fields.Has(FieldURL) {
+		metadata = append(metadata, fmt.Sprintf("url: \"%s\"", strings.ReplaceAll(quote.URL, "\"", "\\\"")))
+	}
+	if fields.Has(FieldVerified) {
+		metadata = append(metadata, "verified: true")
+	}
+	for _, line := range metadata {
+		if err := writeLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    string
+		expected FieldSet
+	}{
+		{name: "Empty selects all fields", param: "", expected: AllFields},
+		{name: "Text and author", param: "text,author", expected: FieldText | FieldAuthor},
+		{name: "Whitespace and case", param: " Text , AUTHOR_ID ", expected: FieldText | FieldAuthorID},
+		{name: "Unknown fields are ignored", param: "text,bogus", expected: FieldText},
+		{name: "Only unknown fields selects all fields", param: "bogus", expected: AllFields},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseFields(tt.param); got != tt.expected {
+				t.Errorf("parseFields(%q) = %b, want %b", tt.param, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSparseFieldsFormats(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	tests := []struct {
+		name       string
+		url        string
+		contains   []string
+		notContain []string
+	}{
+		{
+			name:       "Single quote JSON",
+			url:        "http://go-quote.com/quotes/1?fields=text,author",
+			contains:   []string{`"text":"Second quote"`, `"author":"Bob Example"`},
+			notContain: []string{`"tags"`, `"id"`, `"author_id"`},
+		},
+		{
+			name:       "Single quote XML",
+			url:        "http://go-quote.com/quotes/1?format=xml&fields=text",
+			contains:   []string{"<text>Second quote</text>"},
+			notContain: []string{"<author>", "<tags>", "<id>"},
+		},
+		{
+			name:       "Single quote CSV",
+			url:        "http://go-quote.com/quotes/1?format=csv&fields=id,author",
+			contains:   []string{`"ID","Author"`, `"1","Bob Example"`},
+			notContain: []string{"Text", "Second quote"},
+		},
+		{
+			name:       "Single quote XML author_id",
+			url:        "http://go-quote.com/quotes/1?format=xml&fields=author,author_id",
+			contains:   []string{"<author>Bob Example</author><author_id>Bob+Example</author_id>"},
+			notContain: []string{"<text>", "<id>"},
+		},
+		{
+			name:       "Single quote CSV author_id",
+			url:        "http://go-quote.com/quotes/1?format=csv&fields=id,author_id",
+			contains:   []string{`"ID","AuthorID"`, `"1","Bob+Example"`},
+			notContain: []string{"Text", "Bob Example"},
+		},
+		{
+			name:       "Tag quotes CSV author_id",
+			url:        "http://go-quote.com/tags/love?format=csv&fields=author_id",
+			contains:   []string{`"AuthorID"`, `"Ann+Example"`, `"Bob+Example"`},
+			notContain: []string{"Text", "Ann Example"},
+		},
+		{
+			name:       "Single quote YAML",
+			url:        "http://go-quote.com/quotes/1?format=yaml&fields=tags",
+			contains:   []string{"quote:\n  tags:\n    - love\n"},
+			notContain: []string{"text:", "author:", "id:"},
+		},
+		{
+			name:       "Single quote YAML author_id",
+			url:        "http://go-quote.com/quotes/1?format=yaml&fields=author_id,text",
+			contains:   []string{"quote:\n  text: \"Second quote\"\n  author_id: \"Bob+Example\"\n"},
+			notContain: []string{"author:", "id: 1", "tags:"},
+		},
+		{
+			name:       "Streaming YAML author_id",
+			url:        "http://go-quote.com/quotes?format=yaml&fields=author_id",
+			contains:   []string{"quotes:\n  - author_id: \"Ann+Example\"\n  - author_id: \"Bob+Example\"\n"},
+			notContain: []string{"text:", "tags:"},
+		},
+		{
+			name:       "Tag quotes YAML author_id",
+			url:        "http://go-quote.com/tags/love?format=yaml&fields=author_id",
+			contains:   []string{"quotes:\n  - author_id: \"Ann+Example\"\n  - author_id: \"Bob+Example\"\n"},
+			notContain: []string{"text:", "tags:"},
+		},
+		{
+			name:       "Streaming JSON",
+			url:        "http://go-quote.com/quotes?fields=author",
+			contains:   []string{`{"author":"Ann Example"}`, `{"author":"Bob Example"}`, `"pagination"`},
+			notContain: []string{`"text"`, `"tags"`},
+		},
+		{
+			name:       "Streaming CSV",
+			url:        "http://go-quote.com/quotes?format=csv&fields=author_id,text",
+			contains:   []string{"Text,AuthorID\n", "Second quote,Bob+Example\n"},
+			notContain: []string{"Tags", "love"},
+		},
+		{
+			name:       "Streaming XML",
+			url:        "http://go-quote.com/quotes?format=xml&fields=id",
+			contains:   []string{"<response><id>0</id></response>", "<response><id>2</id></response>"},
+			notContain: []string{"<text>", "<author>"},
+		},
+		{
+			name:       "Streaming YAML",
+			url:        "http://go-quote.com/quotes?format=yaml&fields=id,author",
+			contains:   []string{"quotes:\n  - id: 0\n    author: \"Ann Example\"\n  - id: 1\n"},
+			notContain: []string{"text:", "tags:"},
+		},
+		{
+			name:       "Tag quotes JSON",
+			url:        "http://go-quote.com/tags/love?fields=id",
+			contains:   []string{`"quotes":[{"id":0},{"id":1}]`},
+			notContain: []string{`"text"`},
+		},
+		{
+			name:       "Tag quotes XML",
+			url:        "http://go-quote.com/tags/love?format=xml&fields=text",
+			contains:   []string{"<quotes><response><text>Fish &amp; &lt;chips&gt; are &#34;great&#34;</text></response><response><text>Second quote</text></response></quotes>"},
+			notContain: []string{"<author>", `"quotes"`},
+		},
+		{
+			name:       "Author quotes CSV",
+			url:        "http://go-quote.com/authors/Ann+Example?format=csv&fields=id",
+			contains:   []string{"\"ID\"\n\"0\"\n\"2\"\n"},
+			notContain: []string{"Text", "Ann Example"},
+		},
+		{
+			name:       "Author quotes XML",
+			url:        "http://go-quote.com/authors/Ann+Example?format=xml&fields=id",
+			contains:   []string{"<quotes><response><id>0</id></response><response><id>2</id></response></quotes>"},
+			notContain: []string{"<text>", "<author>"},
+		},
+		{
+			name:       "Author quotes YAML",
+			url:        "http://go-quote.com/authors/Ann+Example?format=yaml&fields=text",
+			contains:   []string{"quotes:\n  - text: \"Fish & <chips> are \\\"great\\\"\"\n  - text: \"Third quote\"\n"},
+			notContain: []string{"author:", "id:"},
+		},
+		{
+			name:       "Author quotes JSON",
+			url:        "http://go-quote.com/authors/Ann+Example?fields=text",
+			contains:   []string{`"author":"Ann Example"`, `"quotes":[{"text":"Fish \u0026 \u003cchips\u003e are \"great\""},{"text":"Third quote"}]`},
+			notContain: []string{`"tags"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := serveTestRequest(api, tt.url).Body.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("Response does not contain %q\n%s", want, body)
+				}
+			}
+			for _, unwanted := range tt.notContain {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("Response contains %q\n%s", unwanted, body)
+				}
+			}
+		})
+	}
+}
+
+func TestAllFieldsUnchanged(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	body := serveTestRequest(api, "http://go-quote.com/quotes/2").Body.Bytes()
+
+	var got, expected interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	raw, _ := json.Marshal(testQuotes[2].CreateResponseQuote(2))
+	json.Unmarshal(raw, &expected)
+
+	if !jsonEqual(got, expected) {
+		t.Errorf("Default response changed:\n%s", body)
+	}
+}
+
+func TestMetadataFormats(t *testing.T) {
+	quotes := append(Quotes{}, testQuotes...)
+	quotes[1].Source, quotes[1].Year, quotes[1].Language = "Letters", 1890, "en"
+	quotes[1].URL, quotes[1].Verified = "https://example.com/letters", true
+	api := newTestAPI(quotes)
+
+	tests := []struct {
+		name       string
+		url        string
+		contains   []string
+		notContain []string
+	}{
+		{"json", "/quotes/1", []string{`"source":"Letters"`, `"year":1890`, `"language":"en"`, `"verified":true`}, nil},
+		{"json without metadata", "/quotes/2", nil, []string{"source", "year", "verified"}},
+		{"sparse json", "/quotes/1?fields=id,year", []string{`{"id":1,"year":1890}`}, []string{"source"}},
+		{"text", "/quotes/1?format=text", []string{"Source: Letters, 1890\n", "Language: en\n", "Verified: yes\n"}, nil},
+		{"markdown", "/quotes/1?format=markdown", []string{"Bob Example, *Letters, 1890* ([source](https://example.com/letters))"}, nil},
+		{"xml", "/quotes/1?format=xml", []string{"<source>Letters</source>", "<year>1890</year>", "<verified>true</verified>"}, nil},
+		{"yaml", "/quotes/1?format=yaml", []string{"source: \"Letters\"", "year: 1890", "verified: true"}, nil},
+		{"csv", "/quotes?format=csv&fields=id,year,verified", []string{"ID,Year,Verified\n", "1,1890,true\n", "2,,false\n"}, nil},
+		{"html", "/quotes/1?format=html", []string{`<cite class="source"><a href="https://example.com/letters">Letters, 1890</a></cite>`}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := serveTestRequest(api, tt.url).Body.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("Response does not contain %q\n%s", want, body)
+				}
+			}
+			for _, unwanted := range tt.notContain {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("Response contains %q\n%s", unwanted, body)
+				}
+			}
+		})
+	}
+}
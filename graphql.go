@@ -0,0 +1,834 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const (
+	graphqlMaxDepth      = 10
+	graphqlMaxComplexity = 5000
+	graphqlMaxPageSize   = 100
+	graphqlMaxBodySize   = 64 * 1024
+	// graphqlMaxSelections bounds the selections once fragments are
+	// inlined, a fragment spread twice per level doubles them every level.
+	graphqlMaxSelections = 2000
+
+	// graphqlTagsEstimate is the number of tags a quote is assumed to have
+	// when estimating the complexity of a query.
+	graphqlTagsEstimate = 10
+)
+
+// GraphQLSchema documents the schema served by /graphql, it is kept in sync
+// with gqlSchema by hand and TestGraphQLSchemaInSync checks the two agree.
+const GraphQLSchema = `type Query {
+  quote(id: Int!): Quote
+  randomQuote(tag: String, author: String): Quote
+  quotes(page: Int = 1, pageSize: Int): QuotePage!
+  author(id: String!): Author
+  authors(page: Int = 1, pageSize: Int): AuthorPage!
+  tag(name: String!): Tag
+  tags(page: Int = 1, pageSize: Int): TagPage!
+}
+
+type Quote {
+  id: Int!
+  text: String!
+  author: Author!
+  tags: [Tag!]!
+  source: String
+  year: Int
+  language: String
+  url: String
+  verified: Boolean!
+}
+
+type Author {
+  id: String!
+  name: String!
+  totalQuotes: Int!
+  quotes(page: Int = 1, pageSize: Int): QuotePage!
+}
+
+type Tag {
+  name: String!
+  totalQuotes: Int!
+  quotes(page: Int = 1, pageSize: Int): QuotePage!
+}
+
+type QuotePage {
+  items: [Quote!]!
+  pageInfo: PageInfo!
+}
+
+type AuthorPage {
+  items: [Author!]!
+  pageInfo: PageInfo!
+}
+
+type TagPage {
+  items: [Tag!]!
+  pageInfo: PageInfo!
+}
+
+type PageInfo {
+  page: Int!
+  pageSize: Int!
+  total: Int!
+  pages: Int!
+  hasNextPage: Boolean!
+}
+`
+
+type gqlArgDef struct {
+	typ      string
+	required bool
+}
+
+type gqlFieldDef struct {
+	// typ is the object type of the field, empty for scalars.
+	typ  string
+	args map[string]gqlArgDef
+	// multiplier estimates how many objects the field returns, it is used
+	// for the complexity limit. Nil means a single object.
+	multiplier func(api *API, args map[string]interface{}) int
+	resolve    func(api *API, source interface{}, args map[string]interface{}) interface{}
+}
+
+type gqlObjectType map[string]*gqlFieldDef
+
+// Sources of the object types: Quote is the quote ID, Author the author ID,
+// Tag the tag name and PageInfo a Pagination.
+type gqlQuotePage struct {
+	ids        []int
+	pagination Pagination
+}
+
+type gqlNamePage struct {
+	names      []string
+	pagination Pagination
+}
+
+var gqlPageArgs = map[string]gqlArgDef{
+	"page":     {typ: "Int"},
+	"pageSize": {typ: "Int"},
+}
+
+func gqlPageMultiplier(api *API, args map[string]interface{}) int {
+	return api.gqlPageSize(args)
+}
+
+var gqlSchema = map[string]gqlObjectType{
+	"Query": {
+		"quote": {
+			typ:  "Quote",
+			args: map[string]gqlArgDef{"id": {typ: "Int", required: true}},
+			resolve: func(api *API, _ interface{}, args map[string]interface{}) interface{} {
+				id := args["id"].(int)
+				if id < 0 || id >= len(api.Quotes) {
+					return nil
+				}
+				return id
+			},
+		},
+		"randomQuote": {
+			typ:  "Quote",
+			args: map[string]gqlArgDef{"tag": {typ: "String"}, "author": {typ: "String"}},
+			resolve: func(api *API, _ interface{}, args map[string]interface{}) interface{} {
+				tag, _ := args["tag"].(string)
+				author, _ := args["author"].(string)
+				if id, ok := api.randomQuoteID(tag, author); ok {
+					return id
+				}
+				return nil
+			},
+		},
+		"quotes": {
+			typ:        "QuotePage",
+			args:       gqlPageArgs,
+			multiplier: gqlPageMultiplier,
+			resolve: func(api *API, _ interface{}, args map[string]interface{}) interface{} {
+				return api.gqlQuotePage(nil, len(api.Quotes), args)
+			},
+		},
+		"author": {
+			typ:  "Author",
+			args: map[string]gqlArgDef{"id": {typ: "String", required: true}},
+			resolve: func(api *API, _ interface{}, args map[string]interface{}) interface{} {
+				id := args["id"].(string)
+				if len(api.Authors.NameToQuotes[id]) == 0 {
+					return nil
+				}
+				return id
+			},
+		},
+		"authors": {
+			typ:        "AuthorPage",
+			args:       gqlPageArgs,
+			multiplier: gqlPageMultiplier,
+			resolve: func(api *API, _ interface{}, args map[string]interface{}) interface{} {
+				return api.gqlNamePage(api.Authors.Names, args)
+			},
+		},
+		"tag": {
+			typ:  "Tag",
+			args: map[string]gqlArgDef{"name": {typ: "String", required: true}},
+			resolve: func(api *API, _ interface{}, args map[string]interface{}) interface{} {
+				name := args["name"].(string)
+				if len(api.Tags.NameToQuotes[name]) == 0 {
+					return nil
+				}
+				return name
+			},
+		},
+		"tags": {
+			typ:        "TagPage",
+			args:       gqlPageArgs,
+			multiplier: gqlPageMultiplier,
+			resolve: func(api *API, _ interface{}, args map[string]interface{}) interface{} {
+				return api.gqlNamePage(api.Tags.Names, args)
+			},
+		},
+	},
+	"Quote": {
+		"id": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(int)
+		}},
+		"text": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return api.Quotes[source.(int)].Text
+		}},
+		"author": {typ: "Author", resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return url.QueryEscape(api.Quotes[source.(int)].Author)
+		}},
+		"tags": {
+			typ:        "Tag",
+			multiplier: func(*API, map[string]interface{}) int { return graphqlTagsEstimate },
+			resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+				tags := make([]interface{}, 0, len(api.Quotes[source.(int)].Tags))
+				for _, tag := range api.Quotes[source.(int)].Tags {
+					// Same normalisation as the tag index
+					if tag = strings.TrimSpace(tag); tag != "" {
+						tags = append(tags, tag)
+					}
+				}
+				return tags
+			},
+		},
+		"source": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return gqlOptional(api.Quotes[source.(int)].Source)
+		}},
+		"year": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return gqlOptional(api.Quotes[source.(int)].Year)
+		}},
+		"language": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return gqlOptional(api.Quotes[source.(int)].Language)
+		}},
+		"url": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return gqlOptional(api.Quotes[source.(int)].URL)
+		}},
+		"verified": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return api.Quotes[source.(int)].Verified
+		}},
+	},
+	"Author": {
+		"id": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(string)
+		}},
+		"name": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			name, _ := url.QueryUnescape(source.(string))
+			return name
+		}},
+		"totalQuotes": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return len(api.Authors.NameToQuotes[source.(string)])
+		}},
+		"quotes": {
+			typ:        "QuotePage",
+			args:       gqlPageArgs,
+			multiplier: gqlPageMultiplier,
+			resolve: func(api *API, source interface{}, args map[string]interface{}) interface{} {
+				ids := api.Authors.NameToQuotes[source.(string)]
+				return api.gqlQuotePage(ids, len(ids), args)
+			},
+		},
+	},
+	"Tag": {
+		"name": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(string)
+		}},
+		"totalQuotes": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return len(api.Tags.NameToQuotes[source.(string)])
+		}},
+		"quotes": {
+			typ:        "QuotePage",
+			args:       gqlPageArgs,
+			multiplier: gqlPageMultiplier,
+			resolve: func(api *API, source interface{}, args map[string]interface{}) interface{} {
+				ids := api.Tags.NameToQuotes[source.(string)]
+				return api.gqlQuotePage(ids, len(ids), args)
+			},
+		},
+	},
+	"QuotePage": {
+		"items": {typ: "Quote", resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			ids := source.(gqlQuotePage).ids
+			items := make([]interface{}, len(ids))
+			for i, id := range ids {
+				items[i] = id
+			}
+			return items
+		}},
+		"pageInfo": {typ: "PageInfo", resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(gqlQuotePage).pagination
+		}},
+	},
+	"AuthorPage": gqlNamePageFields("Author"),
+	"TagPage":    gqlNamePageFields("Tag"),
+	"PageInfo": {
+		"page": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(Pagination).Page
+		}},
+		"pageSize": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(Pagination).PageSize
+		}},
+		"total": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(Pagination).Total
+		}},
+		"pages": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(Pagination).Pages
+		}},
+		"hasNextPage": {resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			pagination := source.(Pagination)
+			return pagination.Page < pagination.Pages
+		}},
+	},
+}
+
+// gqlNamePageFields are the fields of AuthorPage and TagPage, which only
+// differ in the type of their items.
+func gqlNamePageFields(itemType string) gqlObjectType {
+	return gqlObjectType{
+		"items": {typ: itemType, resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			names := source.(gqlNamePage).names
+			items := make([]interface{}, len(names))
+			for i, name := range names {
+				items[i] = name
+			}
+			return items
+		}},
+		"pageInfo": {typ: "PageInfo", resolve: func(api *API, source interface{}, _ map[string]interface{}) interface{} {
+			return source.(gqlNamePage).pagination
+		}},
+	}
+}
+
+// gqlOptional resolves the zero value of optional quote metadata to null.
+func gqlOptional[T comparable](value T) interface{} {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return value
+}
+
+func (api *API) gqlPageSize(args map[string]interface{}) int {
+	pageSize, _ := args["pageSize"].(int)
+	if pageSize < 1 {
+		pageSize = api.DefaultPageSize
+	}
+	return min(pageSize, graphqlMaxPageSize)
+}
+
+// gqlQuotePage pages over the given quote IDs, or over all quotes when ids is
+// nil.
+func (api *API) gqlQuotePage(ids []int, total int, args map[string]interface{}) gqlQuotePage {
+	page, _ := args["page"].(int)
+	pagination := api.paginate(total, page, api.gqlPageSize(args))
+	start := min(pagination.offset, total)
+	end := min(start+pagination.PageSize, total)
+
+	result := gqlQuotePage{ids: make([]int, 0, end-start), pagination: pagination}
+	for i := start; i < end; i++ {
+		if ids == nil {
+			result.ids = append(result.ids, i)
+		} else {
+			result.ids = append(result.ids, ids[i])
+		}
+	}
+	return result
+}
+
+func (api *API) gqlNamePage(names []string, args map[string]interface{}) gqlNamePage {
+	page, _ := args["page"].(int)
+	pagination := api.paginate(len(names), page, api.gqlPageSize(args))
+	start := min(pagination.offset, len(names))
+	end := min(start+pagination.PageSize, len(names))
+	return gqlNamePage{names: names[start:end], pagination: pagination}
+}
+
+type gqlError struct {
+	Message   string        `json:"message"`
+	Locations []gqlLocation `json:"locations,omitempty"`
+}
+
+func (e *gqlError) Error() string {
+	return e.Message
+}
+
+func gqlErrorf(loc gqlLocation, format string, args ...interface{}) *gqlError {
+	return &gqlError{Message: fmt.Sprintf(format, args...), Locations: []gqlLocation{loc}}
+}
+
+// gqlObject is a JSON object that keeps the order of the selection set.
+type gqlObject []gqlObjectEntry
+
+type gqlObjectEntry struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(entry.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlPlan is a validated field with its arguments coerced, fragments are
+// inlined and fields with the same response key merged.
+type gqlPlan struct {
+	key        string
+	name       string
+	def        *gqlFieldDef
+	args       map[string]interface{}
+	selections []gqlSelection
+	children   []*gqlPlan
+	loc        gqlLocation
+}
+
+type gqlPlanner struct {
+	doc       *gqlDocument
+	declared  map[string]bool
+	variables map[string]interface{}
+	spreading map[string]bool
+	collected int
+}
+
+func coerceGQLScalar(typ string, value interface{}) (interface{}, bool) {
+	switch typ {
+	case "Int":
+		switch v := value.(type) {
+		case int:
+			return v, v >= math.MinInt32 && v <= math.MaxInt32
+		case float64:
+			return int(v), v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32
+		}
+	case "Float":
+		switch v := value.(type) {
+		case int:
+			return float64(v), true
+		case float64:
+			return v, true
+		}
+	case "String", "ID":
+		v, ok := value.(string)
+		return v, ok
+	case "Boolean":
+		v, ok := value.(bool)
+		return v, ok
+	}
+	return nil, false
+}
+
+// coerceVariables applies defaults and checks the variable values against
+// their declared types.
+func coerceVariables(operation *gqlOperation, values map[string]interface{}) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(operation.variables))
+	for _, definition := range operation.variables {
+		value, ok := values[definition.name]
+		if !ok && definition.hasDefault {
+			value, ok = definition.defaultValue, true
+		}
+		if value == nil {
+			if definition.typ.nonNull {
+				return nil, gqlErrorf(definition.loc, "Variable \"$%s\" of required type was not provided.", definition.name)
+			}
+			if ok {
+				variables[definition.name] = nil
+			}
+			continue
+		}
+		if definition.typ.elem != nil {
+			return nil, gqlErrorf(definition.loc, "Variable \"$%s\" cannot be a list.", definition.name)
+		}
+		coerced, valid := coerceGQLScalar(definition.typ.name, value)
+		if !valid {
+			return nil, gqlErrorf(definition.loc, "Variable \"$%s\" got invalid value, expected type \"%s\".", definition.name, definition.typ.name)
+		}
+		variables[definition.name] = coerced
+	}
+	return variables, nil
+}
+
+func (p *gqlPlanner) resolveValue(value interface{}, loc gqlLocation) (interface{}, error) {
+	if variable, ok := value.(gqlVariable); ok {
+		if !p.declared[string(variable)] {
+			return nil, gqlErrorf(loc, "Variable \"$%s\" is not defined.", variable)
+		}
+		return p.variables[string(variable)], nil
+	}
+	return value, nil
+}
+
+func (p *gqlPlanner) included(directives []gqlDirective) (bool, error) {
+	for _, directive := range directives {
+		if directive.name != "skip" && directive.name != "include" {
+			return false, gqlErrorf(directive.loc, "Unknown directive \"@%s\".", directive.name)
+		}
+		if len(directive.args) != 1 || directive.args[0].name != "if" {
+			return false, gqlErrorf(directive.loc, "Directive \"@%s\" requires a single \"if\" argument.", directive.name)
+		}
+		value, err := p.resolveValue(directive.args[0].value, directive.loc)
+		if err != nil {
+			return false, err
+		}
+		condition, ok := value.(bool)
+		if !ok {
+			return false, gqlErrorf(directive.loc, "Directive \"@%s\" argument \"if\" must be a Boolean.", directive.name)
+		}
+		if condition == (directive.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *gqlPlanner) coerceArgs(typeName string, def *gqlFieldDef, selection gqlSelection) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for _, arg := range selection.args {
+		argDef, ok := def.args[arg.name]
+		if !ok {
+			return nil, gqlErrorf(selection.loc, "Unknown argument \"%s\" on field \"%s.%s\".", arg.name, typeName, selection.name)
+		}
+		value, err := p.resolveValue(arg.value, selection.loc)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		coerced, ok := coerceGQLScalar(argDef.typ, value)
+		if !ok {
+			return nil, gqlErrorf(selection.loc, "Argument \"%s\" on field \"%s.%s\" has an invalid value, expected type \"%s\".", arg.name, typeName, selection.name, argDef.typ)
+		}
+		args[arg.name] = coerced
+	}
+	for name, argDef := range def.args {
+		if _, ok := args[name]; argDef.required && !ok {
+			return nil, gqlErrorf(selection.loc, "Field \"%s.%s\" argument \"%s\" of type \"%s!\" is required.", typeName, selection.name, name, argDef.typ)
+		}
+	}
+	return args, nil
+}
+
+func (p *gqlPlanner) checkTypeCondition(typeName, typeCondition string, loc gqlLocation) error {
+	if typeCondition == "" || typeCondition == typeName {
+		return nil
+	}
+	if _, ok := gqlSchema[typeCondition]; !ok {
+		return gqlErrorf(loc, "Unknown type \"%s\".", typeCondition)
+	}
+	return gqlErrorf(loc, "Fragment cannot be spread here as objects of type \"%s\" can never be of type \"%s\".", typeName, typeCondition)
+}
+
+// collect flattens the selection set into plans, merging fields that share a
+// response key.
+func (p *gqlPlanner) collect(typeName string, selections []gqlSelection, plans []*gqlPlan) ([]*gqlPlan, error) {
+	for _, selection := range selections {
+		if p.collected++; p.collected > graphqlMaxSelections {
+			return nil, gqlErrorf(selection.loc, "Query has more than the maximum of %d selections once fragments are expanded.", graphqlMaxSelections)
+		}
+		include, err := p.included(selection.directives)
+		if err != nil {
+			return nil, err
+		}
+		if !include {
+			continue
+		}
+
+		switch {
+		case selection.fragmentSpread != "":
+			name := selection.fragmentSpread
+			fragment, ok := p.doc.fragments[name]
+			if !ok {
+				return nil, gqlErrorf(selection.loc, "Unknown fragment \"%s\".", name)
+			}
+			if p.spreading[name] {
+				return nil, gqlErrorf(selection.loc, "Cannot spread fragment \"%s\" within itself.", name)
+			}
+			if err := p.checkTypeCondition(typeName, fragment.typeCondition, selection.loc); err != nil {
+				return nil, err
+			}
+			p.spreading[name] = true
+			plans, err = p.collect(typeName, fragment.selections, plans)
+			delete(p.spreading, name)
+			if err != nil {
+				return nil, err
+			}
+			continue
+
+		case selection.inline:
+			if err := p.checkTypeCondition(typeName, selection.typeCondition, selection.loc); err != nil {
+				return nil, err
+			}
+			if plans, err = p.collect(typeName, selection.selections, plans); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		key := selection.alias
+		if key == "" {
+			key = selection.name
+		}
+
+		var def *gqlFieldDef
+		args := map[string]interface{}{}
+		if selection.name == "__typename" {
+			if selection.selections != nil {
+				return nil, gqlErrorf(selection.loc, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+			}
+		} else {
+			def = gqlSchema[typeName][selection.name]
+			if def == nil {
+				return nil, gqlErrorf(selection.loc, "Cannot query field \"%s\" on type \"%s\".", selection.name, typeName)
+			}
+			if def.typ == "" && selection.selections != nil {
+				return nil, gqlErrorf(selection.loc, "Field \"%s\" must not have a selection since it is a scalar.", selection.name)
+			}
+			if def.typ != "" && selection.selections == nil {
+				return nil, gqlErrorf(selection.loc, "Field \"%s\" of type \"%s\" must have a selection of subfields.", selection.name, def.typ)
+			}
+			if args, err = p.coerceArgs(typeName, def, selection); err != nil {
+				return nil, err
+			}
+		}
+
+		merged := false
+		for _, plan := range plans {
+			if plan.key != key {
+				continue
+			}
+			if plan.name != selection.name || !reflect.DeepEqual(plan.args, args) {
+				return nil, gqlErrorf(selection.loc, "Fields \"%s\" conflict because they select different fields or arguments.", key)
+			}
+			plan.selections = append(plan.selections, selection.selections...)
+			merged = true
+			break
+		}
+		if !merged {
+			plans = append(plans, &gqlPlan{key: key, name: selection.name, def: def, args: args, selections: selection.selections, loc: selection.loc})
+		}
+	}
+	return plans, nil
+}
+
+func (p *gqlPlanner) plan(typeName string, selections []gqlSelection, depth int) ([]*gqlPlan, error) {
+	plans, err := p.collect(typeName, selections, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, plan := range plans {
+		if plan.def == nil || plan.def.typ == "" {
+			continue
+		}
+		if depth+1 > graphqlMaxDepth {
+			return nil, gqlErrorf(plan.loc, "Query is nested deeper than the maximum depth of %d.", graphqlMaxDepth)
+		}
+		if plan.children, err = p.plan(plan.def.typ, plan.selections, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+// gqlComplexity counts the fields a query resolves at most, saturating just
+// above the limit.
+func gqlComplexity(api *API, plans []*gqlPlan) int {
+	total := 0
+	for _, plan := range plans {
+		cost := 1
+		if len(plan.children) > 0 {
+			multiplier := 1
+			if plan.def.multiplier != nil {
+				multiplier = plan.def.multiplier(api, plan.args)
+			}
+			cost += multiplier * gqlComplexity(api, plan.children)
+		}
+		total += cost
+		if total > graphqlMaxComplexity {
+			return graphqlMaxComplexity + 1
+		}
+	}
+	return total
+}
+
+func (api *API) gqlExecute(plans []*gqlPlan, typeName string, source interface{}) gqlObject {
+	object := make(gqlObject, 0, len(plans))
+	for _, plan := range plans {
+		var value interface{}
+		if plan.def == nil {
+			value = typeName
+		} else {
+			value = api.gqlComplete(plan, plan.def.resolve(api, source, plan.args))
+		}
+		object = append(object, gqlObjectEntry{key: plan.key, value: value})
+	}
+	return object
+}
+
+func (api *API) gqlComplete(plan *gqlPlan, value interface{}) interface{} {
+	if value == nil || plan.def.typ == "" {
+		return value
+	}
+	if list, ok := value.([]interface{}); ok {
+		items := make([]interface{}, len(list))
+		for i, item := range list {
+			items[i] = api.gqlExecute(plan.children, plan.def.typ, item)
+		}
+		return items
+	}
+	return api.gqlExecute(plan.children, plan.def.typ, value)
+}
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type GraphQLResponse struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*gqlError `json:"errors,omitempty"`
+}
+
+func gqlErrorResponse(err error) GraphQLResponse {
+	if gqlErr, ok := err.(*gqlError); ok {
+		return GraphQLResponse{Errors: []*gqlError{gqlErr}}
+	}
+	if syntaxErr, ok := err.(*gqlSyntaxError); ok {
+		return GraphQLResponse{Errors: []*gqlError{{Message: "Syntax Error: " + syntaxErr.message, Locations: []gqlLocation{syntaxErr.loc}}}}
+	}
+	return GraphQLResponse{Errors: []*gqlError{{Message: err.Error()}}}
+}
+
+func (api *API) executeGraphQL(request GraphQLRequest) GraphQLResponse {
+	doc, err := parseGraphQL(request.Query)
+	if err != nil {
+		return gqlErrorResponse(err)
+	}
+
+	var operation *gqlOperation
+	for _, candidate := range doc.operations {
+		if request.OperationName == "" || candidate.name == request.OperationName {
+			if operation != nil {
+				return gqlErrorResponse(&gqlError{Message: "Must provide operation name if query contains multiple operations."})
+			}
+			operation = candidate
+		}
+	}
+	if operation == nil {
+		return gqlErrorResponse(&gqlError{Message: fmt.Sprintf("Unknown operation named \"%s\".", request.OperationName)})
+	}
+	if operation.kind != "query" {
+		return gqlErrorResponse(gqlErrorf(operation.loc, "Only query operations are supported, got %s.", operation.kind))
+	}
+
+	variables, err := coerceVariables(operation, request.Variables)
+	if err != nil {
+		return gqlErrorResponse(err)
+	}
+
+	planner := &gqlPlanner{doc: doc, declared: map[string]bool{}, variables: variables, spreading: map[string]bool{}}
+	for _, definition := range operation.variables {
+		planner.declared[definition.name] = true
+	}
+	plans, err := planner.plan("Query", operation.selections, 1)
+	if err != nil {
+		return gqlErrorResponse(err)
+	}
+	if complexity := gqlComplexity(api, plans); complexity > graphqlMaxComplexity {
+		return gqlErrorResponse(gqlErrorf(operation.loc, "Query is too complex, it may resolve more than the maximum of %d fields.", graphqlMaxComplexity))
+	}
+
+	return GraphQLResponse{Data: api.gqlExecute(plans, "Query", nil)}
+}
+
+// GraphQLHandler serves GraphQL queries sent as GET parameters, a JSON body
+// or an application/graphql body.
+func (api *API) GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var request GraphQLRequest
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				returnError(w, "json", http.StatusBadRequest, "Invalid variables", err.Error())
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, graphqlMaxBodySize))
+		if err != nil {
+			returnError(w, "json", http.StatusRequestEntityTooLarge, "Request body too large", err.Error())
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/graphql" {
+			request.Query = string(body)
+		} else if err := json.Unmarshal(body, &request); err != nil {
+			returnError(w, "json", http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		returnError(w, "json", http.StatusMethodNotAllowed, "Method not allowed", "Use GET or POST")
+		return
+	}
+
+	if strings.TrimSpace(request.Query) == "" {
+		returnError(w, "json", http.StatusBadRequest, "Missing query", "The query parameter is required")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.executeGraphQL(request))
+}
+
+func (api *API) GraphQLSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, GraphQLSchema)
+}
@@ -0,0 +1,712 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Lexer and parser for GraphQL executable documents. Type system definitions
+// are not accepted, the schema lives in graphql.go.
+
+type gqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type gqlTokenKind int
+
+const (
+	gqlTokenEOF gqlTokenKind = iota
+	gqlTokenPunctuator
+	gqlTokenName
+	gqlTokenInt
+	gqlTokenFloat
+	gqlTokenString
+)
+
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	loc   gqlLocation
+}
+
+type gqlSyntaxError struct {
+	message string
+	loc     gqlLocation
+}
+
+func (e *gqlSyntaxError) Error() string {
+	return fmt.Sprintf("Syntax Error: %s (line %d, column %d)", e.message, e.loc.Line, e.loc.Column)
+}
+
+type gqlLexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+}
+
+func (l *gqlLexer) location() gqlLocation {
+	return gqlLocation{Line: l.line, Column: l.pos - l.lineStart + 1}
+}
+
+func (l *gqlLexer) errorf(format string, args ...interface{}) error {
+	return &gqlSyntaxError{message: fmt.Sprintf(format, args...), loc: l.location()}
+}
+
+func (l *gqlLexer) newline() {
+	l.line++
+	l.lineStart = l.pos
+}
+
+// skipIgnored skips whitespace, commas and comments.
+func (l *gqlLexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case ' ', '\t', ',', '\r':
+			l.pos++
+		case '\n':
+			l.pos++
+			l.newline()
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			if strings.HasPrefix(l.src[l.pos:], "\uFEFF") {
+				l.pos += len("\uFEFF")
+				continue
+			}
+			return
+		}
+	}
+}
+
+func isGQLNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isGQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *gqlLexer) next() (gqlToken, error) {
+	l.skipIgnored()
+	loc := l.location()
+	if l.pos >= len(l.src) {
+		return gqlToken{kind: gqlTokenEOF, loc: loc}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return gqlToken{kind: gqlTokenPunctuator, value: "...", loc: loc}, nil
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		l.pos++
+		return gqlToken{kind: gqlTokenPunctuator, value: string(c), loc: loc}, nil
+	case isGQLNameStart(c):
+		start := l.pos
+		for l.pos < len(l.src) && (isGQLNameStart(l.src[l.pos]) || isGQLDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return gqlToken{kind: gqlTokenName, value: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isGQLDigit(c):
+		return l.number(loc)
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		return l.blockString(loc)
+	case c == '"':
+		return l.string(loc)
+	}
+	return gqlToken{}, l.errorf("unexpected character %q", c)
+}
+
+func (l *gqlLexer) number(loc gqlLocation) (gqlToken, error) {
+	start := l.pos
+	kind := gqlTokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() {
+		for l.pos < len(l.src) && isGQLDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	digits()
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = gqlTokenFloat
+		l.pos++
+		digits()
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = gqlTokenFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		digits()
+	}
+	value := l.src[start:l.pos]
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return gqlToken{}, &gqlSyntaxError{message: fmt.Sprintf("invalid number %q", value), loc: loc}
+	}
+	return gqlToken{kind: kind, value: value, loc: loc}, nil
+}
+
+func (l *gqlLexer) string(loc gqlLocation) (gqlToken, error) {
+	l.pos++
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return gqlToken{kind: gqlTokenString, value: sb.String(), loc: loc}, nil
+		case c == '\n':
+			return gqlToken{}, l.errorf("unterminated string")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return gqlToken{}, l.errorf("unterminated string")
+			}
+			escape := l.src[l.pos+1]
+			l.pos += 2
+			switch escape {
+			case '"', '\\', '/':
+				sb.WriteByte(escape)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return gqlToken{}, l.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return gqlToken{}, l.errorf("invalid unicode escape")
+				}
+				sb.WriteRune(rune(r))
+				l.pos += 4
+			default:
+				return gqlToken{}, l.errorf("invalid escape \\%c", escape)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			sb.WriteRune(r)
+			l.pos += size
+		}
+	}
+	return gqlToken{}, l.errorf("unterminated string")
+}
+
+// blockString reads a """ string, only the escaped triple quote is unescaped
+// and the common indentation is not removed.
+func (l *gqlLexer) blockString(loc gqlLocation) (gqlToken, error) {
+	l.pos += 3
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.pos += 3
+			return gqlToken{kind: gqlTokenString, value: sb.String(), loc: loc}, nil
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			sb.WriteString(`"""`)
+			l.pos += 4
+		default:
+			c := l.src[l.pos]
+			sb.WriteByte(c)
+			l.pos++
+			if c == '\n' {
+				l.newline()
+			}
+		}
+	}
+	return gqlToken{}, l.errorf("unterminated block string")
+}
+
+type gqlVariable string
+
+type gqlEnum string
+
+type gqlArgument struct {
+	name  string
+	value interface{}
+}
+
+type gqlDirective struct {
+	name string
+	args []gqlArgument
+	loc  gqlLocation
+}
+
+// gqlSelection is a field, a fragment spread or an inline fragment.
+type gqlSelection struct {
+	alias      string
+	name       string
+	args       []gqlArgument
+	selections []gqlSelection
+
+	fragmentSpread string
+	inline         bool
+	typeCondition  string
+
+	directives []gqlDirective
+	loc        gqlLocation
+}
+
+type gqlTypeRef struct {
+	name    string
+	elem    *gqlTypeRef
+	nonNull bool
+}
+
+type gqlVariableDefinition struct {
+	name         string
+	typ          gqlTypeRef
+	defaultValue interface{}
+	hasDefault   bool
+	loc          gqlLocation
+}
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []gqlVariableDefinition
+	selections []gqlSelection
+	loc        gqlLocation
+}
+
+type gqlFragment struct {
+	name          string
+	typeCondition string
+	selections    []gqlSelection
+	loc           gqlLocation
+}
+
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+type gqlParser struct {
+	lexer *gqlLexer
+	token gqlToken
+}
+
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{lexer: &gqlLexer{src: src, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &gqlDocument{fragments: make(map[string]*gqlFragment)}
+	for p.token.kind != gqlTokenEOF {
+		switch {
+		case p.peek("{"):
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: selections, loc: selections[0].loc})
+		case p.token.kind == gqlTokenName && p.token.value == "fragment":
+			fragment, err := p.fragmentDefinition()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.fragments[fragment.name]; exists {
+				return nil, &gqlSyntaxError{message: fmt.Sprintf("there can be only one fragment named %q", fragment.name), loc: fragment.loc}
+			}
+			doc.fragments[fragment.name] = fragment
+		case p.token.kind == gqlTokenName && (p.token.value == "query" || p.token.value == "mutation" || p.token.value == "subscription"):
+			operation, err := p.operationDefinition()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, operation)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, &gqlSyntaxError{message: "document does not contain an operation", loc: p.token.loc}
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *gqlParser) peek(punctuator string) bool {
+	return p.token.kind == gqlTokenPunctuator && p.token.value == punctuator
+}
+
+func (p *gqlParser) unexpected() error {
+	if p.token.kind == gqlTokenEOF {
+		return &gqlSyntaxError{message: "unexpected end of document", loc: p.token.loc}
+	}
+	return &gqlSyntaxError{message: fmt.Sprintf("unexpected %q", p.token.value), loc: p.token.loc}
+}
+
+func (p *gqlParser) expect(punctuator string) error {
+	if !p.peek(punctuator) {
+		if p.token.kind == gqlTokenEOF {
+			return p.unexpected()
+		}
+		return &gqlSyntaxError{message: fmt.Sprintf("expected %q, found %q", punctuator, p.token.value), loc: p.token.loc}
+	}
+	return p.advance()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.token.kind != gqlTokenName {
+		return "", p.unexpected()
+	}
+	name := p.token.value
+	return name, p.advance()
+}
+
+func (p *gqlParser) operationDefinition() (*gqlOperation, error) {
+	operation := &gqlOperation{kind: p.token.value, loc: p.token.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.token.kind == gqlTokenName {
+		operation.name = p.token.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek(")") {
+			definition, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			operation.variables = append(operation.variables, definition)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	operation.selections = selections
+	return operation, nil
+}
+
+func (p *gqlParser) variableDefinition() (gqlVariableDefinition, error) {
+	definition := gqlVariableDefinition{loc: p.token.loc}
+	if err := p.expect("$"); err != nil {
+		return definition, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return definition, err
+	}
+	definition.name = name
+
+	if err := p.expect(":"); err != nil {
+		return definition, err
+	}
+	if definition.typ, err = p.typeRef(); err != nil {
+		return definition, err
+	}
+
+	if p.peek("=") {
+		if err := p.advance(); err != nil {
+			return definition, err
+		}
+		if definition.defaultValue, err = p.value(true); err != nil {
+			return definition, err
+		}
+		definition.hasDefault = true
+	}
+	_, err = p.directives()
+	return definition, err
+}
+
+func (p *gqlParser) typeRef() (gqlTypeRef, error) {
+	var typ gqlTypeRef
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return typ, err
+		}
+		elem, err := p.typeRef()
+		if err != nil {
+			return typ, err
+		}
+		typ.elem = &elem
+		if err := p.expect("]"); err != nil {
+			return typ, err
+		}
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return typ, err
+		}
+		typ.name = name
+	}
+
+	if p.peek("!") {
+		typ.nonNull = true
+		return typ, p.advance()
+	}
+	return typ, nil
+}
+
+func (p *gqlParser) fragmentDefinition() (*gqlFragment, error) {
+	fragment := &gqlFragment{loc: p.token.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, &gqlSyntaxError{message: `unexpected name "on"`, loc: fragment.loc}
+	}
+	fragment.name = name
+
+	if p.token.kind != gqlTokenName || p.token.value != "on" {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if fragment.typeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	if fragment.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return fragment, nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []gqlSelection
+	for !p.peek("}") {
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, &gqlSyntaxError{message: "selection set cannot be empty", loc: p.token.loc}
+	}
+	return selections, p.advance()
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	selection := gqlSelection{loc: p.token.loc}
+	var err error
+
+	if p.peek("...") {
+		if err := p.advance(); err != nil {
+			return selection, err
+		}
+		if p.token.kind == gqlTokenName && p.token.value != "on" {
+			selection.fragmentSpread = p.token.value
+			if err := p.advance(); err != nil {
+				return selection, err
+			}
+			selection.directives, err = p.directives()
+			return selection, err
+		}
+
+		selection.inline = true
+		if p.token.kind == gqlTokenName {
+			if err := p.advance(); err != nil {
+				return selection, err
+			}
+			if selection.typeCondition, err = p.name(); err != nil {
+				return selection, err
+			}
+		}
+		if selection.directives, err = p.directives(); err != nil {
+			return selection, err
+		}
+		selection.selections, err = p.selectionSet()
+		return selection, err
+	}
+
+	if selection.name, err = p.name(); err != nil {
+		return selection, err
+	}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return selection, err
+		}
+		selection.alias = selection.name
+		if selection.name, err = p.name(); err != nil {
+			return selection, err
+		}
+	}
+
+	if selection.args, err = p.arguments(); err != nil {
+		return selection, err
+	}
+	if selection.directives, err = p.directives(); err != nil {
+		return selection, err
+	}
+	if p.peek("{") {
+		selection.selections, err = p.selectionSet()
+	}
+	return selection, err
+}
+
+func (p *gqlParser) arguments() ([]gqlArgument, error) {
+	if !p.peek("(") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var args []gqlArgument
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, gqlArgument{name: name, value: value})
+	}
+	return args, p.advance()
+}
+
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var directives []gqlDirective
+	for p.peek("@") {
+		directive := gqlDirective{loc: p.token.loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		directive.name = name
+		if directive.args, err = p.arguments(); err != nil {
+			return nil, err
+		}
+		directives = append(directives, directive)
+	}
+	return directives, nil
+}
+
+// value parses a literal. Variables are returned as gqlVariable and are not
+// allowed in constant positions such as default values.
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	token := p.token
+	switch token.kind {
+	case gqlTokenInt:
+		n, err := strconv.Atoi(token.value)
+		if err != nil {
+			return nil, &gqlSyntaxError{message: fmt.Sprintf("integer %s out of range", token.value), loc: token.loc}
+		}
+		return n, p.advance()
+	case gqlTokenFloat:
+		f, _ := strconv.ParseFloat(token.value, 64)
+		return f, p.advance()
+	case gqlTokenString:
+		return token.value, p.advance()
+	case gqlTokenName:
+		var value interface{}
+		switch token.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = gqlEnum(token.value)
+		}
+		return value, p.advance()
+	}
+
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return gqlVariable(name), err
+	case p.peek("["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek("]") {
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.advance()
+	case p.peek("{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		object := map[string]interface{}{}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.advance()
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postGraphQL(t *testing.T, api *API, request GraphQLRequest) string {
+	t.Helper()
+	body, _ := json.Marshal(request)
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	return strings.TrimSpace(rec.Body.String())
+}
+
+func TestGraphQLNestedQuery(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	got := postGraphQL(t, api, GraphQLRequest{Query: `
+		query Nested($id: Int!) {
+			quote(id: $id) {
+				id
+				author { name ...AuthorQuotes }
+				tags { name totalQuotes }
+			}
+			missing: quote(id: 99) { id }
+		}
+		fragment AuthorQuotes on Author {
+			id
+			quotes(pageSize: 1, page: 2) {
+				items { id __typename }
+				pageInfo { page total hasNextPage }
+			}
+		}`,
+		Variables: map[string]interface{}{"id": 0},
+	})
+
+	want := `{"data":{"quote":{"id":0,"author":{"name":"Ann Example","id":"Ann+Example","quotes":{"items":[{"id":2,"__typename":"Quote"}],"pageInfo":{"page":2,"total":2,"hasNextPage":false}}},"tags":[{"name":"food","totalQuotes":1},{"name":"love","totalQuotes":2}]},"missing":null}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestGraphQLGetAndDirectives(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	query := url.Values{
+		"query":     {`query ($full: Boolean!) { tags(pageSize: 2) { items { name quotes @include(if: $full) { pageInfo { total } } } } }`},
+		"variables": {`{"full": false}`},
+	}
+	rec := serveTestRequest(api, "/graphql?"+query.Encode())
+
+	want := `{"data":{"tags":{"items":[{"name":"food"},{"name":"love"}]}}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{"syntax", `{ quote(id: 1) { text }`, "Syntax Error"},
+		{"unknown field", `{ quote(id: 1) { body } }`, `Cannot query field \"body\" on type \"Quote\"`},
+		{"missing argument", `{ quote { text } }`, `argument \"id\" of type \"Int!\" is required`},
+		{"invalid argument", `{ quote(id: "one") { text } }`, `expected type \"Int\"`},
+		{"undefined variable", `{ quote(id: $id) { text } }`, `Variable \"$id\" is not defined`},
+		{"scalar selection", `{ quote(id: 1) { text { length } } }`, "must not have a selection"},
+		{"mutation", `mutation { quote(id: 1) { text } }`, "Only query operations are supported"},
+		{"fragment cycle", `{ quote(id: 1) { ...A } } fragment A on Quote { ...A }`, "within itself"},
+		{"depth", `{ quote(id: 0) { author { quotes { items { author { quotes { items { author { quotes { items { author { id } } } } } } } } } } } }`, "maximum depth"},
+		{"complexity", `{ quotes(pageSize: 100) { items { author { quotes(pageSize: 100) { items { text } } } } } }`, "too complex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := postGraphQL(t, api, GraphQLRequest{Query: tt.query})
+			if !strings.HasPrefix(got, `{"errors":[{"message":`) || !strings.Contains(got, tt.message) {
+				t.Errorf("got %s, want an error containing %q", got, tt.message)
+			}
+		})
+	}
+}
+
+func TestGraphQLPageSizeLimit(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	got := postGraphQL(t, api, GraphQLRequest{Query: `{ quotes(pageSize: 100000) { pageInfo { pageSize } } }`})
+	if want := `{"data":{"quotes":{"pageInfo":{"pageSize":100}}}}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestGraphQLFragmentExpansionLimit(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	// Each fragment spreads the next twice, 2^30 selections when inlined.
+	var sb strings.Builder
+	sb.WriteString("{ ...F0 }\n")
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&sb, "fragment F%d on Query { ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	sb.WriteString("fragment F30 on Query { quote(id: 1) { text } }\n")
+
+	started := time.Now()
+	got := postGraphQL(t, api, GraphQLRequest{Query: sb.String()})
+	if !strings.Contains(got, "maximum of 2000 selections") {
+		t.Errorf("got %s, want the selection limit error", got)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("rejecting the query took %v", elapsed)
+	}
+}
+
+// TestGraphQLSchemaInSync checks that the SDL served at /graphql/schema has
+// the types, fields and arguments of gqlSchema, and nothing more.
+func TestGraphQLSchemaInSync(t *testing.T) {
+	typeRe := regexp.MustCompile(`(?m)^type (\w+) \{\n((?:  .*\n)*)\}`)
+	fieldRe := regexp.MustCompile(`^  (\w+)(?:\((.*)\))?: (\[?(\w+)!?\]?!?)$`)
+
+	documented := map[string]map[string]string{}
+	for _, match := range typeRe.FindAllStringSubmatch(GraphQLSchema, -1) {
+		fields := map[string]string{}
+		for _, line := range strings.Split(strings.TrimSuffix(match[2], "\n"), "\n") {
+			field := fieldRe.FindStringSubmatch(line)
+			if field == nil {
+				t.Errorf("type %s: cannot parse %q", match[1], line)
+				continue
+			}
+			fields[field[1]] = field[2] + "|" + field[4]
+		}
+		documented[match[1]] = fields
+	}
+
+	scalars := map[string]bool{"Int": true, "String": true, "Boolean": true}
+	for typeName, objectType := range gqlSchema {
+		fields, ok := documented[typeName]
+		if !ok {
+			t.Errorf("type %s is missing from GraphQLSchema", typeName)
+			continue
+		}
+		for fieldName, def := range objectType {
+			signature, ok := fields[fieldName]
+			if !ok {
+				t.Errorf("field %s.%s is missing from GraphQLSchema", typeName, fieldName)
+				continue
+			}
+			args, returnType, _ := strings.Cut(signature, "|")
+			if def.typ != "" && def.typ != returnType {
+				t.Errorf("field %s.%s has type %s in GraphQLSchema, want %s", typeName, fieldName, returnType, def.typ)
+			}
+			if def.typ == "" && !scalars[returnType] {
+				t.Errorf("field %s.%s is a scalar but has type %s in GraphQLSchema", typeName, fieldName, returnType)
+			}
+
+			documentedArgs := map[string]string{}
+			if args != "" {
+				for _, arg := range strings.Split(args, ", ") {
+					name, argType, _ := strings.Cut(arg, ": ")
+					argType, _, _ = strings.Cut(argType, " = ")
+					documentedArgs[name] = argType
+				}
+			}
+			for argName, argDef := range def.args {
+				want := argDef.typ
+				if argDef.required {
+					want += "!"
+				}
+				if documentedArgs[argName] != want {
+					t.Errorf("argument %s.%s(%s) is %q in GraphQLSchema, want %q", typeName, fieldName, argName, documentedArgs[argName], want)
+				}
+				delete(documentedArgs, argName)
+			}
+			for argName := range documentedArgs {
+				t.Errorf("argument %s.%s(%s) is not in gqlSchema", typeName, fieldName, argName)
+			}
+			delete(fields, fieldName)
+		}
+		for fieldName := range fields {
+			t.Errorf("field %s.%s is not in gqlSchema", typeName, fieldName)
+		}
+		delete(documented, typeName)
+	}
+	for typeName := range documented {
+		t.Errorf("type %s is not in gqlSchema", typeName)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	quotespb "go_quote/proto"
+)
+
+// quoteServer implements the gRPC QuoteService on top of the same in-memory
+// data as the HTTP handlers.
+type quoteServer struct {
+	quotespb.UnimplementedQuoteServiceServer
+	api *API
+}
+
+// NewGRPCServer returns a server with the QuoteService, the health service
+// and server reflection registered.
+func NewGRPCServer(api *API) *grpc.Server {
+	server := grpc.NewServer()
+	quotespb.RegisterQuoteServiceServer(server, &quoteServer{api: api})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(quotespb.QuoteService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+func (s *quoteServer) protoQuote(id int) *quotespb.Quote {
+	quote := s.api.Quotes[id].CreateResponseQuote(id)
+	return &quotespb.Quote{
+		Id:       int64(quote.ID),
+		Text:     quote.Text,
+		Author:   quote.Author,
+		AuthorId: quote.AuthorID,
+		Tags:     quote.Tags,
+		Source:   quote.Source,
+		Year:     int32(quote.Year),
+		Language: quote.Language,
+		Url:      quote.URL,
+		Verified: quote.Verified,
+	}
+}
+
+func protoPagination(pagination Pagination) *quotespb.Pagination {
+	return &quotespb.Pagination{
+		Page:     int64(pagination.Page),
+		PageSize: int64(pagination.PageSize),
+		Total:    int64(pagination.Total),
+		Pages:    int64(pagination.Pages),
+	}
+}
+
+// quoteIDs returns the quotes of a tag or an author, nil means all quotes.
+func (s *quoteServer) quoteIDs(tag, authorID string) ([]int, error) {
+	switch {
+	case tag != "" && authorID != "":
+		return nil, status.Error(codes.InvalidArgument, "use either tag or author_id")
+	case tag != "":
+		if ids := s.api.Tags.NameToQuotes[tag]; len(ids) > 0 {
+			return ids, nil
+		}
+		return nil, status.Errorf(codes.NotFound, "tag %q not found", tag)
+	case authorID != "":
+		if ids := s.api.Authors.NameToQuotes[authorID]; len(ids) > 0 {
+			return ids, nil
+		}
+		return nil, status.Errorf(codes.NotFound, "author %q not found", authorID)
+	}
+	return nil, nil
+}
+
+func (s *quoteServer) GetQuote(ctx context.Context, req *quotespb.GetQuoteRequest) (*quotespb.Quote, error) {
+	if req.Id < 0 || req.Id >= int64(len(s.api.Quotes)) {
+		return nil, status.Errorf(codes.NotFound, "quote %d not found", req.Id)
+	}
+	return s.protoQuote(int(req.Id)), nil
+}
+
+func (s *quoteServer) RandomQuote(ctx context.Context, req *quotespb.RandomQuoteRequest) (*quotespb.Quote, error) {
+	if _, err := s.quoteIDs(req.Tag, req.AuthorId); err != nil {
+		return nil, err
+	}
+	quoteID, ok := s.api.randomQuoteID(req.Tag, req.AuthorId)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no quotes available")
+	}
+	return s.protoQuote(quoteID), nil
+}
+
+func (s *quoteServer) ListQuotes(req *quotespb.ListQuotesRequest, stream grpc.ServerStreamingServer[quotespb.Quote]) error {
+	if req.Offset < 0 || req.Limit < 0 {
+		return status.Error(codes.InvalidArgument, "offset and limit must not be negative")
+	}
+	ids, err := s.quoteIDs(req.Tag, req.AuthorId)
+	if err != nil {
+		return err
+	}
+
+	total := int64(len(s.api.Quotes))
+	if ids != nil {
+		total = int64(len(ids))
+	}
+	end := total
+	if req.Limit > 0 && req.Offset+req.Limit < total {
+		end = req.Offset + req.Limit
+	}
+
+	for i := req.Offset; i < end; i++ {
+		quoteID := int(i)
+		if ids != nil {
+			quoteID = ids[i]
+		}
+		if err := stream.Send(s.protoQuote(quoteID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *quoteServer) ListAuthors(ctx context.Context, req *quotespb.ListAuthorsRequest) (*quotespb.ListAuthorsResponse, error) {
+	pagination := s.api.paginate(s.api.Authors.Len(), int(req.Page), int(req.PageSize))
+	start, end, capacity := calculateSafeIndices(s.api.Authors.Len(), pagination)
+
+	authors := make([]*quotespb.Author, 0, capacity)
+	for _, encodedName := range s.api.Authors.Names[start:end] {
+		decodedName, _ := url.QueryUnescape(encodedName)
+		authors = append(authors, &quotespb.Author{
+			AuthorId:    encodedName,
+			Name:        decodedName,
+			TotalQuotes: int64(len(s.api.Authors.NameToQuotes[encodedName])),
+		})
+	}
+	return &quotespb.ListAuthorsResponse{Authors: authors, Pagination: protoPagination(pagination)}, nil
+}
+
+func (s *quoteServer) ListTags(ctx context.Context, req *quotespb.ListTagsRequest) (*quotespb.ListTagsResponse, error) {
+	pagination := s.api.paginate(s.api.Tags.Len(), int(req.Page), int(req.PageSize))
+	start, end, capacity := calculateSafeIndices(s.api.Tags.Len(), pagination)
+
+	tags := make([]*quotespb.Tag, 0, capacity)
+	for _, name := range s.api.Tags.Names[start:end] {
+		tags = append(tags, &quotespb.Tag{
+			Name:        name,
+			TotalQuotes: int64(len(s.api.Tags.NameToQuotes[name])),
+		})
+	}
+	return &quotespb.ListTagsResponse{Tags: tags, Pagination: protoPagination(pagination)}, nil
+}
+
+func (s *quoteServer) Search(ctx context.Context, req *quotespb.SearchRequest) (*quotespb.SearchResponse, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+
+	ids := s.api.searchQuoteIDs(query)
+	pagination := s.api.paginate(len(ids), int(req.Page), int(req.PageSize))
+	start, end, capacity := calculateSafeIndices(len(ids), pagination)
+
+	quotes := make([]*quotespb.Quote, 0, capacity)
+	for _, quoteID := range ids[start:end] {
+		quotes = append(quotes, s.protoQuote(quoteID))
+	}
+	return &quotespb.SearchResponse{Quotes: quotes, Pagination: protoPagination(pagination)}, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	quotespb "go_quote/proto"
+)
+
+func dialTestGRPC(t *testing.T, api *API) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(api)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCGetQuote(t *testing.T) {
+	client := quotespb.NewQuoteServiceClient(dialTestGRPC(t, newTestAPI(testQuotes)))
+	ctx := context.Background()
+
+	quote, err := client.GetQuote(ctx, &quotespb.GetQuoteRequest{Id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Id != 1 || quote.Author != "Bob Example" || quote.AuthorId != "Bob+Example" || len(quote.Tags) != 1 {
+		t.Errorf("unexpected quote %v", quote)
+	}
+
+	_, err = client.GetQuote(ctx, &quotespb.GetQuoteRequest{Id: 99})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetQuote(99) error = %v, want NotFound", err)
+	}
+
+	quote, err = client.RandomQuote(ctx, &quotespb.RandomQuoteRequest{AuthorId: "Bob+Example"})
+	if err != nil || quote.Id != 1 {
+		t.Errorf("RandomQuote(Bob) = %v, %v", quote, err)
+	}
+	_, err = client.RandomQuote(ctx, &quotespb.RandomQuoteRequest{Tag: "love", AuthorId: "Bob+Example"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("RandomQuote(tag and author) error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPCListQuotes(t *testing.T) {
+	client := quotespb.NewQuoteServiceClient(dialTestGRPC(t, newTestAPI(testQuotes)))
+
+	tests := []struct {
+		request *quotespb.ListQuotesRequest
+		want    []int64
+	}{
+		{&quotespb.ListQuotesRequest{}, []int64{0, 1, 2}},
+		{&quotespb.ListQuotesRequest{Offset: 1, Limit: 1}, []int64{1}},
+		{&quotespb.ListQuotesRequest{AuthorId: "Ann+Example", Offset: 1}, []int64{2}},
+		{&quotespb.ListQuotesRequest{Tag: "love"}, []int64{0, 1}},
+		{&quotespb.ListQuotesRequest{Offset: 5}, nil},
+	}
+
+	for _, tt := range tests {
+		stream, err := client.ListQuotes(context.Background(), tt.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for {
+			quote, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, quote.Id)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ListQuotes(%v) = %v, want %v", tt.request, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ListQuotes(%v) = %v, want %v", tt.request, got, tt.want)
+				break
+			}
+		}
+	}
+
+	stream, _ := client.ListQuotes(context.Background(), &quotespb.ListQuotesRequest{Tag: "missing"})
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("ListQuotes(missing tag) error = %v, want NotFound", err)
+	}
+}
+
+func TestGRPCListAndSearch(t *testing.T) {
+	conn := dialTestGRPC(t, newTestAPI(testQuotes))
+	client := quotespb.NewQuoteServiceClient(conn)
+	ctx := context.Background()
+
+	authors, err := client.ListAuthors(ctx, &quotespb.ListAuthorsRequest{Page: 2, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authors.Authors) != 1 || authors.Authors[0].Name != "Bob Example" || authors.Pagination.Pages != 2 {
+		t.Errorf("unexpected authors %v", authors)
+	}
+
+	tags, err := client.ListTags(ctx, &quotespb.ListTagsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.Tags) != 3 || tags.Tags[1].Name != "love" || tags.Tags[1].TotalQuotes != 2 {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	results, err := client.Search(ctx, &quotespb.SearchRequest{Query: "ANN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Pagination.Total != 2 || results.Quotes[0].Id != 0 || results.Quotes[1].Id != 2 {
+		t.Errorf("unexpected search results %v", results)
+	}
+	if _, err := client.Search(ctx, &quotespb.SearchRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Search(empty) error = %v, want InvalidArgument", err)
+	}
+
+	health, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "goquote.v1.QuoteService"})
+	if err != nil || health.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health check = %v, %v", health, err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type HALLink struct {
+	Href string `json:"href"`
+	Name string `json:"name,omitempty"`
+}
+
+type HALQuoteLinks struct {
+	Self   HALLink   `json:"self"`
+	Author HALLink   `json:"author"`
+	Tags   []HALLink `json:"tags"`
+	Source *HALLink  `json:"source,omitempty"`
+}
+
+type HALQuote struct {
+	Links HALQuoteLinks `json:"_links"`
+	ResponseQuote
+}
+
+type HALPageLinks struct {
+	Self  HALLink  `json:"self"`
+	First HALLink  `json:"first"`
+	Last  HALLink  `json:"last"`
+	Prev  *HALLink `json:"prev,omitempty"`
+	Next  *HALLink `json:"next,omitempty"`
+}
+
+type HALSelfLinks struct {
+	Self HALLink `json:"self"`
+}
+
+type HALQuotesResponse struct {
+	Links    HALPageLinks `json:"_links"`
+	Embedded struct {
+		Quotes []HALQuote `json:"quotes"`
+	} `json:"_embedded"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type HALAuthorQuotesResponse struct {
+	Links    HALPageLinks `json:"_links"`
+	Embedded struct {
+		Quotes []HALQuote `json:"quotes"`
+	} `json:"_embedded"`
+	Author      string     `json:"author"`
+	AuthorID    string     `json:"author_id"`
+	TotalQuotes int        `json:"total_quotes"`
+	Pagination  Pagination `json:"pagination"`
+}
+
+type HALTag struct {
+	Links HALSelfLinks `json:"_links"`
+	TagResponse
+}
+
+type HALTagsResponse struct {
+	Links    HALPageLinks `json:"_links"`
+	Embedded struct {
+		Tags []HALTag `json:"tags"`
+	} `json:"_embedded"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type HALAuthor struct {
+	Links HALSelfLinks `json:"_links"`
+	AuthorResponse
+}
+
+type HALAuthorsResponse struct {
+	Links    HALPageLinks `json:"_links"`
+	Embedded struct {
+		Authors []HALAuthor `json:"authors"`
+	} `json:"_embedded"`
+	Pagination Pagination `json:"pagination"`
+}
+
+func halQuoteLinks(quote ResponseQuote, baseURL string) HALQuoteLinks {
+	links := HALQuoteLinks{
+		Self:   HALLink{Href: fmt.Sprintf("%s/quotes/%d", baseURL, quote.ID)},
+		Author: HALLink{Href: baseURL + "/authors/" + quote.AuthorID, Name: quote.Author},
+		Tags:   make([]HALLink, 0, len(quote.Tags)),
+	}
+	for _, tag := range quote.Tags {
+		links.Tags = append(links.Tags, HALLink{Href: halTagURL(baseURL, tag), Name: tag})
+	}
+	if quote.URL != "" {
+		links.Source = &HALLink{Href: quote.URL, Name: quote.Citation()}
+	}
+	return links
+}
+
+func halTagURL(baseURL, tag string) string {
+	return baseURL + "/tags/" + url.PathEscape(tag)
+}
+
+func halQuote(quote ResponseQuote, baseURL string) HALQuote {
+	return HALQuote{
+		Links:         halQuoteLinks(quote, baseURL),
+		ResponseQuote: quote,
+	}
+}
+
+func halQuotes(quotes []ResponseQuote, baseURL string) []HALQuote {
+	result := make([]HALQuote, 0, len(quotes))
+	for _, quote := range quotes {
+		result = append(result, halQuote(quote, baseURL))
+	}
+	return result
+}
+
+func halPageLinks(baseURL string, u *url.URL, pagination Pagination) HALPageLinks {
+	links := buildPageLinks(baseURL, u, pagination)
+	halLinks := HALPageLinks{
+		Self:  HALLink{Href: links.Self},
+		First: HALLink{Href: links.First},
+		Last:  HALLink{Href: links.Last},
+	}
+	if links.Prev != "" {
+		halLinks.Prev = &HALLink{Href: links.Prev}
+	}
+	if links.Next != "" {
+		halLinks.Next = &HALLink{Href: links.Next}
+	}
+	return halLinks
+}
+
+func serveHALJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/hal+json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func serveHALQuote(w http.ResponseWriter, q ResponseQuote, api *API, responseInfo *ResponseInfo) {
+	serveHALJSON(w, halQuote(q, responseInfo.BaseURL))
+}
+
+func (api *API) serveHALQuotes(w http.ResponseWriter, r *http.Request, response PaginatedQuotesResponse) {
+	responseInfo := getResponseInfo(r, -1, createRequestData(r, api))
+	setPaginationHeaders(w, response.Pagination)
+
+	halResponse := HALQuotesResponse{
+		Links:      halPageLinks(responseInfo.BaseURL, r.URL, response.Pagination),
+		Pagination: response.Pagination,
+	}
+	halResponse.Embedded.Quotes = halQuotes(response.Quotes, responseInfo.BaseURL)
+	serveHALJSON(w, halResponse)
+}
+
+func (api *API) serveHALAuthorQuotes(w http.ResponseWriter, r *http.Request, response PaginatedAuthorResponse) {
+	responseInfo := getResponseInfo(r, -1, createRequestData(r, api))
+	setPaginationHeaders(w, response.Pagination)
+
+	halResponse := HALAuthorQuotesResponse{
+		Links:       halPageLinks(responseInfo.BaseURL, r.URL, response.Pagination),
+		Author:      response.Author,
+		AuthorID:    response.AuthorID,
+		TotalQuotes: response.TotalQuotes,
+		Pagination:  response.Pagination,
+	}
+	halResponse.Embedded.Quotes = halQuotes(response.Quotes, responseInfo.BaseURL)
+	serveHALJSON(w, halResponse)
+}
+
+func (api *API) serveHALTags(w http.ResponseWriter, r *http.Request, response PaginatedTagsResponse) {
+	responseInfo := getResponseInfo(r, -1, createRequestData(r, api))
+	setPaginationHeaders(w, response.Pagination)
+
+	halResponse := HALTagsResponse{
+		Links:      halPageLinks(responseInfo.BaseURL, r.URL, response.Pagination),
+		Pagination: response.Pagination,
+	}
+	halResponse.Embedded.Tags = make([]HALTag, 0, len(response.Tags))
+	for _, tag := range response.Tags {
+		halResponse.Embedded.Tags = append(halResponse.Embedded.Tags, HALTag{
+			Links:       HALSelfLinks{Self: HALLink{Href: halTagURL(responseInfo.BaseURL, tag.TagID)}},
+			TagResponse: tag,
+		})
+	}
+	serveHALJSON(w, halResponse)
+}
+
+func (api *API) serveHALAuthors(w http.ResponseWriter, r *http.Request, response PaginatedAuthorsResponse) {
+	responseInfo := getResponseInfo(r, -1, createRequestData(r, api))
+	setPaginationHeaders(w, response.Pagination)
+
+	halResponse := HALAuthorsResponse{
+		Links:      halPageLinks(responseInfo.BaseURL, r.URL, response.Pagination),
+		Pagination: response.Pagination,
+	}
+	halResponse.Embedded.Authors = make([]HALAuthor, 0, len(response.Authors))
+	for _, author := range response.Authors {
+		halResponse.Embedded.Authors = append(halResponse.Embedded.Authors, HALAuthor{
+			Links:          HALSelfLinks{Self: HALLink{Href: responseInfo.BaseURL + "/authors/" + author.AuthorID}},
+			AuthorResponse: author,
+		})
+	}
+	serveHALJSON(w, halResponse)
+}
+
+func streamQuotesHAL(w http.ResponseWriter, api *API, RequestDataList *RequestDataList) {
+	setPaginationHeaders(w, RequestDataList.Pagination)
+
+	approximateSize := RequestDataList.Total*400 + 500
+	writer, closeWriter := api.streamWriter(w, RequestDataList, approximateSize)
+	defer closeWriter()
+
+	w.Header().Set("Content-Type", "application/hal+json")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(writer)
+
+	if _, err := writer.Write([]byte(`{"_links":`)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := enc.Encode(halPageLinks(RequestDataList.BaseURL, RequestDataList.URL, RequestDataList.Pagination)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := writer.Write([]byte(`,"_embedded":{"quotes":[`)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := RequestDataList.StartIndex; i < RequestDataList.EndIndex; i++ {
+		if i > RequestDataList.StartIndex {
+			if _, err := writer.Write([]byte(",")); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := enc.Encode(halQuote(api.Quotes[i].CreateResponseQuote(i), RequestDataList.BaseURL)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if _, err := writer.Write([]byte(`]},"pagination":`)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := enc.Encode(RequestDataList.Pagination); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := writer.Write([]byte("}")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := writer.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
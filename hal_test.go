@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHALQuote(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	rec := serveTestRequest(api, "http://go-quote.com/quotes/1?format=hal")
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/hal+json" {
+		t.Fatalf("Content-Type = %q, want application/hal+json", ct)
+	}
+
+	var quote HALQuote
+	if err := json.Unmarshal(rec.Body.Bytes(), &quote); err != nil {
+		t.Fatalf("Failed to parse HAL: %v", err)
+	}
+	if quote.Links.Self.Href != "http://go-quote.com/quotes/1" {
+		t.Errorf("self = %q", quote.Links.Self.Href)
+	}
+	if quote.Links.Author.Href != "http://go-quote.com/authors/Bob+Example" {
+		t.Errorf("author = %q", quote.Links.Author.Href)
+	}
+	if len(quote.Links.Tags) != 1 || quote.Links.Tags[0].Href != "http://go-quote.com/tags/love" {
+		t.Errorf("tags = %+v", quote.Links.Tags)
+	}
+	if quote.Text != "Second quote" {
+		t.Errorf("text = %q", quote.Text)
+	}
+}
+
+func TestHALPageLinks(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantPrev string
+		wantNext string
+		wantLast string
+	}{
+		{
+			name:     "Streaming quotes first page",
+			url:      "http://go-quote.com/quotes?format=hal&page_size=1",
+			wantNext: "http://go-quote.com/quotes?format=hal&page=2&page_size=1",
+			wantLast: "http://go-quote.com/quotes?format=hal&page=3&page_size=1",
+		},
+		{
+			name:     "Tag quotes last page",
+			url:      "http://go-quote.com/tags/love?format=hal&page=2&page_size=1",
+			wantPrev: "http://go-quote.com/tags/love?format=hal&page=1&page_size=1",
+			wantLast: "http://go-quote.com/tags/love?format=hal&page=2&page_size=1",
+		},
+		{
+			name:     "Authors middle page",
+			url:      "http://go-quote.com/authors?format=hal&page=2&page_size=1",
+			wantPrev: "http://go-quote.com/authors?format=hal&page=1&page_size=1",
+			wantLast: "http://go-quote.com/authors?format=hal&page=2&page_size=1",
+		},
+		{
+			name:     "Tags via Accept header",
+			url:      "http://go-quote.com/tags?page_size=2",
+			wantNext: "http://go-quote.com/tags?page=2&page_size=2",
+			wantLast: "http://go-quote.com/tags?page=2&page_size=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveTestRequestAccept(api, tt.url, "application/hal+json")
+
+			var response struct {
+				Links HALPageLinks `json:"_links"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse HAL: %v\n%s", err, rec.Body.String())
+			}
+
+			var prev, next string
+			if response.Links.Prev != nil {
+				prev = response.Links.Prev.Href
+			}
+			if response.Links.Next != nil {
+				next = response.Links.Next.Href
+			}
+			if prev != tt.wantPrev {
+				t.Errorf("prev = %q, want %q", prev, tt.wantPrev)
+			}
+			if next != tt.wantNext {
+				t.Errorf("next = %q, want %q", next, tt.wantNext)
+			}
+			if response.Links.Last.Href != tt.wantLast {
+				t.Errorf("last = %q, want %q", response.Links.Last.Href, tt.wantLast)
+			}
+		})
+	}
+}
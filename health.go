@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+type HealthTTS struct {
+	Backend   string `json:"backend"`
+	Available bool   `json:"available"`
+}
+
+type HealthDataset struct {
+	Version string    `json:"version,omitempty"`
+	Updated time.Time `json:"updated"`
+	Quotes  int       `json:"quotes"`
+}
+
+type HealthStatus struct {
+	Status  string         `json:"status"`
+	Dataset *HealthDataset `json:"dataset,omitempty"`
+	TTS     *HealthTTS     `json:"tts,omitempty"`
+}
+
+// ttsBackend returns the speech command used on the given runtime, see
+// serveAudioQuote.
+func ttsBackend(runtime string) string {
+	if runtime == "darwin" {
+		return "say"
+	}
+	return "pico2wave"
+}
+
+func writeHealth(w http.ResponseWriter, status int, health HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(health)
+}
+
+// HealthzHandler is the liveness probe, it answers as soon as the server
+// listens, also while the data is loading.
+func (api *API) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, HealthStatus{Status: "ok"})
+}
+
+// ReadyzHandler is the readiness probe, it answers 503 until the quotes are
+// loaded and indexed. Speech is optional and only reported.
+func (api *API) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	backend := ttsBackend(api.Runtime)
+	_, err := exec.LookPath(backend)
+	tts := &HealthTTS{Backend: backend, Available: err == nil}
+
+	if api.loading.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, HealthStatus{Status: "loading", TTS: tts})
+		return
+	}
+	dataset := &HealthDataset{Updated: api.DataUpdated, Quotes: len(api.Quotes)}
+	if api.Config != nil {
+		dataset.Version = api.dataChecksum()
+	}
+	writeHealth(w, http.StatusOK, HealthStatus{Status: "ready", Dataset: dataset, TTS: tts})
+}
+
+// readinessMiddleware answers 503 for everything but the probes while the
+// data is loading, the handlers expect the quotes and indexes to be there.
+func (api *API) readinessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.loading.Load() && r.URL.Path != "/healthz" && r.URL.Path != "/readyz" {
+			w.Header().Set("Retry-After", "5")
+			returnError(w, getOutputFormat(r), http.StatusServiceUnavailable, "Loading", "The quotes are still being loaded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthProbes(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	api.loading.Store(true)
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	handler := api.SetupMiddleware()(mux)
+
+	serve := func(url string) (*httptest.ResponseRecorder, HealthStatus) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		var health HealthStatus
+		json.Unmarshal(rec.Body.Bytes(), &health)
+		return rec, health
+	}
+
+	if rec, health := serve("/healthz"); rec.Code != http.StatusOK || health.Status != "ok" {
+		t.Errorf("/healthz while loading = %d %+v", rec.Code, health)
+	}
+	rec, health := serve("/readyz")
+	if rec.Code != http.StatusServiceUnavailable || health.Status != "loading" || health.Dataset != nil {
+		t.Errorf("/readyz while loading = %d %+v", rec.Code, health)
+	}
+	if health.TTS == nil || health.TTS.Backend != ttsBackend(api.Runtime) {
+		t.Errorf("/readyz tts = %+v", health.TTS)
+	}
+	if rec, _ := serve("/quotes/1"); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("/quotes/1 while loading = %d, want 503 with Retry-After", rec.Code)
+	}
+
+	api.loading.Store(false)
+	rec, health = serve("/readyz")
+	if rec.Code != http.StatusOK || health.Status != "ready" {
+		t.Fatalf("/readyz = %d %+v", rec.Code, health)
+	}
+	if health.Dataset == nil || health.Dataset.Quotes != len(testQuotes) {
+		t.Errorf("/readyz dataset = %+v", health.Dataset)
+	}
+	if rec, _ := serve("/quotes/1"); rec.Code != http.StatusOK {
+		t.Errorf("/quotes/1 once ready = %d", rec.Code)
+	}
+}
+
+func TestTTSBackend(t *testing.T) {
+	if backend := ttsBackend("darwin"); backend != "say" {
+		t.Errorf("ttsBackend(darwin) = %q", backend)
+	}
+	if backend := ttsBackend("linux"); backend != "pico2wave" {
+		t.Errorf("ttsBackend(linux) = %q", backend)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// HNSWIndex is a hierarchical navigable small world graph over the quote
+// embeddings for approximate nearest neighbour search by cosine distance.
+// See Malkov and Yashunin, https://arxiv.org/abs/1603.09320.
+
+const (
+	hnswM              = 16
+	hnswEfConstruction = 200
+	hnswEfSearch       = 64
+)
+
+type hnswNode struct {
+	quote int
+	// friends holds the neighbours of the node per level, level 0 first.
+	friends [][]int32
+}
+
+type HNSWIndex struct {
+	dimensions int
+	// vectors holds the normalised vector of node i at i*dimensions.
+	vectors  []float32
+	nodes    []hnswNode
+	byQuote  map[int]int32
+	entry    int32
+	maxLevel int
+
+	levelFactor float64
+	rng         *rand.Rand
+}
+
+type hnswCandidate struct {
+	node     int32
+	distance float32
+}
+
+// hnswHeap is a min heap of candidates, or a max heap when farthest is set.
+type hnswHeap struct {
+	items    []hnswCandidate
+	farthest bool
+}
+
+func (h *hnswHeap) Len() int { return len(h.items) }
+func (h *hnswHeap) Less(i, j int) bool {
+	if h.farthest {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+func (h *hnswHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *hnswHeap) Push(x interface{}) { h.items = append(h.items, x.(hnswCandidate)) }
+func (h *hnswHeap) Pop() interface{} {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// BuildHNSWIndex inserts the embeddings of the quotes into a new graph.
+// Embeddings of quotes that do not exist are an error, zero vectors are
+// left out.
+func BuildHNSWIndex(embeddings *Embeddings, quoteCount int) (*HNSWIndex, error) {
+	if len(embeddings.Vectors) > quoteCount {
+		return nil, fmt.Errorf("embedding for quote %d, but there are %d quotes", len(embeddings.Vectors)-1, quoteCount)
+	}
+	index := &HNSWIndex{
+		dimensions:  embeddings.Dimensions,
+		byQuote:     make(map[int]int32),
+		entry:       -1,
+		levelFactor: 1 / math.Log(hnswM),
+		rng:         rand.New(rand.NewSource(1)),
+	}
+	for quote, vector := range embeddings.Vectors {
+		if vector == nil {
+			continue
+		}
+		if len(vector) != index.dimensions {
+			return nil, fmt.Errorf("embedding for quote %d has %d dimensions, want %d", quote, len(vector), index.dimensions)
+		}
+		index.insert(quote, vector)
+	}
+	return index, nil
+}
+
+func (index *HNSWIndex) Len() int {
+	return len(index.nodes)
+}
+
+func (index *HNSWIndex) Dimensions() int {
+	return index.dimensions
+}
+
+// normalise returns the vector scaled to unit length, or false for the zero
+// vector.
+func normalise(vector []float32) ([]float32, bool) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 || math.IsNaN(norm) || math.IsInf(norm, 0) {
+		return nil, false
+	}
+	norm = math.Sqrt(norm)
+	normalised := make([]float32, len(vector))
+	for i, v := range vector {
+		normalised[i] = float32(float64(v) / norm)
+	}
+	return normalised, true
+}
+
+func (index *HNSWIndex) vector(node int32) []float32 {
+	start := int(node) * index.dimensions
+	return index.vectors[start : start+index.dimensions]
+}
+
+func (index *HNSWIndex) distance(query []float32, node int32) float32 {
+	var dot float32
+	for i, v := range index.vector(node) {
+		dot += query[i] * v
+	}
+	return 1 - dot
+}
+
+func (index *HNSWIndex) randomLevel() int {
+	return int(-math.Log(1-index.rng.Float64()) * index.levelFactor)
+}
+
+func (index *HNSWIndex) insert(quote int, vector []float32) {
+	vector, ok := normalise(vector)
+	if !ok {
+		return
+	}
+	node := int32(len(index.nodes))
+	level := index.randomLevel()
+	index.vectors = append(index.vectors, vector...)
+	index.nodes = append(index.nodes, hnswNode{quote: quote, friends: make([][]int32, level+1)})
+	index.byQuote[quote] = node
+
+	if index.entry < 0 {
+		index.entry = node
+		index.maxLevel = level
+		return
+	}
+
+	entry := []hnswCandidate{{index.entry, index.distance(vector, index.entry)}}
+	for l := index.maxLevel; l > level; l-- {
+		entry = index.searchLayer(vector, entry, 1, l)[:1]
+	}
+	for l := min(level, index.maxLevel); l >= 0; l-- {
+		candidates := index.searchLayer(vector, entry, hnswEfConstruction, l)
+		index.nodes[node].friends[l] = index.selectNeighbours(candidates, hnswM)
+		for _, friend := range index.nodes[node].friends[l] {
+			index.connect(friend, node, l)
+		}
+		entry = candidates
+	}
+	if level > index.maxLevel {
+		index.entry = node
+		index.maxLevel = level
+	}
+}
+
+// connect adds node to the neighbours of friend, pruning them when there
+// are too many.
+func (index *HNSWIndex) connect(friend, node int32, level int) {
+	maxFriends := hnswM
+	if level == 0 {
+		maxFriends = 2 * hnswM
+	}
+	friends := append(index.nodes[friend].friends[level], node)
+	if len(friends) > maxFriends {
+		vector := index.vector(friend)
+		candidates := make([]hnswCandidate, len(friends))
+		for i, f := range friends {
+			candidates[i] = hnswCandidate{f, index.distance(vector, f)}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+		friends = index.selectNeighbours(candidates, maxFriends)
+	}
+	index.nodes[friend].friends[level] = friends
+}
+
+// selectNeighbours picks up to m of the candidates, closest first, skipping
+// those closer to an already picked neighbour than to the node so the
+// neighbours point in different directions. Skipped candidates fill up the
+// remaining places. The candidates must be sorted by distance.
+func (index *HNSWIndex) selectNeighbours(candidates []hnswCandidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, candidate := range candidates {
+		if len(selected) == m {
+			break
+		}
+		vector := index.vector(candidate.node)
+		diverse := true
+		for _, s := range selected {
+			if index.distance(vector, s) < candidate.distance {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, candidate.node)
+		} else {
+			skipped = append(skipped, candidate.node)
+		}
+	}
+	for _, node := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, node)
+	}
+	return selected
+}
+
+// searchLayer returns the ef nodes of a level closest to the query found
+// from the entry points, closest first.
+func (index *HNSWIndex) searchLayer(query []float32, entry []hnswCandidate, ef int, level int) []hnswCandidate {
+	visited := make(map[int32]struct{}, ef*4)
+	candidates := &hnswHeap{}
+	results := &hnswHeap{farthest: true}
+	for _, e := range entry {
+		visited[e.node] = struct{}{}
+		heap.Push(candidates, e)
+		heap.Push(results, e)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		closest := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && closest.distance > results.items[0].distance {
+			break
+		}
+		for _, friend := range index.nodes[closest.node].friends[level] {
+			if _, ok := visited[friend]; ok {
+				continue
+			}
+			visited[friend] = struct{}{}
+			distance := index.distance(query, friend)
+			if results.Len() < ef || distance < results.items[0].distance {
+				heap.Push(candidates, hnswCandidate{friend, distance})
+				heap.Push(results, hnswCandidate{friend, distance})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	found := make([]hnswCandidate, results.Len())
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return found
+}
+
+// Search returns the quotes with the k embeddings closest to the vector,
+// closest first.
+func (index *HNSWIndex) Search(vector []float32, k int) []int {
+	if index.entry < 0 || len(vector) != index.dimensions {
+		return []int{}
+	}
+	query, ok := normalise(vector)
+	if !ok {
+		return []int{}
+	}
+
+	entry := []hnswCandidate{{index.entry, index.distance(query, index.entry)}}
+	for l := index.maxLevel; l > 0; l-- {
+		entry = index.searchLayer(query, entry, 1, l)[:1]
+	}
+	found := index.searchLayer(query, entry, max(hnswEfSearch, k), 0)
+
+	quotes := make([]int, 0, min(k, len(found)))
+	for _, candidate := range found[:min(k, len(found))] {
+		quotes = append(quotes, index.nodes[candidate.node].quote)
+	}
+	return quotes
+}
+
+// Nearest returns the k quotes with embeddings closest to that of the
+// quote, or false when the quote has no embedding.
+func (index *HNSWIndex) Nearest(quote, k int) ([]int, bool) {
+	node, ok := index.byQuote[quote]
+	if !ok {
+		return nil, false
+	}
+	quotes := make([]int, 0, k)
+	for _, id := range index.Search(index.vector(node), k+1) {
+		if id != quote && len(quotes) < k {
+			quotes = append(quotes, id)
+		}
+	}
+	return quotes, true
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestHNSWRecall(t *testing.T) {
+	const (
+		count      = 1000
+		dimensions = 16
+		k          = 10
+	)
+	rng := rand.New(rand.NewSource(7))
+	embeddings := &Embeddings{Dimensions: dimensions, Vectors: make([][]float32, count)}
+	for i := range embeddings.Vectors {
+		vector := make([]float32, dimensions)
+		for j := range vector {
+			vector[j] = float32(rng.NormFloat64())
+		}
+		embeddings.Vectors[i] = vector
+	}
+	index, err := BuildHNSWIndex(embeddings, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := 0
+	for quote := 0; quote < 100; quote++ {
+		query, _ := normalise(embeddings.Vectors[quote])
+		exact := make([]int, 0, count-1)
+		for id := range embeddings.Vectors {
+			if id != quote {
+				exact = append(exact, id)
+			}
+		}
+		sort.Slice(exact, func(i, j int) bool {
+			return index.distance(query, index.byQuote[exact[i]]) < index.distance(query, index.byQuote[exact[j]])
+		})
+		want := make(map[int]bool, k)
+		for _, id := range exact[:k] {
+			want[id] = true
+		}
+
+		got, ok := index.Nearest(quote, k)
+		if !ok || len(got) != k {
+			t.Fatalf("Nearest(%d) = %v, %v", quote, got, ok)
+		}
+		for _, id := range got {
+			if want[id] {
+				found++
+			}
+		}
+	}
+	if recall := float64(found) / (100 * k); recall < 0.95 {
+		t.Errorf("recall = %.3f, want at least 0.95", recall)
+	}
+}
+
+func TestHNSWBuildErrors(t *testing.T) {
+	embeddings := &Embeddings{Dimensions: 2, Vectors: [][]float32{{1, 0}, nil, {0, 0}}}
+	index, err := BuildHNSWIndex(embeddings, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Len() != 1 {
+		t.Errorf("indexed %d embeddings, want 1 without the missing and zero vectors", index.Len())
+	}
+	if _, err := BuildHNSWIndex(embeddings, 2); err == nil {
+		t.Error("expected an error for an embedding of a quote that does not exist")
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"embed"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Offline language detection. Latin script languages are told apart by their
+// character trigrams, with profiles built from the sample texts embedded from
+// languages/. Other scripts map to a language by their Unicode range.
+
+//go:embed languages/*.txt
+var languageSamples embed.FS
+
+const (
+	// languageProfileSize is the number of most frequent trigrams kept per
+	// language.
+	languageProfileSize = 1000
+	// minDetectLetters is the number of letters below which a text is too
+	// short to guess its language.
+	minDetectLetters = 8
+)
+
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+type trigram [3]rune
+
+// languageDetector holds the log probability of every profiled trigram per
+// language, so scoring a trigram is a single map lookup.
+type languageDetector struct {
+	codes    []string
+	logProbs map[trigram][]float64
+	unseen   []float64
+}
+
+var defaultLanguageDetector = sync.OnceValue(func() *languageDetector {
+	entries, err := languageSamples.ReadDir("languages")
+	if err != nil {
+		panic(err)
+	}
+	samples := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := languageSamples.ReadFile("languages/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		samples[strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))] = string(data)
+	}
+	return newLanguageDetector(samples)
+})
+
+// forEachTrigram calls fn for the trigrams of every word in text, words are
+// lower cased and padded with a space on both sides.
+func forEachTrigram(text string, fn func(trigram)) {
+	word := []rune{' '}
+	flush := func() {
+		if len(word) > 1 {
+			word = append(word, ' ')
+			for i := 0; i+3 <= len(word); i++ {
+				fn(trigram{word[i], word[i+1], word[i+2]})
+			}
+		}
+		word = word[:1]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			word = append(word, unicode.ToLower(r))
+		} else {
+			flush()
+		}
+	}
+	flush()
+}
+
+func newLanguageDetector(samples map[string]string) *languageDetector {
+	d := &languageDetector{logProbs: make(map[trigram][]float64)}
+	for code := range samples {
+		d.codes = append(d.codes, code)
+	}
+	sort.Strings(d.codes)
+	d.unseen = make([]float64, len(d.codes))
+
+	for i, code := range d.codes {
+		counts := make(map[trigram]int)
+		forEachTrigram(samples[code], func(t trigram) { counts[t]++ })
+
+		profile := make([]trigram, 0, len(counts))
+		for t := range counts {
+			profile = append(profile, t)
+		}
+		sort.Slice(profile, func(a, b int) bool {
+			if counts[profile[a]] != counts[profile[b]] {
+				return counts[profile[a]] > counts[profile[b]]
+			}
+			return string(profile[a][:]) < string(profile[b][:])
+		})
+		profile = profile[:min(len(profile), languageProfileSize)]
+
+		total := 0
+		for _, t := range profile {
+			total += counts[t]
+		}
+		// Add-one smoothing, so trigrams missing from a profile only count
+		// against a language instead of ruling it out.
+		denominator := float64(total + len(profile))
+		d.unseen[i] = math.Log(1 / denominator)
+		for _, t := range profile {
+			if d.logProbs[t] == nil {
+				d.logProbs[t] = make([]float64, len(d.codes))
+			}
+			d.logProbs[t][i] = math.Log(float64(counts[t]+1) / denominator)
+		}
+	}
+	for _, logProbs := range d.logProbs {
+		for i := range logProbs {
+			if logProbs[i] == 0 {
+				logProbs[i] = d.unseen[i]
+			}
+		}
+	}
+	return d
+}
+
+// Detect returns the language code of the text, or an empty string when the
+// text is too short to tell.
+func (d *languageDetector) Detect(text string) string {
+	latin := 0
+	scripts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				scripts[script.code]++
+				break
+			}
+		}
+	}
+
+	// Kana next to Han characters means Japanese rather than Chinese.
+	if scripts["ja"] > 0 {
+		scripts["ja"] += scripts["zh"]
+		delete(scripts, "zh")
+	}
+	best, bestCount := "", latin
+	for _, script := range scriptLanguages {
+		if count := scripts[script.code]; count > bestCount {
+			best, bestCount = script.code, count
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if latin < minDetectLetters || len(d.codes) == 0 {
+		return ""
+	}
+
+	scores := make([]float64, len(d.codes))
+	forEachTrigram(text, func(t trigram) {
+		logProbs, ok := d.logProbs[t]
+		if !ok {
+			logProbs = d.unseen
+		}
+		for i := range scores {
+			scores[i] += logProbs[i]
+		}
+	})
+	bestIndex := 0
+	for i := range scores {
+		if scores[i] > scores[bestIndex] {
+			bestIndex = i
+		}
+	}
+	return d.codes[bestIndex]
+}
+
+// DetectLanguages sets the language of the quotes that have none and returns
+// how many were detected.
+func DetectLanguages(quotes Quotes) int {
+	detector := defaultLanguageDetector()
+	detected := 0
+	for i := range quotes {
+		if quotes[i].Language != "" {
+			continue
+		}
+		if quotes[i].Language = detector.Detect(quotes[i].Text); quotes[i].Language != "" {
+			detected++
+		}
+	}
+	return detected
+}
+
+func BuildLanguageIndex(quotes Quotes) IndexStructure {
+	index := NewIndexStructure()
+	for i, quote := range quotes {
+		// Quotes whose language was not detected are left out.
+		if lang := normalizeLanguage(quote.Language); lang != "" {
+			index.Add(lang, i)
+		}
+	}
+	return index
+}
+
+// normalizeLanguage reduces a language tag such as "es-MX" to its lower case
+// primary subtag.
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// acceptedLanguages returns the languages of an Accept-Language header in
+// order of preference, languages with q=0 are left out.
+func acceptedLanguages(header string) []string {
+	type accepted struct {
+		tag     string
+		quality float64
+	}
+	var languages []accepted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = v
+			}
+		}
+		if tag = strings.TrimSpace(tag); tag != "" && quality > 0 {
+			languages = append(languages, accepted{tag, quality})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	tags := make([]string, len(languages))
+	for i, language := range languages {
+		tags[i] = language.tag
+	}
+	return tags
+}
+
+// requestLanguage returns the language of the lang parameter to limit a
+// request to. An empty language means no limit, as does lang=all.
+// Accept-Language does not limit lists, quotes whose language was not
+// detected would silently go missing.
+func requestLanguage(r *http.Request) string {
+	lang := normalizeLanguage(r.URL.Query().Get("lang"))
+	if lang == "all" || lang == "*" {
+		return ""
+	}
+	return lang
+}
+
+// preferredLanguage returns the first language of the Accept-Language header
+// that has quotes, or "" when none has. It only picks random quotes, a list
+// would lose the quotes whose language was not detected.
+func (api *API) preferredLanguage(r *http.Request) string {
+	for _, tag := range acceptedLanguages(r.Header.Get("Accept-Language")) {
+		if tag == "*" {
+			break
+		}
+		if lang := normalizeLanguage(tag); len(api.Languages.NameToQuotes[lang]) > 0 {
+			return lang
+		}
+	}
+	return ""
+}
+
+// randomQuoteLanguage returns the language to pick a random quote in, the
+// lang parameter or else the preferred language of the client.
+func (api *API) randomQuoteLanguage(w http.ResponseWriter, r *http.Request) string {
+	if r.URL.Query().Has("lang") {
+		return requestLanguage(r)
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	return api.preferredLanguage(r)
+}
+
+// setLanguageHeaders names the language a response is limited to.
+func setLanguageHeaders(w http.ResponseWriter, lang string) {
+	if lang != "" {
+		w.Header().Set("Content-Language", lang)
+	}
+}
+
+// languageQuotesHandler lists the quotes in a language, for /quotes with a
+// lang parameter.
+func (api *API) languageQuotesHandler(w http.ResponseWriter, r *http.Request, lang string) {
+	lookup := startSpan(r.Context(), "index lookup")
+	quoteIDs := api.Languages.NameToQuotes[lang]
+	lookup.SetAttr("go_quote.index", "languages")
+	lookup.SetAttr("go_quote.results", len(quoteIDs))
+	lookup.End()
+	if len(quoteIDs) == 0 {
+		returnError(w, getOutputFormat(r), http.StatusNotFound, "Language not found", "There are no quotes in the given language")
+		return
+	}
+	setLanguageHeaders(w, lang)
+	api.serveQuoteList(w, r, quoteIDs, "Quotes in "+lang, "All quotes in language "+lang, nil)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Imagination is more important than knowledge, for knowledge is limited.", "en"},
+		{"The greatest glory in living lies not in never falling, but in rising every time we fall.", "en"},
+		{"El que no arriesga no gana, y el que no lucha por sus sueños nunca los verá cumplidos.", "es"},
+		{"Lo importante no es lo que nos hace el destino, sino lo que nosotros hacemos con él.", "es"},
+		{"Il n'y a pas de chemin vers le bonheur, le bonheur est le chemin.", "fr"},
+		{"Wer nichts weiß, muss alles glauben, und wer alles glaubt, weiß nichts.", "de"},
+		{"Non è mai troppo tardi per diventare quello che avresti potuto essere.", "it"},
+		{"Não existe um caminho para a felicidade, a felicidade é o caminho.", "pt"},
+		{"Wie goed doet, goed ontmoet, en wie zoekt die vindt.", "nl"},
+		{"Всё течёт, всё меняется.", "ru"},
+		{"千里之行，始于足下。", "zh"},
+		{"七転び八起き、それが人生だ。", "ja"},
+		{"Carpe", ""},
+	}
+
+	detector := defaultLanguageDetector()
+	for _, tt := range tests {
+		if got := detector.Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	got := acceptedLanguages("fr-CH, fr;q=0.9, en;q=0.8, de;q=0, *;q=0.5")
+	want := []string{"fr-CH", "fr", "en", "*"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("acceptedLanguages = %v, want %v", got, want)
+	}
+}
+
+func TestLanguageFilter(t *testing.T) {
+	quotes := append(Quotes{}, testQuotes...)
+	quotes = append(quotes,
+		Quote{Text: "Más vale tarde que nunca, dice el refrán.", Author: "Carla Ejemplo", Tags: []string{"life"}},
+		Quote{Text: "La vida es sueño y los sueños, sueños son.", Author: "Carla Ejemplo", Tags: []string{"life"}},
+	)
+	quotes[0].Language = "en-GB"
+	quotes[1].Language = "nl"
+	quotes[2].Language = "nl"
+	DetectLanguages(quotes)
+	api := newTestAPI(quotes)
+
+	serve := func(url, acceptLanguage string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		api.SetupRoutes(mux)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		acceptLanguage string
+		status         int
+		contains       []string
+		notContain     []string
+		language       string
+	}{
+		{"lang parameter", "/quotes?lang=es", "", http.StatusOK, []string{"Más vale", "La vida"}, []string{"Fish"}, "es"},
+		{"region subtag", "/quotes?lang=es-MX&format=text", "", http.StatusOK, []string{"Más vale"}, []string{"Fish"}, "es"},
+		{"accept language does not filter", "/quotes", "es-ES,es;q=0.9", http.StatusOK, []string{"Fish", "Más vale"}, nil, ""},
+		{"accept language random", "/random-quote?format=text", "sv, es;q=0.8, en;q=0.5", http.StatusOK, nil, []string{"Fish", "Second", "Third"}, "es"},
+		{"accept language without quotes", "/random-quote?format=text", "sv", http.StatusOK, nil, nil, ""},
+		{"lang parameter over accept language", "/random-quote?lang=en&format=text", "es", http.StatusOK, []string{"Fish"}, nil, "en"},
+		{"all languages", "/quotes?lang=all", "es", http.StatusOK, []string{"Fish", "Más vale"}, nil, ""},
+		{"unknown lang", "/quotes?lang=sv", "", http.StatusNotFound, []string{"Language not found"}, nil, ""},
+		{"random", "/random-quote?lang=en&format=text", "", http.StatusOK, []string{"Fish"}, nil, "en"},
+		{"random unknown lang", "/random-quote?lang=sv", "", http.StatusNotFound, []string{"Language not found"}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.url, tt.acceptLanguage)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.status, rec.Body)
+			}
+			body := rec.Body.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("Response does not contain %q\n%s", want, body)
+				}
+			}
+			for _, unwanted := range tt.notContain {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("Response contains %q\n%s", unwanted, body)
+				}
+			}
+			if got := rec.Header().Get("Content-Language"); got != tt.language {
+				t.Errorf("Content-Language = %q, want %q", got, tt.language)
+			}
+		})
+	}
+
+	if vary := serve("/random-quote", "es").Header().Get("Vary"); vary != "Accept-Language" {
+		t.Errorf("Vary = %q, want Accept-Language", vary)
+	}
+	if vary := serve("/random-quote?lang=es", "es").Header().Get("Vary"); vary != "" {
+		t.Errorf("Vary with a lang parameter = %q, want none", vary)
+	}
+}
+
+func TestBuildLanguageIndexSkipsUnknown(t *testing.T) {
+	quotes := Quotes{{Text: "a", Language: "en-GB"}, {Text: "b"}, {Text: "c", Language: "EN"}}
+	index := BuildLanguageIndex(quotes)
+	if index.Len() != 1 || !reflect.DeepEqual(index.NameToQuotes["en"], []int{0, 2}) {
+		t.Errorf("index = %v, want only en with quotes 0 and 2", index.NameToQuotes)
+	}
+}
@@ -1,28 +1,62 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/Attumm/settingo/settingo"
+	"google.golang.org/grpc"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 	"time"
 )
 
 type Config struct {
-	Filename        string `settingo:"Path of the filename"`
-	Storage         string `settingo:"Storage type"`
-	Convert         bool   `settingo:"Convert mode, will convert data into Convert Storage type"`
-	ConvertStorage  string `settingo:"Storage type to convert to"`
-	OutputDir       string `settingo:"Directory to store converted files"`
-	Port            string `settingo:"Port for the API server"`
-	Host            string `settingo:"Host for the API server"`
-	DefaultPageSize int    `settingo:"Page size to use for the API server"`
-	MaxPageSize     int    `settingo:"Maximum quotes for the API server"`
-	MemoryDebugLog  bool   `settingo:"Enable periodic memory debug log"`
-	EnableLogging   bool   `settingo:"Enable logging of requests"`
-	PermissiveCORS  bool   `settingo:"Enable Permissive CORS"`
-	Swagger         bool   `settingo:"Enable swagger documentation"`
+	Filename          string   `settingo:"Path of the filename"`
+	Embeddings        string   `settingo:"Path of the quote embeddings, empty looks next to the filename"`
+	Storage           string   `settingo:"Storage type"`
+	Convert           bool     `settingo:"Convert mode, will convert data into Convert Storage type"`
+	ConvertStorage    string   `settingo:"Storage type to convert to"`
+	OutputDir         string   `settingo:"Directory to store converted files"`
+	Port              string   `settingo:"Port for the API server"`
+	GRPCPort          string   `settingo:"Port for the gRPC server, empty disables it"`
+	Host              string   `settingo:"Host for the API server"`
+	ReadTimeout       int      `settingo:"Seconds to read a request including its body, 0 disables the timeout"`
+	ReadHeaderTimeout int      `settingo:"Seconds to read the request headers, 0 disables the timeout"`
+	WriteTimeout      int      `settingo:"Seconds to write a response, streams, exports and pages of more than 1000 entries are exempt, 0 disables the timeout"`
+	IdleTimeout       int      `settingo:"Seconds to keep an idle keep-alive connection open, 0 disables the timeout"`
+	MaxHeaderBytes    int      `settingo:"Maximum size of the request headers in bytes"`
+	KeepAlives        bool     `settingo:"Enable HTTP keep-alive connections"`
+	ShutdownTimeout   int      `settingo:"Seconds to let requests finish on SIGTERM before closing their connections"`
+	DefaultPageSize   int      `settingo:"Page size to use for the API server"`
+	MaxPageSize       int      `settingo:"Maximum quotes for the API server"`
+	MemoryDebugLog    bool     `settingo:"Enable periodic memory debug log"`
+	EnableLogging     bool     `settingo:"Enable logging of requests"`
+	AccessLogFile     string   `settingo:"File for the access log, rotated by size, empty logs to stdout"`
+	AccessLogSize     int      `settingo:"Size in MB at which the access log file is rotated"`
+	AccessLogFiles    int      `settingo:"Number of rotated access log files to keep"`
+	AccessLogSample   int      `settingo:"Log one in this many requests, server errors are always logged"`
+	AccessLogFields   []string `settingo:"Fields of the access log lines"`
+	PermissiveCORS    bool     `settingo:"Enable Permissive CORS"`
+	Swagger           bool     `settingo:"Enable swagger documentation"`
+	Metrics           bool     `settingo:"Enable the Prometheus /metrics endpoint"`
+	Pprof             bool     `settingo:"Enable profiling under /debug/pprof/, local clients only without a debug token"`
+	DebugToken        string   `settingo:"Bearer token required for /debug and /debug/pprof/, empty serves them to local clients only"`
+	TraceEndpoint     string   `settingo:"OTLP/HTTP endpoint to export traces to, such as http://localhost:4318/v1/traces, empty disables tracing"`
+	TraceService      string   `settingo:"Service name of the exported traces"`
+}
+
+// embeddingsFilename returns the embeddings file to load, which is optional.
+func (config *Config) embeddingsFilename() string {
+	if config.Embeddings != "" {
+		return config.Embeddings
+	}
+	return EmbeddingsFilename(config.Filename, config.Storage)
 }
 
 func logMemoryUsagePeriodically() {
@@ -38,21 +72,105 @@ func logMemoryUsagePeriodically() {
 	}
 }
 
+// loadData loads the quotes and builds the indexes. It runs while the server
+// already answers the health probes.
+func (api *API) loadData(config *Config) error {
+	runtime.GC()
+	quotes, err := LoadQuotes(config.Filename, config.Storage)
+	if err != nil {
+		return fmt.Errorf("loading quotes: %v", err)
+	}
+	fmt.Printf("Loaded: %d quotes from %s\n", len(quotes), config.Filename)
+	runtime.GC()
+
+	fmt.Printf("Total quotes processed: %d\n", len(quotes))
+
+	dataUpdated := time.Now()
+	if fi, err := os.Stat(config.Filename); err == nil {
+		dataUpdated = fi.ModTime()
+	}
+
+	detected := DetectLanguages(quotes)
+	fmt.Printf("Detected the language of %d quotes\n", detected)
+
+	authorIndex := BuildAuthorIndex(quotes)
+	tagIndex := BuildTagIndex(quotes)
+	languageIndex := BuildLanguageIndex(quotes)
+	translationIndex := BuildTranslationIndex(quotes)
+	similarIndex := BuildSimilarIndex(quotes)
+	stats := BuildStats(quotes, authorIndex, tagIndex)
+
+	fmt.Printf("Created index for Authors: %d, Tags: %d, Languages: %d and Translations: %d\n",
+		authorIndex.Len(), tagIndex.Len(), languageIndex.Len(), translationIndex.Len())
+
+	var semanticIndex *HNSWIndex
+	embeddingsFilename := config.embeddingsFilename()
+	if _, err := os.Stat(embeddingsFilename); err == nil {
+		embeddings, err := LoadEmbeddings(embeddingsFilename, EmbeddingsStorage(embeddingsFilename))
+		if err != nil {
+			return fmt.Errorf("loading embeddings: %v", err)
+		}
+		started := time.Now()
+		if semanticIndex, err = BuildHNSWIndex(embeddings, len(quotes)); err != nil {
+			return fmt.Errorf("indexing embeddings: %v", err)
+		}
+		fmt.Printf("Indexed %d embeddings of %d dimensions from %s in %v\n",
+			semanticIndex.Len(), semanticIndex.Dimensions(), embeddingsFilename, time.Since(started).Round(time.Millisecond))
+		runtime.GC()
+	}
+
+	api.Quotes = quotes
+	api.Authors = authorIndex
+	api.Tags = tagIndex
+	api.Languages = languageIndex
+	api.Translations = translationIndex
+	api.Similar = similarIndex
+	api.Semantic = semanticIndex
+	api.Stats = stats
+	api.DataUpdated = dataUpdated
+	// Hashed here so /readyz and /debug do not read the file on a request.
+	api.dataChecksum()
+	return nil
+}
+
 func main() {
 	config := &Config{
-		Filename:        "data/quotes.bytesz",
-		Storage:         "bytesz",
-		Convert:         false,
-		ConvertStorage:  "bytesz",
-		OutputDir:       "data",
-		Port:            "8000",
-		Host:            "0.0.0.0",
-		DefaultPageSize: 10,
-		MaxPageSize:     1000000,
-		MemoryDebugLog:  false,
-		EnableLogging:   true,
-		PermissiveCORS:  true,
-		Swagger:         true,
+		Filename:          "data/quotes.bytesz",
+		Embeddings:        "",
+		Storage:           "bytesz",
+		Convert:           false,
+		ConvertStorage:    "bytesz",
+		OutputDir:         "data",
+		Port:              "8000",
+		GRPCPort:          "",
+		Host:              "0.0.0.0",
+		ReadTimeout:       30,
+		ReadHeaderTimeout: 10,
+		WriteTimeout:      60,
+		IdleTimeout:       120,
+		MaxHeaderBytes:    1 << 20,
+		KeepAlives:        true,
+		ShutdownTimeout:   30,
+		DefaultPageSize:   10,
+		MaxPageSize:       1000000,
+		MemoryDebugLog:    false,
+		EnableLogging:     true,
+		AccessLogFile:     "",
+		AccessLogSize:     100,
+		AccessLogFiles:    5,
+		AccessLogSample:   1,
+		AccessLogFields:   AccessLogFields,
+		PermissiveCORS:    true,
+		Swagger:           true,
+		Metrics:           true,
+		Pprof:             false,
+		DebugToken:        "",
+		TraceEndpoint:     "",
+		TraceService:      "go-quote",
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "quote" {
+		os.Exit(runQuoteCommand(os.Args[2:], os.Stdout, os.Stderr))
 	}
 
 	settingo.ParseTo(config)
@@ -63,39 +181,117 @@ func main() {
 		go logMemoryUsagePeriodically()
 	}
 
-	runtime.GC()
-	quotes, err := LoadQuotes(config.Filename, config.Storage)
-	if err != nil {
-		log.Fatalf("Error loading quotes: %v", err)
-	}
-	fmt.Printf("Loaded: %d quotes from %s\n", len(quotes), config.Filename)
-	runtime.GC()
+	if config.Convert {
+		outputFilename := getConvertedFilename(config.Filename, config.ConvertStorage, config.OutputDir)
+		if err := ConvertQuotes(config.Filename, config.Storage, outputFilename, config.ConvertStorage); err != nil {
+			log.Fatalf("Error converting quotes: %v", err)
+		}
+		fmt.Printf("Converted quotes to %s and saved as %s\n", config.ConvertStorage, outputFilename)
 
-	fmt.Printf("Total quotes processed: %d\n", len(quotes))
-
-	authorIndex := BuildAuthorIndex(quotes)
-	tagIndex := BuildTagIndex(quotes)
-
-	fmt.Printf("Created index for Authors: %d and Tags: %d\n", authorIndex.Len(), tagIndex.Len())
+		embeddingsFilename := config.embeddingsFilename()
+		if _, err := os.Stat(embeddingsFilename); err == nil {
+			outputEmbeddings := EmbeddingsFilename(outputFilename, config.ConvertStorage)
+			if err := ConvertEmbeddings(embeddingsFilename, outputEmbeddings); err != nil {
+				log.Fatalf("Error converting embeddings: %v", err)
+			}
+			fmt.Printf("Converted embeddings and saved as %s\n", outputEmbeddings)
+		}
+		return
+	}
 
 	api := &API{
-		Quotes:          quotes,
-		Authors:         authorIndex,
-		Tags:            tagIndex,
 		DefaultPageSize: config.DefaultPageSize,
 		MaxPageSize:     config.MaxPageSize,
 		Runtime:         runtime.GOOS,
 		EnableLogging:   config.EnableLogging,
 		PermissiveCORS:  config.PermissiveCORS,
 		Swagger:         config.Swagger,
+		Config:          config,
+		Pprof:           config.Pprof,
+		DebugToken:      config.DebugToken,
+		stopping:        make(chan struct{}),
+	}
+	api.loading.Store(true)
+
+	var err error
+	if config.Metrics {
+		api.Metrics = NewMetrics()
+	}
+	if config.EnableLogging {
+		var output io.Writer = os.Stdout
+		if config.AccessLogFile != "" {
+			if output, err = OpenRotatingFile(config.AccessLogFile, int64(config.AccessLogSize)<<20, config.AccessLogFiles); err != nil {
+				log.Fatalf("Error opening access log: %v", err)
+			}
+		}
+		accessLogWriter := NewBufferedLogWriter(output, time.Second)
+		defer accessLogWriter.Close()
+		if api.AccessLog, err = NewAccessLog(accessLogWriter, AccessLogOptions{Fields: config.AccessLogFields, Sample: config.AccessLogSample}); err != nil {
+			log.Fatalf("Error creating access log: %v", err)
+		}
+	}
+
+	if config.TraceEndpoint != "" {
+		api.Tracer = NewTracer(config.TraceEndpoint, config.TraceService)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			api.Tracer.Shutdown(ctx)
+		}()
+		fmt.Printf("Exporting traces to %s\n", config.TraceEndpoint)
 	}
 
 	mux := http.NewServeMux()
 	middleware := api.SetupMiddleware()
 	api.SetupRoutes(mux)
 
+	server := newHTTPServer(config, middleware(mux))
+	server.RegisterOnShutdown(api.stopStreams)
+	listener, err := net.Listen("tcp", config.Host+":"+config.Port)
+	if err != nil {
+		log.Fatalf("Error starting server: %v", err)
+	}
 	fmt.Printf("Starting server on port %s:%s...\n", config.Host, config.Port)
-	if err := http.ListenAndServe(config.Host+":"+config.Port, middleware(mux)); err != nil {
-		log.Fatal(err)
+	go func() {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+	if config.GRPCPort != "" {
+		if grpcListener, err = net.Listen("tcp", config.Host+":"+config.GRPCPort); err != nil {
+			log.Fatalf("Error starting gRPC server: %v", err)
+		}
+		grpcServer = NewGRPCServer(api)
+	}
+
+	go func() {
+		if err := api.loadData(config); err != nil {
+			log.Fatalf("Error %v", err)
+		}
+		// Storing false publishes the data to the handlers, which only
+		// read it once they have seen loading cleared.
+		api.loading.Store(false)
+		fmt.Println("Ready to serve quotes")
+
+		if grpcServer != nil {
+			fmt.Printf("Starting gRPC server on port %s:%s...\n", config.Host, config.GRPCPort)
+			if err := grpcServer.Serve(grpcListener); err != nil && err != grpc.ErrServerStopped {
+				log.Fatal(err)
+			}
+		}
+	}()
+
+	// A second signal during the drain stops the process right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	fmt.Printf("Shutting down, waiting up to %ds for requests to finish...\n", config.ShutdownTimeout)
+	if err := shutdown(server, grpcServer, time.Duration(config.ShutdownTimeout)*time.Second); err != nil {
+		log.Printf("Error shutting down: %v", err)
 	}
+	fmt.Println("Stopped")
 }
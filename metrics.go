@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics collects request telemetry for /metrics, which writes it in the
+// Prometheus text exposition format together with runtime and dataset
+// gauges. See https://prometheus.io/docs/instrumenting/exposition_formats/.
+
+var (
+	metricsDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	metricsSizeBuckets     = []float64{100, 1000, 10000, 100000, 1e6, 1e7, 1e8}
+)
+
+type metricsRequestKey struct {
+	route  string
+	format string
+	code   int
+}
+
+type metricsHistogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newMetricsHistogram(buckets []float64) *metricsHistogram {
+	return &metricsHistogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *metricsHistogram) observe(value float64) {
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += value
+	h.count++
+}
+
+type Metrics struct {
+	started time.Time
+
+	mu        sync.Mutex
+	requests  map[metricsRequestKey]uint64
+	durations map[string]*metricsHistogram
+	sizes     map[string]*metricsHistogram
+
+	inFlight         atomic.Int64
+	gzipUncompressed atomic.Uint64
+	gzipCompressed   atomic.Uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		started:   time.Now(),
+		requests:  make(map[metricsRequestKey]uint64),
+		durations: make(map[string]*metricsHistogram),
+		sizes:     make(map[string]*metricsHistogram),
+	}
+}
+
+func (m *Metrics) observeRequest(route, format string, code int, duration time.Duration, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[metricsRequestKey{route, format, code}]++
+	if m.durations[route] == nil {
+		m.durations[route] = newMetricsHistogram(metricsDurationBuckets)
+		m.sizes[route] = newMetricsHistogram(metricsSizeBuckets)
+	}
+	m.durations[route].observe(duration.Seconds())
+	m.sizes[route].observe(float64(size))
+}
+
+func (api *API) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		api.Metrics.inFlight.Add(1)
+		defer api.Metrics.inFlight.Add(-1)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		// The mux sets the pattern of the route that served the request, it
+		// keeps the route label bounded unlike the path.
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		api.Metrics.observeRequest(route, getOutputFormat(r), recorder.Status(), time.Since(start), recorder.size)
+	})
+}
+
+type countingWriter struct {
+	w     io.Writer
+	count *atomic.Uint64
+}
+
+func (cw countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.count.Add(uint64(n))
+	return n, err
+}
+
+// gzipInput counts the bytes written to a gzip writer, and gzipOutput those
+// it writes, for the compression ratio. Both pass the writer through when
+// metrics are off.
+func (m *Metrics) gzipInput(w io.Writer) io.Writer {
+	if m == nil {
+		return w
+	}
+	return countingWriter{w, &m.gzipUncompressed}
+}
+
+func (m *Metrics) gzipOutput(w io.Writer) io.Writer {
+	if m == nil {
+		return w
+	}
+	return countingWriter{w, &m.gzipCompressed}
+}
+
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (mw metricsWriter) header(name, kind, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (mw metricsWriter) value(name, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(mw.w, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (mw metricsWriter) single(name, kind, help string, value float64) {
+	mw.header(name, kind, help)
+	mw.value(name, "", value)
+}
+
+func (mw metricsWriter) histogram(name, help string, histograms map[string]*metricsHistogram) {
+	mw.header(name, "histogram", help)
+	for _, route := range sortedKeys(histograms) {
+		h := histograms[route]
+		label := "route=" + metricsLabelValue(route)
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += h.counts[i]
+			mw.value(name+"_bucket", label+",le="+metricsLabelValue(strconv.FormatFloat(bound, 'g', -1, 64)), float64(cumulative))
+		}
+		mw.value(name+"_bucket", label+`,le="+Inf"`, float64(h.count))
+		mw.value(name+"_sum", label, h.sum)
+		mw.value(name+"_count", label, float64(h.count))
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// metricsLabelValue quotes a label value, escaping backslashes, double
+// quotes and line feeds.
+func metricsLabelValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+func (m *Metrics) writeRequests(mw metricsWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]metricsRequestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.format != b.format {
+			return a.format < b.format
+		}
+		return a.code < b.code
+	})
+	mw.header("go_quote_http_requests_total", "counter", "HTTP requests by route, output format and status code.")
+	for _, key := range keys {
+		labels := fmt.Sprintf("route=%s,format=%s,code=%s",
+			metricsLabelValue(key.route), metricsLabelValue(key.format), metricsLabelValue(strconv.Itoa(key.code)))
+		mw.value("go_quote_http_requests_total", labels, float64(m.requests[key]))
+	}
+
+	mw.histogram("go_quote_http_request_duration_seconds", "HTTP request latency by route.", m.durations)
+	mw.histogram("go_quote_http_response_size_bytes", "HTTP response body size by route, after compression.", m.sizes)
+}
+
+func (api *API) writeMetrics(w io.Writer) {
+	mw := metricsWriter{w}
+	m := api.Metrics
+
+	m.writeRequests(mw)
+	mw.single("go_quote_http_requests_in_flight", "gauge", "HTTP requests being served.", float64(m.inFlight.Load()))
+
+	uncompressed, compressed := m.gzipUncompressed.Load(), m.gzipCompressed.Load()
+	mw.single("go_quote_gzip_uncompressed_bytes_total", "counter", "Bytes written to gzip compressed responses before compression.", float64(uncompressed))
+	mw.single("go_quote_gzip_compressed_bytes_total", "counter", "Bytes written to gzip compressed responses after compression.", float64(compressed))
+	ratio := 0.0
+	if compressed > 0 {
+		ratio = float64(uncompressed) / float64(compressed)
+	}
+	mw.single("go_quote_gzip_ratio", "gauge", "Uncompressed to compressed bytes of all gzip compressed responses.", ratio)
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	mw.header("go_info", "gauge", "Information about the Go environment.")
+	mw.value("go_info", "version="+metricsLabelValue(runtime.Version()), 1)
+	mw.single("go_goroutines", "gauge", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	mw.single("go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.", float64(stats.Alloc))
+	mw.single("go_memstats_alloc_bytes_total", "counter", "Total number of bytes allocated, even if freed.", float64(stats.TotalAlloc))
+	mw.single("go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system.", float64(stats.Sys))
+	mw.single("go_memstats_heap_inuse_bytes", "gauge", "Number of heap bytes that are in use.", float64(stats.HeapInuse))
+	mw.single("go_memstats_heap_objects", "gauge", "Number of allocated objects.", float64(stats.HeapObjects))
+	mw.single("go_memstats_mallocs_total", "counter", "Total number of mallocs.", float64(stats.Mallocs))
+	mw.single("go_memstats_frees_total", "counter", "Total number of frees.", float64(stats.Frees))
+	mw.single("go_memstats_next_gc_bytes", "gauge", "Number of heap bytes when next garbage collection will take place.", float64(stats.NextGC))
+	mw.single("go_memstats_last_gc_time_seconds", "gauge", "Number of seconds since 1970 of last garbage collection.", float64(stats.LastGC)/1e9)
+	mw.single("go_memstats_gc_cpu_fraction", "gauge", "The fraction of this program's available CPU time used by the GC since the program started.", stats.GCCPUFraction)
+	mw.single("go_gc_cycles_total", "counter", "Number of completed GC cycles.", float64(stats.NumGC))
+	mw.single("go_gc_pause_seconds_total", "counter", "Total time the world was stopped for GC.", float64(stats.PauseTotalNs)/1e9)
+	mw.single("process_start_time_seconds", "gauge", "Start time of the process since unix epoch in seconds.", float64(m.started.UnixNano())/1e9)
+
+	mw.single("go_quote_quotes", "gauge", "Number of quotes loaded.", float64(len(api.Quotes)))
+	mw.single("go_quote_authors", "gauge", "Number of authors.", float64(api.Authors.Len()))
+	mw.single("go_quote_tags", "gauge", "Number of tags.", float64(api.Tags.Len()))
+	mw.single("go_quote_languages", "gauge", "Number of quote languages.", float64(api.Languages.Len()))
+	embeddings := 0
+	if api.Semantic != nil {
+		embeddings = api.Semantic.Len()
+	}
+	mw.single("go_quote_embeddings", "gauge", "Number of quote embeddings in the semantic index.", float64(embeddings))
+	mw.single("go_quote_data_updated_timestamp_seconds", "gauge", "Modification time of the data file since unix epoch in seconds.", float64(api.DataUpdated.Unix()))
+}
+
+func (api *API) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	api.writeMetrics(w)
+}
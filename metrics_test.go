@@ -0,0 +1,102 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	api.Metrics = NewMetrics()
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	handler := api.SetupMiddleware()(mux)
+
+	for _, url := range []string{"/quotes/1", "/quotes/1?format=text", "/quotes/9", "/quotes?gzip=true", "/tags/love?format=csv"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE go_quote_http_requests_total counter\n",
+		`go_quote_http_requests_total{route="/quotes/",format="json",code="200"} 1`,
+		`go_quote_http_requests_total{route="/quotes/",format="text",code="200"} 1`,
+		`go_quote_http_requests_total{route="/quotes/",format="json",code="404"} 1`,
+		`go_quote_http_requests_total{route="/tags/",format="csv",code="200"} 1`,
+		"# TYPE go_quote_http_request_duration_seconds histogram\n",
+		`go_quote_http_request_duration_seconds_bucket{route="/quotes/",le="+Inf"} 3`,
+		`go_quote_http_request_duration_seconds_count{route="/quotes/"} 3`,
+		`go_quote_http_response_size_bytes_count{route="/quotes"} 1`,
+		"go_quote_http_requests_in_flight 1\n",
+		"go_quote_quotes 3\n",
+		"go_quote_authors 2\n",
+		"go_quote_tags 3\n",
+		"go_goroutines ",
+		"go_memstats_alloc_bytes ",
+		"go_gc_cycles_total ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q\n%s", want, body)
+		}
+	}
+
+	ratio := regexp.MustCompile(`(?m)^go_quote_gzip_ratio (\S+)$`).FindStringSubmatch(body)
+	if ratio == nil {
+		t.Fatal("no go_quote_gzip_ratio")
+	}
+	if value, _ := strconv.ParseFloat(ratio[1], 64); value <= 1 {
+		t.Errorf("go_quote_gzip_ratio = %s, want more than 1", ratio[1])
+	}
+
+	// Every sample line is a metric name, optional labels and a number.
+	sample := regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{[a-z_]+="(?:[^"\\]|\\.)*"(,[a-z_]+="(?:[^"\\]|\\.)*")*\})? [-+0-9.eInf]+$`)
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if !strings.HasPrefix(line, "# ") && !sample.MatchString(line) {
+			t.Errorf("invalid sample line %q", line)
+		}
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	rec := serveTestRequest(api, "/metrics")
+	if strings.Contains(rec.Body.String(), "go_quote_http_requests_total") {
+		t.Error("/metrics is served without metrics enabled")
+	}
+}
+
+func TestGzipMetricsEveryStreamedFormat(t *testing.T) {
+	for _, format := range []string{"json", "hal", "protobuf", "msgpack", "csv", "yaml", "xml"} {
+		api := newTestAPI(testQuotes)
+		api.Metrics = NewMetrics()
+		rec := serveTestRequest(api, "/quotes?gzip=true&format="+format)
+
+		gr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		body, err := io.ReadAll(gr)
+		if err != nil || len(body) == 0 {
+			t.Errorf("%s: body %q, %v", format, body, err)
+		}
+		if uncompressed := api.Metrics.gzipUncompressed.Load(); uncompressed != uint64(len(body)) {
+			t.Errorf("%s: gzip input = %d bytes, want %d", format, uncompressed, len(body))
+		}
+		if compressed := api.Metrics.gzipCompressed.Load(); compressed == 0 {
+			t.Errorf("%s: gzip output not counted", format)
+		}
+	}
+}
@@ -1,24 +1,19 @@
 package main
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-func PrintMemUsage() {
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-	// For info on each, see: https://golang.org/pkg/runtime/#MemStats
-	fmt.Printf("Alloc = %v MiB", bToMb(m.Alloc))
-	fmt.Printf("\tTotalAlloc = %v MiB", bToMb(m.TotalAlloc))
-	fmt.Printf("\tSys = %v MiB", bToMb(m.Sys))
-	fmt.Printf("\tNumGC = %v\n", m.NumGC)
-}
-
 func bToMb(b uint64) uint64 {
 	return b / 1024 / 1024
 }
@@ -77,19 +72,18 @@ func getID(path string) (int, error) {
 }
 
 type Pagination struct {
-	Page     int    `json:"page"`
-	PageSize int    `json:"page_size"`
-	Total    int    `json:"total"`
-	Pages    int    `json:"pages"`
-	Next     string `json:"next,omitempty"`
-}
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Total      int    `json:"total"`
+	Pages      int    `json:"pages"`
+	Next       string `json:"next,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 
-func (api *API) paginate(total int, page int, pageSize int) Pagination {
-
-	if page < 1 {
-		page = 1
-	}
+	offset int
+}
 
+func (api *API) clampPageSize(pageSize int) int {
 	if pageSize < 1 {
 		pageSize = api.DefaultPageSize
 	}
@@ -97,6 +91,16 @@ func (api *API) paginate(total int, page int, pageSize int) Pagination {
 	if pageSize > api.MaxPageSize {
 		pageSize = api.MaxPageSize
 	}
+	return pageSize
+}
+
+func (api *API) paginate(total int, page int, pageSize int) Pagination {
+
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize = api.clampPageSize(pageSize)
 
 	pages := (total + pageSize - 1) / pageSize
 
@@ -105,6 +109,7 @@ func (api *API) paginate(total int, page int, pageSize int) Pagination {
 		PageSize: pageSize,
 		Total:    total,
 		Pages:    pages,
+		offset:   (page - 1) * pageSize,
 	}
 
 	if page < pages {
@@ -113,3 +118,199 @@ func (api *API) paginate(total int, page int, pageSize int) Pagination {
 
 	return pagination
 }
+
+const cursorPrefix = "after:"
+
+func encodeCursor(lastID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(lastID)))
+}
+
+// decodeCursor returns the quote ID the cursor points after, or -1 for an
+// empty cursor which starts at the beginning.
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return -1, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return -1, fmt.Errorf("invalid cursor")
+	}
+
+	lastID, err := strconv.Atoi(string(raw[len(cursorPrefix):]))
+	if err != nil || lastID < 0 {
+		return -1, fmt.Errorf("invalid cursor")
+	}
+	return lastID, nil
+}
+
+// paginateCursor paginates over quote IDs after the ID in the cursor. ids is
+// the sorted posting list being paged over, nil pages over all quotes. Since
+// the cursor holds a quote ID instead of an offset, the position is found by
+// a binary search and stays valid however deep the client pages.
+func (api *API) paginateCursor(ids []int, total int, cursor string, pageSize int) (Pagination, error) {
+	lastID, err := decodeCursor(cursor)
+	if err != nil {
+		return Pagination{}, err
+	}
+	// Quote IDs are below len(api.Quotes), a larger ID cannot come from a
+	// next cursor and would overflow the start below.
+	if lastID >= len(api.Quotes) {
+		return Pagination{}, fmt.Errorf("cursor past the end")
+	}
+
+	pageSize = api.clampPageSize(pageSize)
+
+	start := lastID + 1
+	if ids != nil {
+		start = sort.SearchInts(ids, lastID+1)
+	}
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	pagination := Pagination{
+		Page:     start/pageSize + 1,
+		PageSize: pageSize,
+		Total:    total,
+		Pages:    (total + pageSize - 1) / pageSize,
+		Cursor:   cursor,
+		offset:   start,
+	}
+
+	if end < total {
+		lastOnPage := end - 1
+		if ids != nil {
+			lastOnPage = ids[end-1]
+		}
+		pagination.NextCursor = encodeCursor(lastOnPage)
+		pagination.Next = fmt.Sprintf("?cursor=%s&%s=%d", pagination.NextCursor, PAGESIZE, pageSize)
+	}
+
+	return pagination, nil
+}
+
+// paginateRequest uses cursor pagination when the request has a cursor
+// parameter, an empty one starts at the beginning, and page offsets otherwise.
+func (api *API) paginateRequest(r *http.Request, ids []int, total int) (Pagination, error) {
+	query := r.URL.Query()
+	pageSize, _ := strconv.Atoi(query.Get(PAGESIZE))
+	if query.Has("cursor") {
+		return api.paginateCursor(ids, total, query.Get("cursor"), pageSize)
+	}
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	return api.paginate(total, page, pageSize), nil
+}
+
+type PageLinks struct {
+	Self  string
+	First string
+	Last  string
+	Prev  string
+	Next  string
+}
+
+// buildPageLinks creates absolute navigation links for a paginated request.
+// All query parameters other than the position are kept, so the links stay
+// in the same representation as the request.
+func buildPageLinks(baseURL string, u *url.URL, pagination Pagination) PageLinks {
+	link := func(key, value string) string {
+		query := u.Query()
+		query.Del("page")
+		query.Del("cursor")
+		query.Set(key, value)
+		query.Set(PAGESIZE, strconv.Itoa(pagination.PageSize))
+		return baseURL + u.Path + "?" + query.Encode()
+	}
+
+	lastPage := pagination.Pages
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	links := PageLinks{
+		First: link("page", "1"),
+		Last:  link("page", strconv.Itoa(lastPage)),
+	}
+
+	if u.Query().Has("cursor") {
+		links.Self = link("cursor", pagination.Cursor)
+		if pagination.NextCursor != "" {
+			links.Next = link("cursor", pagination.NextCursor)
+		}
+		return links
+	}
+
+	links.Self = link("page", strconv.Itoa(pagination.Page))
+	if pagination.Page > 1 {
+		links.Prev = link("page", strconv.Itoa(min(pagination.Page-1, lastPage)))
+	}
+	if pagination.Page < pagination.Pages {
+		links.Next = link("page", strconv.Itoa(pagination.Page+1))
+	}
+	return links
+}
+
+// setLinkHeader sets the RFC 5988 Link header next to the custom pagination
+// headers.
+func setLinkHeader(w http.ResponseWriter, links PageLinks) {
+	relations := []struct {
+		rel  string
+		href string
+	}{
+		{"self", links.Self},
+		{"first", links.First},
+		{"prev", links.Prev},
+		{"next", links.Next},
+		{"last", links.Last},
+	}
+
+	values := make([]string, 0, len(relations))
+	for _, relation := range relations {
+		if relation.href != "" {
+			values = append(values, fmt.Sprintf("<%s>; rel=\"%s\"", relation.href, relation.rel))
+		}
+	}
+	w.Header().Set("Link", strings.Join(values, ", "))
+}
+
+// randomQuoteID picks a random quote, optionally limited to a tag or an
+// author ID.
+func (api *API) randomQuoteID(tag, author string) (int, bool) {
+	var quoteIDs []int
+	switch {
+	case tag != "" && author != "":
+		return 0, false
+	case tag != "":
+		quoteIDs = api.Tags.NameToQuotes[tag]
+	case author != "":
+		quoteIDs = api.Authors.NameToQuotes[author]
+	default:
+		if len(api.Quotes) == 0 {
+			return 0, false
+		}
+		return rand.Intn(len(api.Quotes)), true
+	}
+	if len(quoteIDs) == 0 {
+		return 0, false
+	}
+	return quoteIDs[rand.Intn(len(quoteIDs))], true
+}
+
+// searchQuoteIDs returns the quotes whose text or author contains the query,
+// ignoring case.
+func (api *API) searchQuoteIDs(query string) []int {
+	query = strings.ToLower(query)
+	var ids []int
+	for i, quote := range api.Quotes {
+		if strings.Contains(strings.ToLower(quote.Text), query) || strings.Contains(strings.ToLower(quote.Author), query) {
+			ids = append(ids, i)
+		}
+	}
+	return ids
+}
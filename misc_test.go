@@ -1,6 +1,10 @@
 package main
 
 import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -117,3 +121,137 @@ func TestGetID(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name        string
+		cursor      string
+		expectedID  int
+		expectError bool
+	}{
+		{name: "Empty cursor starts at the beginning", cursor: "", expectedID: -1},
+		{name: "Round trip", cursor: encodeCursor(48999), expectedID: 48999},
+		{name: "Not base64", cursor: "!!!", expectedID: -1, expectError: true},
+		{name: "Missing prefix", cursor: "MTIz", expectedID: -1, expectError: true},
+		{name: "Negative ID", cursor: "YWZ0ZXI6LTU", expectedID: -1, expectError: true},
+		{name: "Largest ID", cursor: "YWZ0ZXI6OTIyMzM3MjAzNjg1NDc3NTgwNw", expectedID: math.MaxInt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := decodeCursor(tt.cursor)
+
+			if tt.expectError && err == nil {
+				t.Errorf("%s: Expected an error, but got none", tt.name)
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("%s: Unexpected error: %v", tt.name, err)
+			}
+			if id != tt.expectedID {
+				t.Errorf("%s: Expected ID %d, but got %d", tt.name, tt.expectedID, id)
+			}
+		})
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	quotes := make(Quotes, 0, 25)
+	all := make([]int, 0, 25)
+	thirds := make([]int, 0, 9)
+	for i := 0; i < 25; i++ {
+		tags := []string{"all"}
+		if i%3 == 0 {
+			tags = append(tags, "third")
+			thirds = append(thirds, i)
+		}
+		quotes = append(quotes, Quote{Text: "quote", Author: "Author", Tags: tags})
+		all = append(all, i)
+	}
+	api := newTestAPI(quotes)
+
+	tests := []struct {
+		name        string
+		url         string
+		expectedIDs []int
+	}{
+		{name: "All quotes", url: "http://go-quote.com/quotes?cursor=&page_size=10", expectedIDs: all},
+		{name: "Tag posting list", url: "http://go-quote.com/tags/third?cursor=&page_size=4", expectedIDs: thirds},
+		{name: "Author posting list", url: "http://go-quote.com/authors/Author?cursor=&page_size=7", expectedIDs: all},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []int
+			next := tt.url
+			for requests := 0; next != ""; requests++ {
+				if requests > len(tt.expectedIDs) {
+					t.Fatalf("Cursor pagination did not terminate")
+				}
+				rec := serveTestRequest(api, next)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("Unexpected status %d for %s", rec.Code, next)
+				}
+
+				var response struct {
+					Quotes     []ResponseQuote `json:"quotes"`
+					Pagination Pagination      `json:"pagination"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to parse JSON: %v\n%s", err, rec.Body.String())
+				}
+				for _, quote := range response.Quotes {
+					seen = append(seen, quote.ID)
+				}
+
+				next = ""
+				for _, part := range strings.Split(rec.Header().Get("Link"), ", ") {
+					if strings.HasSuffix(part, `rel="next"`) {
+						next = part[1:strings.Index(part, ">")]
+					}
+				}
+				if (next == "") != (response.Pagination.NextCursor == "") {
+					t.Fatalf("Link header next %q does not match next_cursor %q", next, response.Pagination.NextCursor)
+				}
+			}
+
+			if !jsonEqual(seen, tt.expectedIDs) {
+				t.Errorf("Expected IDs %v, got %v", tt.expectedIDs, seen)
+			}
+		})
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	rec := serveTestRequest(api, "http://go-quote.com/tags/love?cursor=bogus")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid cursor, got %d", rec.Code)
+	}
+
+	// after:9223372036854775807 overflowed the start of the page.
+	for _, path := range []string{"/quotes", "/tags/love", "/authors/Ann+Example"} {
+		rec := serveTestRequest(api, "http://go-quote.com"+path+"?cursor=YWZ0ZXI6OTIyMzM3MjAzNjg1NDc3NTgwNw")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status 400 for a cursor past the end, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestLinkHeaderPageMode(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	rec := serveTestRequest(api, "http://go-quote.com/tags/love?page=2&page_size=1")
+
+	link := rec.Header().Get("Link")
+	expected := []string{
+		`<http://go-quote.com/tags/love?page=1&page_size=1>; rel="first"`,
+		`<http://go-quote.com/tags/love?page=1&page_size=1>; rel="prev"`,
+		`<http://go-quote.com/tags/love?page=2&page_size=1>; rel="last"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link header %q does not contain %q", link, want)
+		}
+	}
+	if strings.Contains(link, `rel="next"`) {
+		t.Errorf("Link header %q should not contain next on the last page", link)
+	}
+}
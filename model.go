@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -13,10 +15,37 @@ const (
 	TagsTypeRequest
 )
 
+// Quote is stored with gob, which matches fields by name. Fields can be
+// added without breaking existing data files, the new fields of quotes in an
+// older file are left zero.
 type Quote struct {
 	Text   string   `json:"text"`
 	Author string   `json:"author"`
 	Tags   []string `json:"tags"`
+
+	// Optional metadata, zero values mean unknown.
+	Source   string `json:"source,omitempty"`
+	Year     int    `json:"year,omitempty"`
+	Language string `json:"language,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Verified bool   `json:"verified,omitempty"`
+
+	// TranslationGroup links translations of the same quote, quotes with the
+	// same group are served by /quotes/{id}/translations.
+	TranslationGroup string `json:"-"`
+}
+
+// Citation describes where the quote comes from, such as "Hamlet, 1603".
+func (q Quote) Citation() string {
+	switch {
+	case q.Source != "" && q.Year != 0:
+		return fmt.Sprintf("%s, %d", q.Source, q.Year)
+	case q.Source != "":
+		return q.Source
+	case q.Year != 0:
+		return strconv.Itoa(q.Year)
+	}
+	return ""
 }
 
 type ResponseQuote struct {
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// queryPlan evaluates a query over the in-memory indexes. Plans backed by a
+// posting list are indexed and cheap to intersect, the others have to look
+// at every candidate quote.
+type queryPlan interface {
+	// cost estimates the number of quotes the plan yields or has to scan.
+	cost() int
+	indexed() bool
+	// ids returns the matching quote IDs in ascending order.
+	ids() []int
+	match(id int) bool
+	String() string
+}
+
+type postingPlan struct {
+	desc string
+	list []int
+}
+
+func (p *postingPlan) cost() int     { return len(p.list) }
+func (p *postingPlan) indexed() bool { return true }
+func (p *postingPlan) ids() []int    { return p.list }
+func (p *postingPlan) String() string {
+	return fmt.Sprintf("%s(%d)", p.desc, len(p.list))
+}
+
+func (p *postingPlan) match(id int) bool {
+	i := sort.SearchInts(p.list, id)
+	return i < len(p.list) && p.list[i] == id
+}
+
+type rangePlan struct {
+	desc     string
+	from, to int
+}
+
+func (p *rangePlan) cost() int         { return p.to - p.from }
+func (p *rangePlan) indexed() bool     { return true }
+func (p *rangePlan) match(id int) bool { return id >= p.from && id < p.to }
+func (p *rangePlan) String() string    { return fmt.Sprintf("%s(%d)", p.desc, p.cost()) }
+
+func (p *rangePlan) ids() []int {
+	ids := make([]int, 0, p.cost())
+	for id := p.from; id < p.to; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+type filterPlan struct {
+	desc  string
+	total int
+	test  func(id int) bool
+}
+
+func (p *filterPlan) cost() int         { return p.total }
+func (p *filterPlan) indexed() bool     { return false }
+func (p *filterPlan) match(id int) bool { return p.test(id) }
+func (p *filterPlan) String() string    { return "scan(" + p.desc + ")" }
+
+func (p *filterPlan) ids() []int {
+	var ids []int
+	for id := 0; id < p.total; id++ {
+		if p.test(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// andPlan drives the evaluation with its cheapest child, intersects the
+// posting lists of the other indexed children and filters the remaining
+// candidates with the rest.
+type andPlan struct {
+	children []queryPlan
+	total    int
+}
+
+func (p *andPlan) cost() int     { return p.children[0].cost() }
+func (p *andPlan) indexed() bool { return p.children[0].indexed() }
+
+func (p *andPlan) match(id int) bool {
+	for _, child := range p.children {
+		if !child.match(id) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *andPlan) ids() []int {
+	if !p.children[0].indexed() {
+		return (&filterPlan{total: p.total, test: p.match}).ids()
+	}
+
+	ids := p.children[0].ids()
+	var filters []queryPlan
+	for _, child := range p.children[1:] {
+		if child.indexed() {
+			ids = intersectSorted(ids, child.ids())
+		} else {
+			filters = append(filters, child)
+		}
+	}
+	if len(filters) == 0 {
+		return ids
+	}
+
+	matched := make([]int, 0, len(ids))
+	for _, id := range ids {
+		ok := true
+		for _, filter := range filters {
+			if !filter.match(id) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, id)
+		}
+	}
+	return matched
+}
+
+func (p *andPlan) String() string {
+	if !p.children[0].indexed() {
+		return "scan(" + joinPlans(p.children) + ")"
+	}
+	var intersect, filter []queryPlan
+	for _, child := range p.children {
+		if child.indexed() {
+			intersect = append(intersect, child)
+		} else {
+			filter = append(filter, child)
+		}
+	}
+	s := "intersect(" + joinPlans(intersect) + ")"
+	if len(filter) > 0 {
+		s += " filter(" + joinPlans(filter) + ")"
+	}
+	return s
+}
+
+type orPlan struct {
+	children []queryPlan
+	total    int
+}
+
+func (p *orPlan) cost() int {
+	cost := 0
+	for _, child := range p.children {
+		cost += child.cost()
+	}
+	return min(cost, p.total)
+}
+
+func (p *orPlan) indexed() bool {
+	for _, child := range p.children {
+		if !child.indexed() {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *orPlan) match(id int) bool {
+	for _, child := range p.children {
+		if child.match(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *orPlan) ids() []int {
+	if !p.indexed() {
+		return (&filterPlan{total: p.total, test: p.match}).ids()
+	}
+	var ids []int
+	for _, child := range p.children {
+		ids = unionSorted(ids, child.ids())
+	}
+	return ids
+}
+
+func (p *orPlan) String() string {
+	if !p.indexed() {
+		return "scan(" + joinPlans(p.children) + ")"
+	}
+	return "union(" + joinPlans(p.children) + ")"
+}
+
+type notPlan struct {
+	child queryPlan
+	total int
+}
+
+func (p *notPlan) cost() int         { return p.total }
+func (p *notPlan) indexed() bool     { return false }
+func (p *notPlan) match(id int) bool { return !p.child.match(id) }
+func (p *notPlan) String() string    { return "not(" + p.child.String() + ")" }
+
+func (p *notPlan) ids() []int {
+	return (&filterPlan{total: p.total, test: p.match}).ids()
+}
+
+func joinPlans(plans []queryPlan) string {
+	parts := make([]string, len(plans))
+	for i, plan := range plans {
+		parts[i] = plan.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func intersectSorted(a, b []int) []int {
+	result := make([]int, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+func unionSorted(a, b []int) []int {
+	result := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+func compareQuery(op string, a, b int) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func (api *API) planQueryPredicate(predicate *queryPredicate) queryPlan {
+	total := len(api.Quotes)
+	desc := fmt.Sprintf("%s%s%s", predicate.field, predicate.op, predicate.value)
+
+	switch predicate.field {
+	case "tag":
+		return &postingPlan{desc: desc, list: api.Tags.NameToQuotes[strings.TrimSpace(predicate.value)]}
+
+	case "author":
+		list, ok := api.Authors.NameToQuotes[url.QueryEscape(predicate.value)]
+		if !ok {
+			list = api.Authors.NameToQuotes[predicate.value]
+		}
+		return &postingPlan{desc: desc, list: list}
+
+	case "author_count":
+		var list []int
+		for _, name := range api.Authors.Names {
+			if quoteIDs := api.Authors.NameToQuotes[name]; compareQuery(predicate.op, len(quoteIDs), predicate.number) {
+				list = append(list, quoteIDs...)
+			}
+		}
+		sort.Ints(list)
+		return &postingPlan{desc: desc, list: list}
+
+	case "id":
+		// Clamped so the arithmetic below cannot overflow, ids outside the
+		// quotes behave the same either way.
+		number := min(max(predicate.number, -1), total)
+		from, to := 0, total
+		switch predicate.op {
+		case "=":
+			from, to = number, number+1
+		case "<":
+			to = number
+		case "<=":
+			to = number + 1
+		case ">":
+			from = number + 1
+		case ">=":
+			from = number
+		case "!=":
+			return &filterPlan{desc: desc, total: total, test: func(id int) bool { return id != predicate.number }}
+		}
+		from, to = max(from, 0), min(to, total)
+		return &rangePlan{desc: desc, from: from, to: max(from, to)}
+
+	case "len":
+		return &filterPlan{desc: desc, total: total, test: func(id int) bool {
+			return compareQuery(predicate.op, utf8.RuneCountInString(api.Quotes[id].Text), predicate.number)
+		}}
+
+	case "tag_count":
+		return &filterPlan{desc: desc, total: total, test: func(id int) bool {
+			return compareQuery(predicate.op, len(api.Quotes[id].Tags), predicate.number)
+		}}
+
+	case "year":
+		// A year of 0 is unknown and matches no comparison.
+		return &filterPlan{desc: desc, total: total, test: func(id int) bool {
+			return api.Quotes[id].Year != 0 && compareQuery(predicate.op, api.Quotes[id].Year, predicate.number)
+		}}
+
+	case "source":
+		needle := strings.ToLower(predicate.value)
+		return &filterPlan{desc: desc, total: total, test: func(id int) bool {
+			return strings.Contains(strings.ToLower(api.Quotes[id].Source), needle)
+		}}
+
+	case "language":
+		return &filterPlan{desc: desc, total: total, test: func(id int) bool {
+			return strings.EqualFold(api.Quotes[id].Language, predicate.value)
+		}}
+
+	case "verified":
+		verified := predicate.value == "true"
+		return &filterPlan{desc: desc, total: total, test: func(id int) bool {
+			return api.Quotes[id].Verified == verified
+		}}
+
+	default: // text
+		needle := strings.ToLower(predicate.value)
+		return &filterPlan{desc: desc, total: total, test: func(id int) bool {
+			return strings.Contains(strings.ToLower(api.Quotes[id].Text), needle)
+		}}
+	}
+}
+
+// planQuery turns the syntax tree into a plan, the children of an AND are
+// ordered so the cheapest indexed child drives the evaluation.
+func (api *API) planQuery(expr queryExpr) queryPlan {
+	total := len(api.Quotes)
+	switch e := expr.(type) {
+	case *queryAnd:
+		children := make([]queryPlan, len(e.children))
+		for i, child := range e.children {
+			children[i] = api.planQuery(child)
+		}
+		sort.SliceStable(children, func(i, j int) bool {
+			if children[i].indexed() != children[j].indexed() {
+				return children[i].indexed()
+			}
+			return children[i].cost() < children[j].cost()
+		})
+		return &andPlan{children: children, total: total}
+	case *queryOr:
+		children := make([]queryPlan, len(e.children))
+		for i, child := range e.children {
+			children[i] = api.planQuery(child)
+		}
+		return &orPlan{children: children, total: total}
+	case *queryNot:
+		return &notPlan{child: api.planQuery(e.child), total: total}
+	default:
+		return api.planQueryPredicate(e.(*queryPredicate))
+	}
+}
+
+// QueryHandler filters quotes with the query language of the q parameter,
+// for example /query?q=author_count>50 AND tag:science AND len<100.
+func (api *API) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	format := getOutputFormat(r)
+
+	expr, err := parseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		returnError(w, format, http.StatusBadRequest, "Invalid query", err.Error())
+		return
+	}
+	lookup := startSpan(r.Context(), "index lookup")
+	plan := api.planQuery(expr)
+	quoteIDs := plan.ids()
+	lookup.SetAttr("go_quote.index", "query")
+	lookup.SetAttr("go_quote.results", len(quoteIDs))
+	lookup.End()
+
+	w.Header().Set("X-Query-Plan", plan.String())
+	api.serveQuoteList(w, r, quoteIDs, "Quote query", "Quotes matching "+r.URL.Query().Get("q"), nil)
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Parser of the /query language. Predicates are combined with AND, OR, NOT
+// and parentheses, AND binds stronger than OR:
+//
+//	author_count>50 AND tag:science AND len<100
+//	(tag:love OR tag:life) AND NOT author:"Oscar Wilde"
+
+const (
+	maxQueryLength = 1024
+	// maxQueryDepth bounds the nesting of parentheses and NOT, the parser
+	// recurses once per level.
+	maxQueryDepth = 32
+)
+
+type queryTokenKind int
+
+const (
+	queryEOF queryTokenKind = iota
+	queryWord
+	queryString
+	queryOperator
+	queryLParen
+	queryRParen
+)
+
+type queryToken struct {
+	kind  queryTokenKind
+	value string
+	pos   int
+}
+
+type querySyntaxError struct {
+	pos     int
+	message string
+}
+
+func (e *querySyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.message, e.pos+1)
+}
+
+func isQueryOperatorChar(r rune) bool {
+	return r == ':' || r == '=' || r == '!' || r == '<' || r == '>'
+}
+
+func lexQuery(src string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{queryLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{queryRParen, ")", i})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, &querySyntaxError{start, "unterminated string"}
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				} else if runes[i] == r {
+					break
+				}
+				sb.WriteRune(runes[i])
+			}
+			i++
+			tokens = append(tokens, queryToken{queryString, sb.String(), start})
+		case isQueryOperatorChar(r):
+			start := i
+			for i < len(runes) && isQueryOperatorChar(runes[i]) {
+				i++
+			}
+			op := string(runes[start:i])
+			switch op {
+			case ":", "=", "!=", "<", "<=", ">", ">=":
+			default:
+				return nil, &querySyntaxError{start, fmt.Sprintf("unknown operator %q", op)}
+			}
+			tokens = append(tokens, queryToken{queryOperator, op, start})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !isQueryOperatorChar(runes[i]) &&
+				runes[i] != '(' && runes[i] != ')' && runes[i] != '"' && runes[i] != '\'' {
+				i++
+			}
+			tokens = append(tokens, queryToken{queryWord, string(runes[start:i]), start})
+		}
+	}
+	return append(tokens, queryToken{queryEOF, "", len(runes)}), nil
+}
+
+// queryExpr is the parsed syntax tree, queryPredicate its leaves.
+type queryExpr interface{}
+
+type queryAnd struct{ children []queryExpr }
+
+type queryOr struct{ children []queryExpr }
+
+type queryNot struct{ child queryExpr }
+
+type queryPredicate struct {
+	field  string
+	op     string
+	value  string
+	number int
+	pos    int
+}
+
+type queryFieldKind int
+
+const (
+	queryFieldString queryFieldKind = iota
+	queryFieldNumber
+	queryFieldBool
+)
+
+// queryFields lists the fields and how their values compare.
+var queryFields = map[string]queryFieldKind{
+	"tag":          queryFieldString,
+	"author":       queryFieldString,
+	"text":         queryFieldString,
+	"source":       queryFieldString,
+	"language":     queryFieldString,
+	"verified":     queryFieldBool,
+	"id":           queryFieldNumber,
+	"len":          queryFieldNumber,
+	"year":         queryFieldNumber,
+	"tag_count":    queryFieldNumber,
+	"author_count": queryFieldNumber,
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	depth  int
+}
+
+func (p *queryParser) enter(pos int) error {
+	if p.depth++; p.depth > maxQueryDepth {
+		return &querySyntaxError{pos, fmt.Sprintf("query nested deeper than %d levels", maxQueryDepth)}
+	}
+	return nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.pos]
+	if token.kind != queryEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *queryParser) keyword(name string) bool {
+	token := p.peek()
+	if token.kind == queryWord && strings.EqualFold(token.value, name) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []queryExpr{left}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &queryOr{children}, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []queryExpr{left}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &queryAnd{children}, nil
+}
+
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if token := p.peek(); p.keyword("NOT") {
+		if err := p.enter(token.pos); err != nil {
+			return nil, err
+		}
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		return &queryNot{child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryExpr, error) {
+	token := p.next()
+	switch token.kind {
+	case queryLParen:
+		if err := p.enter(token.pos); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != queryRParen {
+			return nil, &querySyntaxError{closing.pos, "expected )"}
+		}
+		p.depth--
+		return expr, nil
+	case queryWord:
+		return p.parsePredicate(token)
+	case queryEOF:
+		return nil, &querySyntaxError{token.pos, "unexpected end of query"}
+	}
+	return nil, &querySyntaxError{token.pos, fmt.Sprintf("unexpected %q", token.value)}
+}
+
+func (p *queryParser) parsePredicate(field queryToken) (queryExpr, error) {
+	name := strings.ToLower(field.value)
+	kind, ok := queryFields[name]
+	if !ok {
+		return nil, &querySyntaxError{field.pos, fmt.Sprintf("unknown field %q", field.value)}
+	}
+
+	op := p.next()
+	if op.kind != queryOperator {
+		return nil, &querySyntaxError{op.pos, fmt.Sprintf("expected an operator after %s", name)}
+	}
+	value := p.next()
+	if value.kind != queryWord && value.kind != queryString {
+		return nil, &querySyntaxError{value.pos, fmt.Sprintf("expected a value after %s%s", name, op.value)}
+	}
+
+	predicate := &queryPredicate{field: name, op: op.value, value: value.value, pos: field.pos}
+	if kind != queryFieldNumber {
+		if op.value != ":" && op.value != "=" && op.value != "!=" {
+			return nil, &querySyntaxError{op.pos, fmt.Sprintf("%s only supports :, = and !=", name)}
+		}
+		if kind == queryFieldBool {
+			b, err := strconv.ParseBool(value.value)
+			if err != nil {
+				return nil, &querySyntaxError{value.pos, fmt.Sprintf("%s expects true or false", name)}
+			}
+			predicate.value = strconv.FormatBool(b)
+		}
+		if op.value == "!=" {
+			predicate.op = "="
+			return &queryNot{predicate}, nil
+		}
+		return predicate, nil
+	}
+
+	if op.value == ":" {
+		predicate.op = "="
+	}
+	number, err := strconv.Atoi(value.value)
+	if err != nil {
+		return nil, &querySyntaxError{value.pos, fmt.Sprintf("%s expects a number", name)}
+	}
+	predicate.number = number
+	return predicate, nil
+}
+
+func parseQuery(src string) (queryExpr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &querySyntaxError{0, "empty query"}
+	}
+	if len(src) > maxQueryLength {
+		return nil, &querySyntaxError{maxQueryLength, fmt.Sprintf("query longer than %d bytes", maxQueryLength)}
+	}
+	tokens, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != queryEOF {
+		return nil, &querySyntaxError{token.pos, fmt.Sprintf("unexpected %q", token.value)}
+	}
+	return expr, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQueryResults(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"tag:love", []int{0, 1}},
+		{"TAG = love and author:'Ann Example'", []int{0}},
+		{"author:Ann+Example", []int{0, 2}},
+		{"author_count>1 AND len<20", []int{2}},
+		{"tag:life OR tag:food", []int{0, 2}},
+		{"NOT tag:love", []int{2}},
+		{"tag!=love", []int{2}},
+		{"(tag:life OR author:\"Bob Example\") AND id>=1", []int{1, 2}},
+		{"text:QUOTE AND tag_count=1", []int{1, 2}},
+		{"id<0", []int{}},
+		{"tag:missing OR id=5", []int{}},
+		{"id>9223372036854775807", []int{}},
+		{"id<=9223372036854775807", []int{0, 1, 2}},
+		{"id>=-9223372036854775808", []int{0, 1, 2}},
+		{"id=-9223372036854775808", []int{}},
+		{strings.Repeat("(", 32) + "tag:love" + strings.Repeat(")", 32), []int{0, 1}},
+	}
+
+	for _, tt := range tests {
+		expr, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		plan := api.planQuery(expr)
+		got := plan.ids()
+		if !slices.Equal(append([]int{}, got...), tt.want) {
+			t.Errorf("%s: ids = %v, want %v (plan %s)", tt.query, got, tt.want, plan)
+		}
+
+		var scanned []int
+		for id := range api.Quotes {
+			if plan.match(id) {
+				scanned = append(scanned, id)
+			}
+		}
+		if !slices.Equal(append([]int{}, scanned...), tt.want) {
+			t.Errorf("%s: match = %v, want %v", tt.query, scanned, tt.want)
+		}
+	}
+}
+
+func TestQueryPlan(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	expr, err := parseQuery("len<100 AND author_count>1 AND tag:life AND NOT tag:food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "intersect(tag:life(1), author_count>1(2)) filter(scan(len<100), not(tag:food(1)))"
+	if got := api.planQuery(expr).String(); got != want {
+		t.Errorf("plan = %s, want %s", got, want)
+	}
+}
+
+func TestQueryMetadata(t *testing.T) {
+	quotes := append(Quotes{}, testQuotes...)
+	quotes[0].Source, quotes[0].Year, quotes[0].Language = "The Cookbook", 1901, "en"
+	quotes[1].Language, quotes[1].Verified = "nl", true
+	quotes[2].Source, quotes[2].Year, quotes[2].Verified = "Cookbook Letters", 1950, true
+	api := newTestAPI(quotes)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"source:cookbook", []int{0, 2}},
+		{"language:EN", []int{0}},
+		{"language!=en", []int{1, 2}},
+		{"year>=1900 AND year<1950", []int{0}},
+		// Quote 1 has no year, it matches no year comparison.
+		{"year<1950", []int{0}},
+		{"year!=2000", []int{0, 2}},
+		{"NOT year<1950", []int{1, 2}},
+		{"verified:true", []int{1, 2}},
+		{"verified=false OR source:letters", []int{0, 2}},
+	}
+
+	for _, tt := range tests {
+		expr, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := api.planQuery(expr).ids(); !slices.Equal(append([]int{}, got...), tt.want) {
+			t.Errorf("%s: ids = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQuerySyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		error string
+	}{
+		{"", "empty query at position 1"},
+		{"tag:", "expected a value after tag: at position 5"},
+		{"colour:red", `unknown field "colour" at position 1`},
+		{"len<short", "len expects a number at position 5"},
+		{"tag>love", "tag only supports :, = and != at position 4"},
+		{"(tag:love", "expected ) at position 10"},
+		{"tag:love tag:life", `unexpected "tag" at position 10`},
+		{"tag:'love", "unterminated string at position 5"},
+		{"len=>3", `unknown operator "=>" at position 4`},
+		{"verified:maybe", "verified expects true or false at position 10"},
+		{strings.Repeat("(", 33) + "tag:love" + strings.Repeat(")", 33), "query nested deeper than 32 levels at position 33"},
+		{strings.Repeat("NOT ", 33) + "tag:love", "query nested deeper than 32 levels at position 129"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseQuery(tt.query)
+		if err == nil || err.Error() != tt.error {
+			t.Errorf("%q: error = %v, want %s", tt.query, err, tt.error)
+		}
+	}
+}
+
+func TestQueryHandler(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	rec := serveTestRequest(api, "/query?page_size=1&q="+url.QueryEscape("tag:love AND len>5"))
+	if rec.Code != 200 {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response PaginatedQuotesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Pagination.Total != 2 || len(response.Quotes) != 1 || response.Quotes[0].ID != 0 {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if plan := rec.Header().Get("X-Query-Plan"); plan != "intersect(tag:love(2)) filter(scan(len>5))" {
+		t.Errorf("X-Query-Plan = %q", plan)
+	}
+
+	rec = serveTestRequest(api, "/query?format=text&q="+url.QueryEscape("author:'Bob Example'"))
+	if !strings.HasPrefix(rec.Body.String(), "Quote: Second quote\n") {
+		t.Errorf("text output = %q", rec.Body.String())
+	}
+
+	rec = serveTestRequest(api, "/query?q="+url.QueryEscape("tag:love AND"))
+	if rec.Code != 400 || !strings.Contains(rec.Body.String(), fmt.Sprintf("%q", "unexpected end of query at position 13")) {
+		t.Errorf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"math/rand"
@@ -62,9 +63,13 @@ func serveAudioQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestDa
 		"Trinoids",
 	}
 	scary = append(scary, q.Text)
+	span := startSpan(requestData.Context, "tts")
+	span.SetAttr("go_quote.format", format)
 	if api.Runtime == "darwin" {
 		voice := getRandomVoice(format)
 		fmt.Println("Used voice:", voice)
+		span.SetAttr("go_quote.tts.engine", "say")
+		span.SetAttr("go_quote.tts.voice", voice)
 		args := []string{"-o", tempFile, "-v", voice}
 		if format == "wav" {
 			args = append(args, "--data-format=LEF32@32000")
@@ -74,9 +79,18 @@ func serveAudioQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestDa
 	} else {
 		tempFile = filepath.Join("/usr/share/pico/lang", internalFilename)
 		cmd = exec.Command("pico2wave", "-w", tempFile, text)
+		span.SetAttr("go_quote.tts.engine", "pico2wave")
+	}
+	if span != nil {
+		// Tools that support it continue the trace from the environment.
+		cmd.Env = append(os.Environ(), "TRACEPARENT="+span.traceparent())
 	}
 
 	output, err := cmd.CombinedOutput()
+	if err != nil {
+		span.SetError(err.Error())
+	}
+	span.End()
 	if err != nil {
 		log.Printf("Error generating speech: %v, output: %s", err, output)
 		http.Error(w, "Error generating speech", http.StatusInternalServerError)
@@ -233,19 +247,24 @@ func processTags(maxTags int, tags []string) []string {
 	return tags
 }
 
-func quoteToCSV(quote ResponseQuote) string {
+func quoteToCSV(quote ResponseQuote, fields FieldSet) string {
 	var sb strings.Builder
-	sb.WriteString(`"ID","Text","Author","Tags"`)
+	columns := csvColumns(fields, FieldID, FieldText, FieldAuthor, FieldAuthorID, FieldTags,
+		FieldSource, FieldYear, FieldLanguage, FieldURL, FieldVerified)
+	row := make([]string, len(columns))
+	csvRow(row, columns, &quote)
+
+	sb.WriteString(quotedCSVLine(csvHeader(columns)))
 	sb.WriteString("\n")
-	sb.WriteString(fmt.Sprintf(`"%d","%s","%s","%s"`,
-		quote.ID,
-		strings.ReplaceAll(quote.Text, `"`, `""`),
-		strings.ReplaceAll(quote.Author, `"`, `""`),
-		strings.Join(quote.Tags, "|")))
+	sb.WriteString(quotedCSVLine(row))
 	return sb.String()
 }
 
 func quoteToEmbeddedHTML(quote ResponseQuote) string {
+	attribution := quote.Author
+	if citation := quote.Citation(); citation != "" {
+		attribution += ", " + citation
+	}
 	return fmt.Sprintf(`
 <blockquote class="quote-embed" 
     style="font-family: Arial, sans-serif; max-width: 500px; margin: 20px auto; padding: 20px;
@@ -257,7 +276,7 @@ func quoteToEmbeddedHTML(quote ResponseQuote) string {
     <div style="margin-top: 10px; font-size: 14px; color: #888;">
         ID: %d • %s
     </div>
-</blockquote>`, quote.Text, quote.Author, quote.ID, strings.Join(quote.Tags, " • "))
+</blockquote>`, quote.Text, attribution, quote.ID, strings.Join(quote.Tags, " • "))
 }
 
 type OEmbedResponse struct {
@@ -287,65 +306,6 @@ func (oe *OEmbedResponse) Create(quote ResponseQuote, baseURL string) {
 	oe.Height = 200
 }
 
-type AtomFeed struct {
-	XMLName xml.Name `xml:"feed"`
-	XMLNS   string   `xml:"xmlns,attr"`
-	Title   string   `xml:"title"`
-	Link    struct {
-		Href string `xml:"href,attr"`
-		Rel  string `xml:"rel,attr"`
-	} `xml:"link"`
-	Updated string     `xml:"updated"`
-	Author  AtomAuthor `xml:"author"`
-	ID      string     `xml:"id"`
-	Entry   AtomEntry  `xml:"entry"`
-}
-
-type AtomAuthor struct {
-	Name string `xml:"name"`
-}
-
-type AtomEntry struct {
-	Title     string     `xml:"title"`
-	Link      AtomLink   `xml:"link"`
-	ID        string     `xml:"id"`
-	Updated   string     `xml:"updated"`
-	Content   string     `xml:"content"`
-	Published string     `xml:"published"`
-	Author    AtomAuthor `xml:"author"`
-}
-
-type AtomLink struct {
-	Href string `xml:"href,attr"`
-	Rel  string `xml:"rel,attr"`
-}
-
-func (af *AtomFeed) Create(quote ResponseQuote, baseURL, quoteURL string) {
-	now := time.Now().Format(time.RFC3339)
-
-	af.XMLNS = "http://www.w3.org/2005/Atom"
-	af.Title = "Random Quote"
-	af.Updated = now
-	af.Author = AtomAuthor{Name: "Quotes API"}
-	af.ID = quoteURL
-	af.Link = struct {
-		Href string `xml:"href,attr"`
-		Rel  string `xml:"rel,attr"`
-	}{
-		Href: baseURL,
-		Rel:  "self",
-	}
-	af.Entry = AtomEntry{
-		Title:     "Quote by " + quote.Author,
-		Updated:   now,
-		Published: now,
-		ID:        quoteURL,
-		Content:   quote.Text,
-		Author:    AtomAuthor{Name: quote.Author},
-		Link:      AtomLink{Href: quoteURL},
-	}
-}
-
 func quoteToEmbeddedJS(quote ResponseQuote) string {
 	return fmt.Sprintf(`
 (function() {
@@ -365,14 +325,29 @@ func mustJSONString(v interface{}) string {
 	return string(b)
 }
 
-func quoteToYAML(quote ResponseQuote) string {
-	return fmt.Sprintf(`quote:
-  id: %d
-  text: "%s"
-  author: "%s"
-  tags:
-    - %s
-`, quote.ID, quote.Text, quote.Author, strings.Join(quote.Tags, "\n    - "))
+func quoteToYAML(quote ResponseQuote, fields FieldSet) string {
+	var sb strings.Builder
+	sb.WriteString("quote:\n")
+	writeYAMLQuote(&sb, &quote, fields, "  ", "  ")
+	return sb.String()
+}
+
+func quoteToText(quote ResponseQuote) string {
+	text := fmt.Sprintf("Quote: %s\nAuthor: %s\nTags: %v\nID: %d\n",
+		quote.Text, quote.Author, strings.Join(quote.Tags, ", "), quote.ID)
+	if citation := quote.Citation(); citation != "" {
+		text += "Source: " + citation + "\n"
+	}
+	if quote.Language != "" {
+		text += "Language: " + quote.Language + "\n"
+	}
+	if quote.URL != "" {
+		text += "URL: " + quote.URL + "\n"
+	}
+	if quote.Verified {
+		text += "Verified: yes\n"
+	}
+	return text
 }
 
 func quoteToMarkdown(quote ResponseQuote) string {
@@ -380,29 +355,24 @@ func quoteToMarkdown(quote ResponseQuote) string {
 	if len(tags) > 0 {
 		tags = "#" + tags
 	}
-	return fmt.Sprintf("> %s\n\n— %s\n\nQuote ID: %d\n\n%s", quote.Text, quote.Author, quote.ID, tags)
-}
-
-func quoteToRSS(quote ResponseQuote, quoteUrl string) string {
-	pubDate := time.Now().Format(time.RFC1123Z)
-	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" ?>
-<rss version="2.0">
-<channel>
-  <title>Random Quote</title>
-  <link>/quotes/%s</link>
-  <description>Random Quote of the Moment</description>
-  <item>
-    <title>Quote by %s</title>
-    <description>%s</description>
-    <author>%s</author>
-    <pubDate>%s</pubDate>
-    <guid>%v</guid>
-  </item>
-</channel>
-</rss>`, quoteUrl, quote.Author, quote.Text, quote.Author, pubDate, quoteUrl)
-}
-
-func quoteToHTML(quote ResponseQuote, highlightedTag string) string {
+	attribution := quote.Author
+	if citation := quote.Citation(); citation != "" {
+		attribution += ", *" + citation + "*"
+	}
+	if quote.URL != "" {
+		attribution += " ([source](" + quote.URL + "))"
+	}
+	details := fmt.Sprintf("Quote ID: %d", quote.ID)
+	if quote.Language != "" {
+		details += " · Language: " + quote.Language
+	}
+	if quote.Verified {
+		details += " · Verified"
+	}
+	return fmt.Sprintf("> %s\n\n— %s\n\n%s\n\n%s", quote.Text, attribution, details, tags)
+}
+
+func quoteToHTML(quote ResponseQuote, highlightedTag string, similar []ResponseQuote) string {
 	var tagHTML strings.Builder
 	for _, tag := range quote.Tags {
 		cleanTag := strings.TrimSpace(tag)
@@ -417,6 +387,13 @@ func quoteToHTML(quote ResponseQuote, highlightedTag string) string {
 	authorLink := fmt.Sprintf(`<a href="/authors/%s" class="author">— %s</a>`,
 		strings.TrimSpace(quote.AuthorID),
 		strings.TrimSpace(quote.Author))
+	if citation := quote.Citation(); citation != "" {
+		citation = html.EscapeString(citation)
+		if quote.URL != "" {
+			citation = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(quote.URL), citation)
+		}
+		authorLink += fmt.Sprintf(`<cite class="source">%s</cite>`, citation)
+	}
 
 	audioURL := fmt.Sprintf("/quote/%d?format=wav", quote.ID)
 	quoteLink := fmt.Sprintf("/quote/%d", quote.ID)
@@ -452,6 +429,7 @@ func quoteToHTML(quote ResponseQuote, highlightedTag string) string {
             </div>
         </div>
     </div>
+    %s
     <style>
         html {
             -webkit-text-size-adjust: 100%%;
@@ -491,6 +469,44 @@ func quoteToHTML(quote ResponseQuote, highlightedTag string) string {
         .author:hover {
             color: #1da1f2;
         }
+        .source {
+            display: block;
+            font-size: 14px;
+            color: #657786;
+            margin-bottom: 15px;
+        }
+        .source a {
+            color: inherit;
+        }
+        .similar-quotes {
+            margin-top: 20px;
+            border-top: 1px solid #e1e8ed;
+            padding-top: 15px;
+        }
+        .similar-quotes h2 {
+            font-size: 16px;
+            color: #14171a;
+            margin: 0 0 10px;
+        }
+        .similar-quotes ul {
+            list-style: none;
+            margin: 0;
+            padding: 0;
+        }
+        .similar-quotes li {
+            margin-bottom: 8px;
+            font-size: 14px;
+        }
+        .similar-quotes a {
+            color: #14171a;
+            text-decoration: none;
+        }
+        .similar-quotes a:hover {
+            color: #1da1f2;
+        }
+        .similar-author {
+            color: #657786;
+        }
         .tags {
             margin-bottom: 15px;
         }
@@ -691,34 +707,36 @@ function showCopiedFeedback() {
     }, 2000);
 }
     </script>
-    `, quote.Text, authorLink, tagHTML.String(), quote.ID, audioURL, quoteLink, quote.ID, quoteLink)
+    `, quote.Text, authorLink, tagHTML.String(), quote.ID, audioURL, quoteLink, quote.ID, quoteLink, similarQuotesHTML(similar))
 }
 
 func serveJSONQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
 	w.Header().Set("Content-Type", "application/json")
+	if !requestData.Fields.All() {
+		json.NewEncoder(w).Encode(newSparseQuote(&q, requestData.Fields))
+		return
+	}
 	json.NewEncoder(w).Encode(q)
 }
 
 func serveXMLQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
 	w.Header().Set("Content-Type", "application/xml")
-	xmlQuote := XMLQuote{
-		Text:   q.Text,
-		Author: q.Author,
-		Tags:   q.Tags,
-		ID:     q.ID,
+	if !requestData.Fields.All() {
+		xml.NewEncoder(w).Encode(newXMLSparseQuote(&q, requestData.Fields))
+		return
 	}
+	xmlQuote := newXMLQuote(q)
 	xml.NewEncoder(w).Encode(xmlQuote)
 }
 
 func serveHTMLQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprint(w, quoteToHTML(q, ""))
+	fmt.Fprint(w, quoteToHTML(q, "", api.similarQuotes(q.ID)))
 }
 
 func serveTextQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
 	w.Header().Set("Content-Type", "text/plain")
-	fmt.Fprintf(w, "Quote: %s\nAuthor: %s\nTags: %v\nID: %d\n",
-		q.Text, q.Author, strings.Join(q.Tags, ", "), q.ID)
+	fmt.Fprint(w, quoteToText(q))
 }
 
 func serveMarkdownQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
@@ -728,24 +746,20 @@ func serveMarkdownQuote(w http.ResponseWriter, q ResponseQuote, api *API, reques
 
 func serveYAMLQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
 	w.Header().Set("Content-Type", "application/yaml")
-	fmt.Fprint(w, quoteToYAML(q))
+	fmt.Fprint(w, quoteToYAML(q, requestData.Fields))
 }
 
 func serveCSVQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
 	w.Header().Set("Content-Type", "text/csv")
-	fmt.Fprint(w, quoteToCSV(q))
+	fmt.Fprint(w, quoteToCSV(q, requestData.Fields))
 }
 
 func serveRSSQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
-	w.Header().Set("Content-Type", "application/rss+xml")
-	fmt.Fprint(w, quoteToRSS(q, requestData.QuoteURL))
+	serveFeed(w, api.singleQuoteFeed(q, requestData), "rss")
 }
 
 func serveAtomQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
-	w.Header().Set("Content-Type", "application/atom+xml")
-	feed := &AtomFeed{}
-	feed.Create(q, requestData.BaseURL, requestData.QuoteURL)
-	xml.NewEncoder(w).Encode(feed)
+	serveFeed(w, api.singleQuoteFeed(q, requestData), "atom")
 }
 
 func serveOEmbedJSONQuote(w http.ResponseWriter, q ResponseQuote, api *API, requestData *ResponseInfo) {
@@ -2,36 +2,48 @@ package main
 
 import (
 	"bytes"
+	"encoding/xml"
 	"fmt"
 	"strings"
 )
 
-func quotesToYAML(quotes []ResponseQuote) string {
+func quotesToYAML(quotes []ResponseQuote, fields FieldSet) string {
 	var buf strings.Builder
 	buf.WriteString("quotes:\n")
-	for _, quote := range quotes {
-		buf.WriteString(fmt.Sprintf("  - id: %d\n", quote.ID))
-		buf.WriteString(fmt.Sprintf("    text: \"%s\"\n", strings.ReplaceAll(quote.Text, "\"", "\\\"")))
-		buf.WriteString(fmt.Sprintf("    author: \"%s\"\n", strings.ReplaceAll(quote.Author, "\"", "\\\"")))
-		buf.WriteString("    tags:\n")
-		for _, tag := range quote.Tags {
-			buf.WriteString(fmt.Sprintf("      - %s\n", tag))
+	for i := range quotes {
+		writeYAMLQuote(&buf, &quotes[i], fields, "  - ", "    ")
+	}
+	return buf.String()
+}
+
+func quotesToXML(quotes []ResponseQuote, fields FieldSet) string {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<quotes>")
+	encoder := xml.NewEncoder(&buf)
+	for i := range quotes {
+		if fields.All() {
+			encoder.Encode(newXMLQuote(quotes[i]))
+		} else {
+			encoder.Encode(newXMLSparseQuote(&quotes[i], fields))
 		}
 	}
+	buf.WriteString("</quotes>")
 	return buf.String()
 }
 
-func quotesToCSV(quotes ResponseQuotes) string {
+func quotesToCSV(quotes ResponseQuotes, fields FieldSet) string {
 	var buf bytes.Buffer
-	buf.WriteString(`"ID","Text","Author","Tags"`)
+	columns := csvColumns(fields, FieldID, FieldText, FieldAuthor, FieldAuthorID, FieldTags,
+		FieldSource, FieldYear, FieldLanguage, FieldURL, FieldVerified)
+	row := make([]string, len(columns))
+
+	buf.WriteString(quotedCSVLine(csvHeader(columns)))
 	buf.WriteString("\n")
 
-	for _, quote := range quotes {
-		buf.WriteString(fmt.Sprintf(`"%d","%s","%s","%s"`,
-			quote.ID,
-			strings.ReplaceAll(quote.Text, `"`, `""`),
-			strings.ReplaceAll(quote.Author, `"`, `""`),
-			strings.Join(quote.Tags, "|")))
+	for i := range quotes {
+		csvRow(row, columns, &quotes[i])
+		buf.WriteString(quotedCSVLine(row))
 		buf.WriteString("\n")
 	}
 
@@ -108,7 +120,7 @@ func quotesToHTML(response struct {
 	}
 
 	for _, quote := range response.Quotes {
-		quoteHTML := quoteToHTML(quote, tagName)
+		quoteHTML := quoteToHTML(quote, tagName, nil)
 		htmlBuilder.WriteString(quoteHTML)
 	}
 
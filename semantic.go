@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	semanticDefaultK    = 10
+	semanticMaxK        = 100
+	semanticMaxBodySize = 1 << 20
+)
+
+// semanticRequest is the body of a POST to /search/semantic.
+type semanticRequest struct {
+	Vector []float32 `json:"vector"`
+}
+
+// SemanticSearchHandler finds the quotes with embeddings nearest to that of
+// the quote in the id parameter, or to the vector posted as
+// {"vector": [...]}. The k parameter sets the number of results.
+func (api *API) SemanticSearchHandler(w http.ResponseWriter, r *http.Request) {
+	format := getOutputFormat(r)
+	if api.Semantic == nil {
+		returnError(w, format, http.StatusNotFound, "Semantic search not available", "No embeddings are loaded")
+		return
+	}
+
+	query := r.URL.Query()
+	k := semanticDefaultK
+	if value := query.Get("k"); value != "" {
+		var err error
+		if k, err = strconv.Atoi(value); err != nil || k < 1 || k > semanticMaxK {
+			returnError(w, format, http.StatusBadRequest, "Invalid k", fmt.Sprintf("k must be between 1 and %d", semanticMaxK))
+			return
+		}
+	}
+	if query.Has("cursor") {
+		returnError(w, format, http.StatusBadRequest, "Invalid cursor", "Search results are ranked, use page based pagination")
+		return
+	}
+
+	var quoteIDs []int
+	var title string
+	switch r.Method {
+	case http.MethodGet:
+		quoteID, err := strconv.Atoi(query.Get("id"))
+		if err != nil {
+			returnError(w, format, http.StatusBadRequest, "Missing id", "Pass the id of a quote or POST a vector")
+			return
+		}
+		lookup := startSpan(r.Context(), "index lookup")
+		ids, ok := api.Semantic.Nearest(quoteID, k)
+		lookup.SetAttr("go_quote.index", "semantic")
+		lookup.SetAttr("go_quote.results", len(ids))
+		lookup.End()
+		if !ok {
+			returnError(w, format, http.StatusNotFound, "Embedding not found", "The quote has no embedding")
+			return
+		}
+		quoteIDs = ids
+		title = fmt.Sprintf("Quotes near quote %d", quoteID)
+	case http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, semanticMaxBodySize))
+		if err != nil {
+			returnError(w, format, http.StatusRequestEntityTooLarge, "Request body too large", err.Error())
+			return
+		}
+		var request semanticRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			returnError(w, format, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+		if len(request.Vector) != api.Semantic.Dimensions() {
+			returnError(w, format, http.StatusBadRequest, "Invalid vector",
+				fmt.Sprintf("vector has %d dimensions, want %d", len(request.Vector), api.Semantic.Dimensions()))
+			return
+		}
+		lookup := startSpan(r.Context(), "index lookup")
+		quoteIDs = api.Semantic.Search(request.Vector, k)
+		lookup.SetAttr("go_quote.index", "semantic")
+		lookup.SetAttr("go_quote.results", len(quoteIDs))
+		lookup.End()
+		title = "Quotes near the vector"
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		returnError(w, format, http.StatusMethodNotAllowed, "Method not allowed", "Use GET or POST")
+		return
+	}
+
+	api.serveQuoteList(w, r, quoteIDs, title, title, nil)
+}
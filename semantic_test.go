@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newSemanticTestAPI(t *testing.T) *API {
+	quotes := Quotes{
+		{Text: "The sea is calm tonight.", Author: "Ann Example"},
+		{Text: "Waves roll in from the ocean.", Author: "Bob Example"},
+		{Text: "Bread is best when fresh.", Author: "Carl Example"},
+		{Text: "A quote without an embedding.", Author: "Dana Example"},
+	}
+	embeddings := &Embeddings{Dimensions: 3, Vectors: [][]float32{
+		{1, 0.1, 0},
+		{0.9, 0.2, 0},
+		{0, 0.1, 1},
+	}}
+	index, err := BuildHNSWIndex(embeddings, len(quotes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPI(quotes)
+	api.Semantic = index
+	return api
+}
+
+func TestSemanticSearchHandler(t *testing.T) {
+	api := newSemanticTestAPI(t)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		api.SetupRoutes(mux)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+		want   []int
+	}{
+		{"by quote", http.MethodGet, "/search/semantic?id=0", "", http.StatusOK, []int{1, 2}},
+		{"k", http.MethodGet, "/search/semantic?id=2&k=1", "", http.StatusOK, []int{1}},
+		{"by vector", http.MethodPost, "/search/semantic?k=2", `{"vector": [0, 1, 2]}`, http.StatusOK, []int{2, 1}},
+		{"no embedding", http.MethodGet, "/search/semantic?id=3", "", http.StatusNotFound, nil},
+		{"missing id", http.MethodGet, "/search/semantic", "", http.StatusBadRequest, nil},
+		{"invalid k", http.MethodGet, "/search/semantic?id=0&k=0", "", http.StatusBadRequest, nil},
+		{"cursor", http.MethodGet, "/search/semantic?id=0&cursor=1", "", http.StatusBadRequest, nil},
+		{"wrong dimensions", http.MethodPost, "/search/semantic", `{"vector": [1, 0]}`, http.StatusBadRequest, nil},
+		{"invalid body", http.MethodPost, "/search/semantic", `[1, 0, 0]`, http.StatusBadRequest, nil},
+		{"method", http.MethodDelete, "/search/semantic?id=0", "", http.StatusMethodNotAllowed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.method, tt.url, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var response PaginatedQuotesResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			got := make([]int, 0, len(response.Quotes))
+			for _, quote := range response.Quotes {
+				got = append(got, quote.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("quotes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	api.Semantic = nil
+	if rec := serve(http.MethodGet, "/search/semantic?id=0", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("without embeddings: status = %d, want 404", rec.Code)
+	}
+}
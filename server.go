@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// newHTTPServer configures the server from the config, timeouts are given in
+// seconds and zero disables them.
+func newHTTPServer(config *Config, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       time.Duration(config.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(config.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(config.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(config.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+	server.SetKeepAlivesEnabled(config.KeepAlives)
+	return server
+}
+
+// largePage is the number of entries above which a page that is not
+// streamed is written without deadlines, a slow client could not receive it
+// in time.
+const largePage = 1000
+
+// liftDeadlines removes the read and write timeouts of the server for a
+// response that is streamed, such as an export of all quotes or an event
+// stream. The read deadline matters as well, once it passes the server
+// cancels the request context.
+func liftDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
+
+// stopStreams ends the event streams and WebSocket sessions on shutdown.
+// http.Server.Shutdown does not wait for hijacked connections and would
+// wait for event streams until the drain deadline.
+func (api *API) stopStreams() {
+	close(api.stopping)
+}
+
+// shutdown drains the HTTP server and stops the gRPC server within the
+// timeout, connections still open then are closed.
+func shutdown(server *http.Server, grpcServer *grpc.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+			}
+		}()
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return err
+	}
+	return nil
+}
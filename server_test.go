@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	config := &Config{ReadTimeout: 30, ReadHeaderTimeout: 10, WriteTimeout: 60, IdleTimeout: 0, MaxHeaderBytes: 4096}
+	server := newHTTPServer(config, http.NotFoundHandler())
+	if server.ReadTimeout != 30*time.Second || server.ReadHeaderTimeout != 10*time.Second ||
+		server.WriteTimeout != time.Minute || server.IdleTimeout != 0 || server.MaxHeaderBytes != 4096 {
+		t.Errorf("server = %+v", server)
+	}
+}
+
+// startTimeoutServer serves the API with short timeouts, like main does.
+func startTimeoutServer(t *testing.T, api *API) (*http.Server, string) {
+	t.Helper()
+	api.stopping = make(chan struct{})
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	config := &Config{ReadTimeout: 1, WriteTimeout: 1, KeepAlives: true, MaxHeaderBytes: 1 << 20}
+	server := newHTTPServer(config, api.SetupMiddleware()(mux))
+	server.RegisterOnShutdown(api.stopStreams)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	return server, "http://" + listener.Addr().String()
+}
+
+func TestShutdownEndsStreams(t *testing.T) {
+	server, url := startTimeoutServer(t, newTestAPI(testQuotes))
+
+	resp, err := http.Get(url + "/stream/random?interval=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	// The stream outlives the read and write timeouts of the server.
+	events := 0
+	for events < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %d events: %v", events, err)
+		}
+		if strings.HasPrefix(line, "event: quote") {
+			events++
+		}
+	}
+
+	started := time.Now()
+	if err := shutdown(server, nil, 5*time.Second); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %v, the stream should end right away", elapsed)
+	}
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Errorf("stream did not end cleanly: %v", err)
+	}
+}
+
+// deadlineRecorder records whether the handler lifted the write deadline.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	lifted bool
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	d.lifted = deadline.IsZero()
+	return nil
+}
+
+func TestLargePagesLiftDeadlines(t *testing.T) {
+	quotes := make(Quotes, largePage+1)
+	for i := range quotes {
+		quotes[i] = Quote{Text: "quote", Author: "Author", Tags: []string{"all"}}
+	}
+	api := newTestAPI(quotes)
+	api.MaxPageSize = largePage + 1
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+
+	tests := []struct {
+		url    string
+		lifted bool
+	}{
+		{"/tags/all?page_size=1001", true},
+		{"/authors/Author?page_size=1001", true},
+		{"/tags/all?page_size=1000", false},
+		{"/tags?page_size=1001", false},
+	}
+	for _, tt := range tests {
+		rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if rec.Code != http.StatusOK || rec.lifted != tt.lifted {
+			t.Errorf("%s: status %d, deadline lifted %v, want %v", tt.url, rec.Code, rec.lifted, tt.lifted)
+		}
+	}
+}
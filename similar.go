@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Similar quotes are ranked by the TF-IDF cosine similarity of their text,
+// the overlap of their tags and a shared author. The rankings are computed
+// on first request and cached per quote.
+
+const (
+	similarTopK = 10
+
+	similarTextWeight   = 0.6
+	similarTagWeight    = 0.3
+	similarAuthorWeight = 0.1
+
+	// similarMaxPostings leaves out terms, tags and authors with more quotes
+	// than this when collecting candidates. Their quotes still score when they
+	// are found through something else.
+	similarMaxPostings = 5000
+)
+
+type similarPosting struct {
+	quote  int32
+	weight float32
+}
+
+type similarTerm struct {
+	term   int32
+	weight float32
+}
+
+// SimilarIndex holds the normalised TF-IDF vectors of the quotes and an
+// inverted index over them.
+type SimilarIndex struct {
+	vectors  [][]similarTerm
+	postings [][]similarPosting
+	cache    []atomic.Pointer[[]int]
+}
+
+func similarTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func BuildSimilarIndex(quotes Quotes) *SimilarIndex {
+	index := &SimilarIndex{
+		vectors: make([][]similarTerm, len(quotes)),
+		cache:   make([]atomic.Pointer[[]int], len(quotes)),
+	}
+
+	// The term counts of a quote are computed twice, once for the document
+	// frequencies and once for the vectors, so only one quote is counted at a
+	// time.
+	terms := make(map[string]int32)
+	counts := make(map[int32]int)
+	countTerms := func(text string, add bool) {
+		clear(counts)
+		for _, token := range similarTokens(text) {
+			if len(token) < 2 {
+				continue
+			}
+			term, ok := terms[token]
+			if !ok {
+				if !add {
+					continue
+				}
+				term = int32(len(terms))
+				terms[token] = term
+			}
+			counts[term]++
+		}
+	}
+
+	var df []int
+	for _, quote := range quotes {
+		countTerms(quote.Text, true)
+		for len(df) < len(terms) {
+			df = append(df, 0)
+		}
+		for term := range counts {
+			df[term]++
+		}
+	}
+
+	index.postings = make([][]similarPosting, len(df))
+	for i, quote := range quotes {
+		countTerms(quote.Text, false)
+		vector := make([]similarTerm, 0, len(counts))
+		norm := 0.0
+		for term, count := range counts {
+			// Terms of a single quote cannot match another one.
+			if df[term] < 2 {
+				continue
+			}
+			weight := (1 + math.Log(float64(count))) * math.Log(float64(len(quotes))/float64(df[term]))
+			vector = append(vector, similarTerm{term, float32(weight)})
+			norm += weight * weight
+		}
+		if norm == 0 {
+			continue
+		}
+		norm = math.Sqrt(norm)
+		sort.Slice(vector, func(a, b int) bool { return vector[a].term < vector[b].term })
+		for j := range vector {
+			vector[j].weight /= float32(norm)
+			index.postings[vector[j].term] = append(index.postings[vector[j].term], similarPosting{int32(i), vector[j].weight})
+		}
+		index.vectors[i] = vector
+	}
+	return index
+}
+
+func tagOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, tagA := range a {
+		for _, tagB := range b {
+			if strings.TrimSpace(tagA) == strings.TrimSpace(tagB) {
+				shared++
+				break
+			}
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// similarQuoteIDs returns the quotes most similar to the quote, best first.
+func (api *API) similarQuoteIDs(quoteID int) []int {
+	index := api.Similar
+	if cached := index.cache[quoteID].Load(); cached != nil {
+		return *cached
+	}
+
+	quote := &api.Quotes[quoteID]
+	text := make(map[int]float64)
+	for _, term := range index.vectors[quoteID] {
+		postings := index.postings[term.term]
+		if len(postings) > similarMaxPostings {
+			continue
+		}
+		for _, posting := range postings {
+			text[int(posting.quote)] += float64(term.weight * posting.weight)
+		}
+	}
+
+	candidates := make(map[int]struct{}, len(text))
+	for id := range text {
+		candidates[id] = struct{}{}
+	}
+	for _, tag := range quote.Tags {
+		if ids := api.Tags.NameToQuotes[strings.TrimSpace(tag)]; len(ids) <= similarMaxPostings {
+			for _, id := range ids {
+				candidates[id] = struct{}{}
+			}
+		}
+	}
+	if ids := api.Authors.NameToQuotes[url.QueryEscape(quote.Author)]; len(ids) <= similarMaxPostings {
+		for _, id := range ids {
+			candidates[id] = struct{}{}
+		}
+	}
+	delete(candidates, quoteID)
+
+	type scored struct {
+		id    int
+		score float64
+	}
+	ranked := make([]scored, 0, len(candidates))
+	for id := range candidates {
+		score := similarTextWeight*text[id] + similarTagWeight*tagOverlap(quote.Tags, api.Quotes[id].Tags)
+		if api.Quotes[id].Author == quote.Author {
+			score += similarAuthorWeight
+		}
+		ranked = append(ranked, scored{id, score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].id < ranked[j].id
+	})
+
+	ids := make([]int, 0, min(len(ranked), similarTopK))
+	for _, candidate := range ranked[:min(len(ranked), similarTopK)] {
+		ids = append(ids, candidate.id)
+	}
+	index.cache[quoteID].Store(&ids)
+	return ids
+}
+
+// similarQuotes returns the similar quotes of a quote ready to render, or
+// nil when the index is not built.
+func (api *API) similarQuotes(quoteID int) []ResponseQuote {
+	if api.Similar == nil || quoteID < 0 || quoteID >= len(api.Quotes) {
+		return nil
+	}
+	ids := api.similarQuoteIDs(quoteID)
+	quotes := make([]ResponseQuote, 0, len(ids))
+	for _, id := range ids {
+		quotes = append(quotes, api.Quotes[id].CreateResponseQuote(id))
+	}
+	return quotes
+}
+
+// similarQuotesHTML renders the "you might also like" section under a quote.
+func similarQuotesHTML(similar []ResponseQuote) string {
+	if len(similar) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(`<div class="similar-quotes"><h2>You might also like</h2><ul>`)
+	for _, quote := range similar {
+		fmt.Fprintf(&sb, `<li><a href="/quotes/%d">%s</a> <span class="similar-author">— %s</span></li>`,
+			quote.ID, html.EscapeString(quote.Text), html.EscapeString(quote.Author))
+	}
+	sb.WriteString(`</ul></div>`)
+	return sb.String()
+}
+
+// SimilarHandler lists the quotes most similar to a quote, for
+// /quotes/{id}/similar.
+func (api *API) SimilarHandler(w http.ResponseWriter, r *http.Request) {
+	format := getOutputFormat(r)
+	quoteID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || quoteID < 0 || quoteID >= len(api.Quotes) {
+		returnError(w, format, http.StatusNotFound, "Quote not found", "Invalid quote ID")
+		return
+	}
+	if r.URL.Query().Has("cursor") {
+		returnError(w, format, http.StatusBadRequest, "Invalid cursor", "Similar quotes are ranked, use page based pagination")
+		return
+	}
+	if api.Similar == nil {
+		returnError(w, format, http.StatusNotFound, "Similar quotes not available", "The similarity index is not built")
+		return
+	}
+
+	lookup := startSpan(r.Context(), "index lookup")
+	quoteIDs := api.similarQuoteIDs(quoteID)
+	lookup.SetAttr("go_quote.index", "similar")
+	lookup.SetAttr("go_quote.results", len(quoteIDs))
+	lookup.End()
+
+	title := fmt.Sprintf("Quotes similar to quote %d", quoteID)
+	api.serveQuoteList(w, r, quoteIDs, title, title, nil)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newSimilarTestAPI() *API {
+	quotes := Quotes{
+		{Text: "The ocean waves crash on the rocky shore.", Author: "Ann Example", Tags: []string{"sea"}},
+		{Text: "Waves of the ocean never stop moving.", Author: "Bob Example", Tags: []string{"sea"}},
+		{Text: "Rocky shore, quiet harbour.", Author: "Ann Example", Tags: []string{"travel"}},
+		{Text: "Bread is best when it is fresh.", Author: "Carl Example", Tags: []string{"food"}},
+		{Text: "Mountains stand tall over the valley.", Author: "Dana Example", Tags: []string{"sea"}},
+	}
+	return newTestAPI(quotes)
+}
+
+func TestSimilarHandler(t *testing.T) {
+	api := newSimilarTestAPI()
+
+	rec := serveTestRequest(api, "/quotes/0/similar")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200\n%s", rec.Code, rec.Body)
+	}
+	var response PaginatedQuotesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]int, 0, len(response.Quotes))
+	for _, quote := range response.Quotes {
+		got = append(got, quote.ID)
+	}
+	if want := []int{1, 4, 2}; !slices.Equal(got, want) {
+		t.Errorf("similar quotes = %v, want %v", got, want)
+	}
+
+	if cached := api.similarQuoteIDs(0); !slices.Equal(cached, got) {
+		t.Errorf("cached ranking = %v, want %v", cached, got)
+	}
+
+	rec = serveTestRequest(api, "/quotes/3/similar?format=text")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "" {
+		t.Errorf("quote without similar quotes: status %d, body %q", rec.Code, rec.Body)
+	}
+
+	errorTests := []struct {
+		url    string
+		status int
+	}{
+		{"/quotes/9/similar", http.StatusNotFound},
+		{"/quotes/x/similar", http.StatusNotFound},
+		{"/quotes/0/similar?cursor=1", http.StatusBadRequest},
+	}
+	for _, tt := range errorTests {
+		if rec := serveTestRequest(api, tt.url); rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.url, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestSimilarFormats(t *testing.T) {
+	api := newSimilarTestAPI()
+
+	tests := []struct {
+		url      string
+		contains string
+	}{
+		{"/quotes/0/similar?format=text", "Waves of the ocean never stop moving."},
+		{"/quotes/0/similar?format=csv", "Rocky shore, quiet harbour."},
+		{"/quotes/0/similar?format=rss", "<rss"},
+		{"/quotes/0?format=html", "You might also like"},
+	}
+	for _, tt := range tests {
+		rec := serveTestRequest(api, tt.url)
+		if !strings.Contains(rec.Body.String(), tt.contains) {
+			t.Errorf("%s: response does not contain %q\n%s", tt.url, tt.contains, rec.Body)
+		}
+	}
+
+	if html := quoteToHTML(api.Quotes[0].CreateResponseQuote(0), "", nil); strings.Contains(html, "You might also like") {
+		t.Error("quoteToHTML renders an empty similar quotes section")
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// statsTopN is the number of authors, tags and tag pairs in the top lists.
+const statsTopN = 20
+
+// Bucket bounds of the histograms, each bucket runs up to the next bound and
+// the last one is open.
+var (
+	statsLengthBounds    = []int{0, 50, 100, 150, 200, 300, 500, 1000}
+	statsWordBounds      = []int{1, 6, 11, 21, 31, 51}
+	statsPerAuthorBounds = []int{1, 2, 6, 11, 51, 101, 501}
+)
+
+type StatsCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type StatsBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+type StatsSummary struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median int     `json:"median"`
+}
+
+type StatsTagPair struct {
+	Tags  [2]string `json:"tags"`
+	Count int       `json:"count"`
+}
+
+// Stats describes the corpus. It is computed once by BuildStats, the
+// handler only renders it.
+type Stats struct {
+	Quotes          int            `json:"quotes"`
+	Authors         int            `json:"authors"`
+	Tags            int            `json:"tags"`
+	TextLength      StatsSummary   `json:"text_length"`
+	LengthHistogram []StatsBucket  `json:"length_histogram"`
+	WordHistogram   []StatsBucket  `json:"word_histogram"`
+	TopAuthors      []StatsCount   `json:"top_authors"`
+	TopTags         []StatsCount   `json:"top_tags"`
+	TagCooccurrence []StatsTagPair `json:"tag_cooccurrence"`
+	QuotesPerAuthor []StatsBucket  `json:"quotes_per_author"`
+}
+
+func newStatsBuckets(bounds []int) []StatsBucket {
+	buckets := make([]StatsBucket, len(bounds))
+	for i, bound := range bounds {
+		switch {
+		case i == len(bounds)-1:
+			buckets[i].Range = strconv.Itoa(bound) + "+"
+		case bounds[i+1]-1 == bound:
+			buckets[i].Range = strconv.Itoa(bound)
+		default:
+			buckets[i].Range = fmt.Sprintf("%d-%d", bound, bounds[i+1]-1)
+		}
+	}
+	return buckets
+}
+
+func countInBucket(buckets []StatsBucket, bounds []int, value int) {
+	i := sort.SearchInts(bounds, value+1) - 1
+	if i >= 0 {
+		buckets[i].Count++
+	}
+}
+
+// topCounts returns the n largest counts, ties by name.
+func topCounts(counts []StatsCount, n int) []StatsCount {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts[:min(n, len(counts))]
+}
+
+func BuildStats(quotes Quotes, authors, tags IndexStructure) *Stats {
+	stats := &Stats{
+		Quotes:          len(quotes),
+		Authors:         authors.Len(),
+		Tags:            tags.Len(),
+		LengthHistogram: newStatsBuckets(statsLengthBounds),
+		WordHistogram:   newStatsBuckets(statsWordBounds),
+		QuotesPerAuthor: newStatsBuckets(statsPerAuthorBounds),
+	}
+
+	lengths := make([]int, len(quotes))
+	total := 0
+	for i, quote := range quotes {
+		lengths[i] = utf8.RuneCountInString(quote.Text)
+		total += lengths[i]
+		countInBucket(stats.LengthHistogram, statsLengthBounds, lengths[i])
+		countInBucket(stats.WordHistogram, statsWordBounds, len(strings.Fields(quote.Text)))
+	}
+	if len(lengths) > 0 {
+		sort.Ints(lengths)
+		stats.TextLength = StatsSummary{
+			Min:    lengths[0],
+			Max:    lengths[len(lengths)-1],
+			Mean:   float64(total) / float64(len(lengths)),
+			Median: lengths[len(lengths)/2],
+		}
+	}
+
+	authorCounts := make([]StatsCount, 0, authors.Len())
+	for _, name := range authors.Names {
+		count := len(authors.NameToQuotes[name])
+		decoded, _ := url.QueryUnescape(name)
+		authorCounts = append(authorCounts, StatsCount{decoded, count})
+		countInBucket(stats.QuotesPerAuthor, statsPerAuthorBounds, count)
+	}
+	stats.TopAuthors = topCounts(authorCounts, statsTopN)
+
+	tagCounts := make([]StatsCount, 0, tags.Len())
+	tagNumbers := make(map[string]int, tags.Len())
+	for i, name := range tags.Names {
+		tagCounts = append(tagCounts, StatsCount{name, len(tags.NameToQuotes[name])})
+		tagNumbers[name] = i
+	}
+	stats.TopTags = topCounts(tagCounts, statsTopN)
+
+	// Pairs are counted by tag number to keep the map small, the lower
+	// number first.
+	pairs := make(map[[2]int]int)
+	var numbers []int
+	for _, quote := range quotes {
+		numbers = numbers[:0]
+		for _, tag := range quote.Tags {
+			if number, ok := tagNumbers[strings.TrimSpace(tag)]; ok {
+				numbers = append(numbers, number)
+			}
+		}
+		sort.Ints(numbers)
+		numbers = slices.Compact(numbers)
+		for i := range numbers {
+			for j := i + 1; j < len(numbers); j++ {
+				pairs[[2]int{numbers[i], numbers[j]}]++
+			}
+		}
+	}
+	cooccurrence := make([]StatsTagPair, 0, len(pairs))
+	for pair, count := range pairs {
+		cooccurrence = append(cooccurrence, StatsTagPair{[2]string{tags.Names[pair[0]], tags.Names[pair[1]]}, count})
+	}
+	sort.Slice(cooccurrence, func(i, j int) bool {
+		a, b := cooccurrence[i], cooccurrence[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Tags[0] != b.Tags[0] {
+			return a.Tags[0] < b.Tags[0]
+		}
+		return a.Tags[1] < b.Tags[1]
+	})
+	stats.TagCooccurrence = cooccurrence[:min(statsTopN, len(cooccurrence))]
+
+	return stats
+}
+
+func (api *API) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	format := getOutputFormat(r)
+	if api.Stats == nil {
+		returnError(w, format, http.StatusNotFound, "Statistics not available", "The statistics are not computed")
+		return
+	}
+
+	switch format {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, statsToHTML(api.Stats))
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		fmt.Fprint(w, statsToCSV(api.Stats))
+	case "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		fmt.Fprint(w, statsToMarkdown(api.Stats))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.Stats)
+	}
+}
+
+// statsSection is a titled list of counts, the shape all formats render.
+type statsSection struct {
+	key    string
+	title  string
+	column string
+	rows   []StatsCount
+}
+
+func bucketRows(buckets []StatsBucket) []StatsCount {
+	rows := make([]StatsCount, len(buckets))
+	for i, bucket := range buckets {
+		rows[i] = StatsCount{bucket.Range, bucket.Count}
+	}
+	return rows
+}
+
+func (stats *Stats) sections() []statsSection {
+	pairs := make([]StatsCount, len(stats.TagCooccurrence))
+	for i, pair := range stats.TagCooccurrence {
+		pairs[i] = StatsCount{pair.Tags[0] + " + " + pair.Tags[1], pair.Count}
+	}
+	return []statsSection{
+		{"totals", "Totals", "Name", []StatsCount{
+			{"quotes", stats.Quotes}, {"authors", stats.Authors}, {"tags", stats.Tags},
+		}},
+		{"length_histogram", "Quote length in characters", "Characters", bucketRows(stats.LengthHistogram)},
+		{"word_histogram", "Quote length in words", "Words", bucketRows(stats.WordHistogram)},
+		{"top_authors", "Top authors", "Author", stats.TopAuthors},
+		{"top_tags", "Top tags", "Tag", stats.TopTags},
+		{"tag_cooccurrence", "Tags used together", "Tags", pairs},
+		{"quotes_per_author", "Quotes per author", "Quotes", bucketRows(stats.QuotesPerAuthor)},
+	}
+}
+
+func statsToCSV(stats *Stats) string {
+	var sb strings.Builder
+	sb.WriteString(quotedCSVLine([]string{"section", "name", "count"}) + "\n")
+	for _, section := range stats.sections() {
+		for _, row := range section.rows {
+			sb.WriteString(quotedCSVLine([]string{section.key, row.Name, strconv.Itoa(row.Count)}) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func statsToMarkdown(stats *Stats) string {
+	var sb strings.Builder
+	sb.WriteString("# Quote statistics\n\n")
+	fmt.Fprintf(&sb, "Quote length: min %d, median %d, mean %.1f, max %d characters.\n",
+		stats.TextLength.Min, stats.TextLength.Median, stats.TextLength.Mean, stats.TextLength.Max)
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	for _, section := range stats.sections() {
+		fmt.Fprintf(&sb, "\n## %s\n\n| %s | Count |\n| --- | ---: |\n", section.title, section.column)
+		for _, row := range section.rows {
+			fmt.Fprintf(&sb, "| %s | %d |\n", escape.Replace(row.Name), row.Count)
+		}
+	}
+	return sb.String()
+}
+
+func statsToHTML(stats *Stats) string {
+	var sb strings.Builder
+	sb.WriteString(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Quote statistics</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', 'Helvetica', 'Arial', sans-serif;
+            color: #14171a;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        h2 {
+            font-size: 18px;
+            margin-top: 30px;
+        }
+        .summary {
+            color: #657786;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        td {
+            padding: 4px 8px;
+            font-size: 14px;
+        }
+        td.name {
+            width: 35%;
+        }
+        td.count {
+            width: 10%;
+            text-align: right;
+            color: #657786;
+        }
+        .bar {
+            background-color: #1da1f2;
+            height: 12px;
+            border-radius: 2px;
+        }
+    </style>
+</head>
+<body>
+    <h1>Quote statistics</h1>
+`)
+	fmt.Fprintf(&sb, `    <p class="summary">Quote length: min %d, median %d, mean %.1f, max %d characters.</p>
+`, stats.TextLength.Min, stats.TextLength.Median, stats.TextLength.Mean, stats.TextLength.Max)
+
+	for _, section := range stats.sections() {
+		fmt.Fprintf(&sb, "    <h2>%s</h2>\n    <table>\n", html.EscapeString(section.title))
+		largest := 0
+		for _, row := range section.rows {
+			largest = max(largest, row.Count)
+		}
+		for _, row := range section.rows {
+			width := 0.0
+			if largest > 0 {
+				width = 100 * float64(row.Count) / float64(largest)
+			}
+			fmt.Fprintf(&sb, `        <tr><td class="name">%s</td><td class="count">%d</td><td><div class="bar" style="width: %.1f%%"></div></td></tr>
+`, html.EscapeString(row.Name), row.Count, width)
+		}
+		sb.WriteString("    </table>\n")
+	}
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
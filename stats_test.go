@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildStats(t *testing.T) {
+	quotes := append(Quotes{}, testQuotes...)
+	quotes = append(quotes, Quote{Text: "Food for thought", Author: "Bob Example", Tags: []string{"food", "love"}})
+	authors := BuildAuthorIndex(quotes)
+	tags := BuildTagIndex(quotes)
+	stats := BuildStats(quotes, authors, tags)
+
+	if stats.Quotes != 4 || stats.Authors != 2 || stats.Tags != 3 {
+		t.Errorf("totals = %d quotes, %d authors, %d tags", stats.Quotes, stats.Authors, stats.Tags)
+	}
+	if want := (StatsSummary{Min: 11, Max: 26, Mean: 16.25, Median: 16}); stats.TextLength != want {
+		t.Errorf("TextLength = %+v, want %+v", stats.TextLength, want)
+	}
+	if stats.LengthHistogram[0] != (StatsBucket{"0-49", 4}) {
+		t.Errorf("LengthHistogram[0] = %+v", stats.LengthHistogram[0])
+	}
+	if stats.WordHistogram[0] != (StatsBucket{"1-5", 4}) {
+		t.Errorf("WordHistogram[0] = %+v", stats.WordHistogram[0])
+	}
+	wantAuthors := []StatsCount{{"Ann Example", 2}, {"Bob Example", 2}}
+	if !reflect.DeepEqual(stats.TopAuthors, wantAuthors) {
+		t.Errorf("TopAuthors = %+v, want %+v", stats.TopAuthors, wantAuthors)
+	}
+	if stats.TopTags[0] != (StatsCount{"love", 3}) {
+		t.Errorf("TopTags[0] = %+v", stats.TopTags[0])
+	}
+	wantPairs := []StatsTagPair{{[2]string{"food", "love"}, 2}}
+	if !reflect.DeepEqual(stats.TagCooccurrence, wantPairs) {
+		t.Errorf("TagCooccurrence = %+v, want %+v", stats.TagCooccurrence, wantPairs)
+	}
+	if stats.QuotesPerAuthor[1] != (StatsBucket{"2-5", 2}) {
+		t.Errorf("QuotesPerAuthor[1] = %+v", stats.QuotesPerAuthor[1])
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	rec := serveTestRequest(api, "/stats")
+	var stats Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("%v\n%s", err, rec.Body)
+	}
+	if stats.Quotes != 3 {
+		t.Errorf("quotes = %d, want 3", stats.Quotes)
+	}
+
+	tests := []struct {
+		url         string
+		contentType string
+		contains    []string
+	}{
+		{"/stats?format=csv", "text/csv", []string{`"section","name","count"`, `"top_authors","Ann Example","2"`, `"tag_cooccurrence","food + love","1"`}},
+		{"/stats?format=markdown", "text/markdown; charset=utf-8", []string{"## Top tags", "| love | 2 |"}},
+		{"/stats?format=html", "text/html; charset=utf-8", []string{"<h2>Top authors</h2>", `class="bar" style="width: 100.0%"`}},
+	}
+	for _, tt := range tests {
+		rec := serveTestRequest(api, tt.url)
+		if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.url, ct, tt.contentType)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("%s: response does not contain %q\n%s", tt.url, want, rec.Body)
+			}
+		}
+	}
+}
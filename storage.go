@@ -5,9 +5,14 @@ import (
 	"compress/gzip"
 	"encoding/csv"
 	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -35,6 +40,8 @@ func SaveQuotes(quotes Quotes, filename, storageType string) error {
 	case "bytesz":
 		_, err := SaveAsBytesCompressed(quotes, filename)
 		return err
+	case "arrow":
+		return SaveQuotesToArrow(quotes, filename)
 	default:
 		return fmt.Errorf("unsupported storage type: %s", storageType)
 	}
@@ -63,11 +70,19 @@ func LoadQuotesFromCSV(filename string) (Quotes, error) {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	// Files written before the metadata columns existed have three columns.
+	reader.FieldsPerRecord = -1
 
-	// Read and discard the header
-	if _, err := reader.Read(); err != nil {
+	header, err := reader.Read()
+	if err != nil {
 		return nil, fmt.Errorf("error reading CSV header: %v", err)
 	}
+	// The first three columns are positional, the metadata columns are
+	// matched by name so they are optional.
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
 
 	records, err := reader.ReadAll()
 	if err != nil {
@@ -76,16 +91,34 @@ func LoadQuotesFromCSV(filename string) (Quotes, error) {
 
 	quotes := make(Quotes, 0, len(records))
 	for _, record := range records {
-		if len(record) != 3 {
+		if len(record) < 3 || len(record) != len(header) {
 			fmt.Printf("Skipping invalid record: %v\n", record)
 			continue
 		}
+		column := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return record[i]
+			}
+			return ""
+		}
 
 		quote := Quote{
-			Text:   record[0],
-			Author: record[1],
-			Tags:   strings.Split(record[2], ", "),
+			Text:     record[0],
+			Author:   record[1],
+			Tags:     strings.Split(record[2], ", "),
+			Source:   column("source"),
+			Language: column("language"),
+			URL:      column("url"),
+
+			TranslationGroup: column("translation_group"),
 		}
+		if year := column("year"); year != "" {
+			if quote.Year, err = strconv.Atoi(year); err != nil {
+				fmt.Printf("Skipping invalid year %q: %v\n", year, record)
+				continue
+			}
+		}
+		quote.Verified, _ = strconv.ParseBool(column("verified"))
 		quotes = append(quotes, quote)
 	}
 
@@ -104,7 +137,7 @@ func SaveQuotesToCSV(quotes Quotes, filename string) error {
 	defer writer.Flush()
 
 	// Write header
-	err = writer.Write([]string{"quote", "author", "category"})
+	err = writer.Write([]string{"quote", "author", "category", "source", "year", "language", "url", "verified", "translation_group"})
 	if err != nil {
 		return fmt.Errorf("error writing CSV header: %v", err)
 	}
@@ -115,6 +148,12 @@ func SaveQuotesToCSV(quotes Quotes, filename string) error {
 			quote.Text,
 			quote.Author,
 			strings.Join(quote.Tags, ", "),
+			quote.Source,
+			yearString(quote.Year),
+			quote.Language,
+			quote.URL,
+			strconv.FormatBool(quote.Verified),
+			quote.TranslationGroup,
 		})
 		if err != nil {
 			return fmt.Errorf("error writing quote to CSV: %v", err)
@@ -223,3 +262,139 @@ func DecodeToQuotes(s []byte) (Quotes, error) {
 	}
 	return quotes, nil
 }
+
+// Embeddings holds a vector per quote, indexed by quote ID. Quotes without an
+// embedding have a nil vector.
+type Embeddings struct {
+	Dimensions int
+	Vectors    [][]float32
+}
+
+// EmbeddingsFilename returns the embeddings file that belongs next to a
+// quotes file, data/quotes.bytesz has data/quotes.embeddings.bytesz.
+func EmbeddingsFilename(quotesFilename, storageType string) string {
+	extension := filepath.Ext(quotesFilename)
+	if storageType != "bytes" && storageType != "bytesz" {
+		storageType = "jsonl"
+	}
+	return quotesFilename[:len(quotesFilename)-len(extension)] + ".embeddings." + storageType
+}
+
+// EmbeddingsStorage returns the storage type of an embeddings file from its
+// extension.
+func EmbeddingsStorage(filename string) string {
+	return strings.TrimPrefix(filepath.Ext(filename), ".")
+}
+
+func LoadEmbeddings(filename, storageType string) (*Embeddings, error) {
+	switch storageType {
+	case "jsonl":
+		return LoadEmbeddingsFromJSONL(filename)
+	case "bytes", "bytesz":
+		data, err := ReadFromFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read file: %v", err)
+		}
+		if storageType == "bytesz" {
+			data = Decompress(data)
+		}
+		var embeddings Embeddings
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&embeddings); err != nil {
+			return nil, fmt.Errorf("unable to decode to Embeddings: %v", err)
+		}
+		return &embeddings, nil
+	default:
+		return nil, fmt.Errorf("unsupported embeddings storage type: %s", storageType)
+	}
+}
+
+func SaveEmbeddings(embeddings *Embeddings, filename, storageType string) error {
+	switch storageType {
+	case "jsonl":
+		return SaveEmbeddingsToJSONL(embeddings, filename)
+	case "bytes", "bytesz":
+		buf := bytes.Buffer{}
+		if err := gob.NewEncoder(&buf).Encode(embeddings); err != nil {
+			return fmt.Errorf("unable to encode embeddings: %v", err)
+		}
+		data := buf.Bytes()
+		if storageType == "bytesz" {
+			data = Compress(data)
+		}
+		return WriteToFile(data, filename)
+	default:
+		return fmt.Errorf("unsupported embeddings storage type: %s", storageType)
+	}
+}
+
+// embeddingRecord is a line of the JSON lines import format.
+type embeddingRecord struct {
+	ID        int       `json:"id"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// LoadEmbeddingsFromJSONL loads embeddings from a file with a JSON object per
+// line, {"id": 12, "embedding": [0.1, ...]}. All embeddings must have the same
+// number of dimensions.
+func LoadEmbeddingsFromJSONL(filename string) (*Embeddings, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read input file: %v", err)
+	}
+	defer file.Close()
+
+	embeddings := &Embeddings{}
+	decoder := json.NewDecoder(file)
+	for line := 1; ; line++ {
+		var record embeddingRecord
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading embedding %d: %v", line, err)
+		}
+		if record.ID < 0 {
+			return nil, fmt.Errorf("embedding %d has negative quote id %d", line, record.ID)
+		}
+		if embeddings.Dimensions == 0 {
+			embeddings.Dimensions = len(record.Embedding)
+		}
+		if len(record.Embedding) == 0 || len(record.Embedding) != embeddings.Dimensions {
+			return nil, fmt.Errorf("embedding %d has %d dimensions, want %d", line, len(record.Embedding), embeddings.Dimensions)
+		}
+		for len(embeddings.Vectors) <= record.ID {
+			embeddings.Vectors = append(embeddings.Vectors, nil)
+		}
+		embeddings.Vectors[record.ID] = record.Embedding
+	}
+	return embeddings, nil
+}
+
+func SaveEmbeddingsToJSONL(embeddings *Embeddings, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for id, vector := range embeddings.Vectors {
+		if vector == nil {
+			continue
+		}
+		if err := encoder.Encode(embeddingRecord{ID: id, Embedding: vector}); err != nil {
+			return fmt.Errorf("error writing embedding: %v", err)
+		}
+	}
+	return nil
+}
+
+func ConvertEmbeddings(inputFilename, outputFilename string) error {
+	embeddings, err := LoadEmbeddings(inputFilename, EmbeddingsStorage(inputFilename))
+	if err != nil {
+		return fmt.Errorf("error loading embeddings: %v", err)
+	}
+	if err := SaveEmbeddings(embeddings, outputFilename, EmbeddingsStorage(outputFilename)); err != nil {
+		return fmt.Errorf("error saving embeddings: %v", err)
+	}
+	return nil
+}
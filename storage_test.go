@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadGobWithoutMetadata(t *testing.T) {
+	// The layout of Quote before the metadata fields were added.
+	type oldQuote struct {
+		Text   string
+		Author string
+		Tags   []string
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode([]oldQuote{{"Old quote", "Ann Example", []string{"life"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "quotes.bytesz")
+	if err := WriteToFile(Compress(buf.Bytes()), filename); err != nil {
+		t.Fatal(err)
+	}
+	quotes, err := LoadQuotes(filename, "bytesz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Quotes{{Text: "Old quote", Author: "Ann Example", Tags: []string{"life"}}}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("got %+v, want %+v", quotes, want)
+	}
+}
+
+func TestCSVStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("round trip", func(t *testing.T) {
+		quotes := Quotes{
+			{Text: "First", Author: "Ann Example", Tags: []string{"life", "love"},
+				Source: "Letters", Year: 1890, Language: "en", URL: "https://example.com", Verified: true},
+			{Text: "Second", Author: "Bob Example", Tags: []string{"food"}},
+		}
+		filename := filepath.Join(dir, "quotes.csv")
+		if err := SaveQuotes(quotes, filename, "csv"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadQuotes(filename, "csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, quotes) {
+			t.Errorf("got %+v, want %+v", got, quotes)
+		}
+	})
+
+	t.Run("without metadata columns", func(t *testing.T) {
+		filename := filepath.Join(dir, "old.csv")
+		data := "quote,author,category\n\"Old quote\",Ann Example,\"life, love\"\n"
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadQuotes(filename, "csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Quotes{{Text: "Old quote", Author: "Ann Example", Tags: []string{"life", "love"}}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestEmbeddingsStorage(t *testing.T) {
+	dir := t.TempDir()
+	if got := EmbeddingsFilename("data/quotes.bytesz", "bytesz"); got != "data/quotes.embeddings.bytesz" {
+		t.Errorf("EmbeddingsFilename = %q", got)
+	}
+	if got := EmbeddingsFilename("data/quotes.csv", "csv"); got != "data/quotes.embeddings.jsonl" {
+		t.Errorf("EmbeddingsFilename = %q", got)
+	}
+
+	input := filepath.Join(dir, "quotes.embeddings.jsonl")
+	err := os.WriteFile(input, []byte(`{"id": 0, "embedding": [0.5, 1]}
+{"id": 2, "embedding": [1, -0.25]}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "quotes.embeddings.bytesz")
+	if err := ConvertEmbeddings(input, output); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadEmbeddings(output, "bytesz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Embeddings{Dimensions: 2, Vectors: [][]float32{{0.5, 1}, nil, {1, -0.25}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	invalid := filepath.Join(dir, "invalid.jsonl")
+	os.WriteFile(invalid, []byte(`{"id": 0, "embedding": [1, 2]}
+{"id": 1, "embedding": [1, 2, 3]}
+`), 0644)
+	if _, err := LoadEmbeddings(invalid, "jsonl"); err == nil || !strings.Contains(err.Error(), "dimensions") {
+		t.Errorf("expected a dimensions error, got %v", err)
+	}
+}
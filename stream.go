@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStreamInterval = 10 * time.Second
+	minStreamInterval     = time.Second
+	maxStreamInterval     = time.Hour
+)
+
+// streamQuoteIndex deterministically picks the quote of an event, so a client
+// resuming a seeded stream with Last-Event-ID continues the same sequence.
+func streamQuoteIndex(seed int64, event uint64, n int) int {
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:8], uint64(seed))
+	binary.LittleEndian.PutUint64(b[8:], event)
+	h := fnv.New64a()
+	h.Write(b[:])
+	return int(h.Sum64() % uint64(n))
+}
+
+// writeSSEEvent writes a single event, data spanning multiple lines is split
+// over multiple data fields.
+func writeSSEEvent(w io.Writer, id string, event string, data string) error {
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: " + id + "\n")
+	}
+	sb.WriteString("event: " + event + "\n")
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + strings.TrimRight(line, "\r") + "\n")
+	}
+	sb.WriteString("\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// StreamRandomHandler keeps a Server-Sent Events connection open and pushes a
+// random quote on every tick, until the client disconnects.
+func (api *API) StreamRandomHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "html" {
+		returnError(w, "json", http.StatusBadRequest, "Unsupported stream format", "Supported formats are json and html")
+		return
+	}
+
+	interval := defaultStreamInterval
+	if param := query.Get("interval"); param != "" {
+		var err error
+		interval, err = time.ParseDuration(param)
+		if err != nil {
+			returnError(w, "json", http.StatusBadRequest, "Invalid interval", err.Error())
+			return
+		}
+	}
+	if interval < minStreamInterval || interval > maxStreamInterval {
+		returnError(w, "json", http.StatusBadRequest, "Invalid interval",
+			fmt.Sprintf("Interval must be between %s and %s", minStreamInterval, maxStreamInterval))
+		return
+	}
+
+	tag := query.Get("tag")
+	var quoteIDs []int
+	if tag != "" {
+		quoteIDs = api.Tags.NameToQuotes[tag]
+		if len(quoteIDs) == 0 {
+			returnError(w, "json", http.StatusNotFound, "Tag not found", "No quotes found for the given tag")
+			return
+		}
+	} else if len(api.Quotes) == 0 {
+		returnError(w, "json", http.StatusNotFound, "No quotes available", "The quote database is empty")
+		return
+	}
+
+	candidates := len(api.Quotes)
+	if quoteIDs != nil {
+		candidates = len(quoteIDs)
+	}
+
+	// Events are numbered and resumable with Last-Event-ID only when a seed
+	// makes the sequence reproducible.
+	seeded := query.Has("seed")
+	var seed int64
+	var event uint64
+	if seeded {
+		var err error
+		seed, err = strconv.ParseInt(query.Get("seed"), 10, 64)
+		if err != nil {
+			returnError(w, "json", http.StatusBadRequest, "Invalid seed", "Seed must be an integer")
+			return
+		}
+		if lastEventID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+			event = lastEventID
+		}
+	}
+
+	fields := parseFields(query.Get("fields"))
+	rc := http.NewResponseController(w)
+	liftDeadlines(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", interval.Milliseconds()); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		event++
+
+		var id string
+		pick := rand.Intn(candidates)
+		if seeded {
+			pick = streamQuoteIndex(seed, event, candidates)
+			id = strconv.FormatUint(event, 10)
+		}
+		quoteID := pick
+		if quoteIDs != nil {
+			quoteID = quoteIDs[pick]
+		}
+
+		quote := api.Quotes[quoteID].CreateResponseQuote(quoteID)
+
+		var data string
+		if format == "html" {
+			data = quoteToHTML(quote, tag, nil)
+		} else {
+			var b []byte
+			if fields.All() {
+				b, _ = json.Marshal(quote)
+			} else {
+				b, _ = json.Marshal(newSparseQuote(&quote, fields))
+			}
+			data = string(b)
+		}
+
+		if err := writeSSEEvent(w, id, "quote", data); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-api.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
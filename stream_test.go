@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveStream runs the stream handler until the first event has been written
+// and the request context is cancelled.
+func serveStream(t *testing.T, api *API, url string, lastEventID string) *httptest.ResponseRecorder {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req := httptest.NewRequest("GET", url, nil).WithContext(ctx)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		api.StreamRandomHandler(rec, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop after the client disconnected")
+	}
+	return rec
+}
+
+func TestStreamRandomResume(t *testing.T) {
+	api := newTestAPI(testQuotes)
+
+	rec := serveStream(t, api, "/stream/random?seed=42&tag=love&fields=id", "7")
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	quoteID := api.Tags.NameToQuotes["love"][streamQuoteIndex(42, 8, 2)]
+	want := "retry: 10000\n\nid: 8\nevent: quote\ndata: {\"id\":" + strconv.Itoa(quoteID) + "}\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStreamRandomHTML(t *testing.T) {
+	rec := serveStream(t, newTestAPI(testQuotes), "/stream/random?format=html&interval=1s", "5")
+
+	body := rec.Body.String()
+	if strings.Contains(body, "id: ") {
+		t.Errorf("unseeded stream should not number its events: %q", body)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n")[2:] {
+		if line != "event: quote" && !strings.HasPrefix(line, "data: ") {
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+}
+
+func TestStreamRandomInvalid(t *testing.T) {
+	api := newTestAPI(testQuotes)
+	for _, url := range []string{
+		"/stream/random?interval=10ms",
+		"/stream/random?interval=soon",
+		"/stream/random?seed=abc",
+		"/stream/random?format=xml",
+	} {
+		rec := serveTestRequest(api, url)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", url, rec.Code)
+		}
+	}
+
+	if rec := serveTestRequest(api, "/stream/random?tag=missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown tag: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tracing follows W3C Trace Context for propagation and exports spans with
+// the OTLP/HTTP JSON encoding, so any OpenTelemetry collector can receive
+// them. See https://www.w3.org/TR/trace-context/ and
+// https://opentelemetry.io/docs/specs/otlp/.
+//
+// Without a Tracer no middleware is installed and no span is put in the
+// request context, startSpan then returns a nil *Span whose methods do
+// nothing.
+
+const (
+	traceBatchSize     = 512
+	traceQueueSize     = 4096
+	traceFlushInterval = 5 * time.Second
+
+	spanKindInternal = 1
+	spanKindServer   = 2
+
+	spanStatusError = 2
+)
+
+type spanContextKey struct{}
+
+type spanAttr struct {
+	key   string
+	value interface{}
+}
+
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []spanAttr
+	err      string
+}
+
+// startSpan starts a child of the span in the context, or returns nil when
+// the request is not traced.
+func startSpan(ctx context.Context, name string) *Span {
+	if ctx == nil {
+		return nil
+	}
+	parent, _ := ctx.Value(spanContextKey{}).(*Span)
+	if parent == nil {
+		return nil
+	}
+	span := &Span{
+		tracer:   parent.tracer,
+		traceID:  parent.traceID,
+		parentID: parent.spanID,
+		sampled:  parent.sampled,
+		name:     name,
+		kind:     spanKindInternal,
+		start:    time.Now(),
+	}
+	span.spanID = newSpanID()
+	return span
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	for id == [8]byte{} {
+		v := rand.Uint64()
+		for i := range id {
+			id[i] = byte(v >> (8 * i))
+		}
+	}
+	return id
+}
+
+func newTraceID() [16]byte {
+	var id [16]byte
+	for id == [16]byte{} {
+		a, b := rand.Uint64(), rand.Uint64()
+		for i := 0; i < 8; i++ {
+			id[i] = byte(a >> (8 * i))
+			id[8+i] = byte(b >> (8 * i))
+		}
+	}
+	return id
+}
+
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, spanAttr{key, value})
+}
+
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.err = message
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	if s.sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// traceparent formats the span as a W3C traceparent header value.
+func (s *Span) traceparent() string {
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-" + flags
+}
+
+// parseTraceparent parses a W3C traceparent header value. Versions after 00
+// are read as far as version 00 goes, as the specification asks.
+func parseTraceparent(value string) (traceID [16]byte, parentID [8]byte, sampled bool, ok bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || (len(value) > 55 && value[55] != '-') {
+		return traceID, parentID, false, false
+	}
+	version, traceHex, parentHex, flagsHex := value[0:2], value[3:35], value[36:52], value[53:55]
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' || version == "ff" ||
+		(version == "00" && len(value) != 55) {
+		return traceID, parentID, false, false
+	}
+	for _, part := range []string{version, traceHex, parentHex, flagsHex} {
+		if strings.ToLower(part) != part {
+			return traceID, parentID, false, false
+		}
+	}
+	if _, err := hex.Decode(traceID[:], []byte(traceHex)); err != nil || traceID == [16]byte{} {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parentHex)); err != nil || parentID == [8]byte{} {
+		return traceID, parentID, false, false
+	}
+	flags, err := strconv.ParseUint(flagsHex, 16, 8)
+	if err != nil {
+		return traceID, parentID, false, false
+	}
+	return traceID, parentID, flags&1 == 1, true
+}
+
+// Tracer batches finished spans and posts them to an OTLP/HTTP endpoint
+// such as http://localhost:4318/v1/traces.
+type Tracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	queue   chan *Span
+	done    chan struct{}
+	stopped sync.WaitGroup
+	dropped atomic.Uint64
+}
+
+func NewTracer(endpoint, service string) *Tracer {
+	tracer := &Tracer{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan *Span, traceQueueSize),
+		done:     make(chan struct{}),
+	}
+	tracer.stopped.Add(1)
+	go tracer.run()
+	return tracer
+}
+
+// enqueue hands a span to the exporter, dropping it when the queue is full
+// rather than blocking the request.
+func (t *Tracer) enqueue(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer t.stopped.Done()
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, traceBatchSize)
+	export := func() {
+		if len(batch) > 0 {
+			if err := t.export(batch); err != nil {
+				log.Printf("Error exporting %d spans: %v", len(batch), err)
+			}
+			batch = batch[:0]
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-t.queue:
+				batch = append(batch, span)
+				if len(batch) == traceBatchSize {
+					export()
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) == traceBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case <-t.done:
+			drain()
+			export()
+			return
+		}
+	}
+}
+
+// Shutdown exports the queued spans and stops the exporter, or gives up
+// when the context is done first.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	close(t.done)
+	if dropped := t.dropped.Load(); dropped > 0 {
+		log.Printf("Dropped %d spans, the export queue was full", dropped)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		t.stopped.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttribute(key string, value interface{}) otlpKeyValue {
+	var encoded otlpAnyValue
+	switch v := value.(type) {
+	case string:
+		encoded.StringValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		encoded.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		encoded.IntValue = &s
+	case float64:
+		encoded.DoubleValue = &v
+	case bool:
+		encoded.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		encoded.StringValue = &s
+	}
+	return otlpKeyValue{key, encoded}
+}
+
+func (t *Tracer) export(spans []*Span) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "go_quote"}, Spans: make([]otlpSpan, 0, len(spans))}
+	for _, span := range spans {
+		encoded := otlpSpan{
+			TraceID:           hex.EncodeToString(span.traceID[:]),
+			SpanID:            hex.EncodeToString(span.spanID[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		}
+		if span.parentID != [8]byte{} {
+			encoded.ParentSpanID = hex.EncodeToString(span.parentID[:])
+		}
+		for _, attr := range span.attrs {
+			encoded.Attributes = append(encoded.Attributes, otlpAttribute(attr.key, attr.value))
+		}
+		if span.err != "" {
+			encoded.Status = otlpStatus{Code: spanStatusError, Message: span.err}
+		}
+		scope.Spans = append(scope.Spans, encoded)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpAttribute("service.name", t.service)}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// tracingMiddleware starts the server span of a request, continuing the
+// trace of a valid traceparent header.
+func (api *API) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := &Span{
+			tracer: api.Tracer,
+			kind:   spanKindServer,
+			start:  time.Now(),
+		}
+		if traceID, parentID, sampled, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			span.traceID, span.parentID, span.sampled = traceID, parentID, sampled
+		} else {
+			span.traceID, span.sampled = newTraceID(), true
+		}
+		span.spanID = newSpanID()
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		traced := r.WithContext(context.WithValue(r.Context(), spanContextKey{}, span))
+		if mux, ok := next.(*http.ServeMux); ok {
+			// The mux routes the request again when serving it, the lookup is
+			// repeated here to time it on its own.
+			routing := startSpan(traced.Context(), "route")
+			_, pattern := mux.Handler(traced)
+			routing.SetAttr("http.route", pattern)
+			routing.End()
+		}
+		next.ServeHTTP(recorder, traced)
+		// The mux records the route on the request it is given, the outer
+		// middleware read it from the original.
+		r.Pattern = traced.Pattern
+
+		route := traced.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		span.name = r.Method + " " + route
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("url.path", r.URL.Path)
+		span.SetAttr("http.response.status_code", recorder.Status())
+		span.SetAttr("http.response.body.size", recorder.size)
+		span.SetAttr("go_quote.format", getOutputFormat(r))
+		if recorder.Status() >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(recorder.Status()))
+		}
+		span.End()
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value   string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		traceID, parentID, sampled, ok := parseTraceparent(tt.value)
+		if ok != tt.ok || sampled != tt.sampled {
+			t.Errorf("parseTraceparent(%q) = sampled %v, ok %v, want %v, %v", tt.value, sampled, ok, tt.sampled, tt.ok)
+			continue
+		}
+		if ok && (hex.EncodeToString(traceID[:]) != "4bf92f3577b34da6a3ce929d0e0e4736" || hex.EncodeToString(parentID[:]) != "00f067aa0ba902b7") {
+			t.Errorf("parseTraceparent(%q) = %x, %x", tt.value, traceID, parentID)
+		}
+	}
+}
+
+func TestStartSpanWithoutTracer(t *testing.T) {
+	span := startSpan(context.Background(), "format")
+	if span != nil {
+		t.Fatalf("startSpan without a traced request = %v, want nil", span)
+	}
+	span.SetAttr("key", "value")
+	span.SetError("error")
+	span.End()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		span := startSpan(context.Background(), "gzip")
+		span.SetAttr("go_quote.format", "json")
+		span.End()
+	})
+	if allocs != 0 {
+		t.Errorf("untraced span allocates %v times", allocs)
+	}
+}
+
+func TestTracingExport(t *testing.T) {
+	var mu sync.Mutex
+	var requests []otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		var request otlpRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("invalid export %s: %v", body, err)
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	api := newTestAPI(testQuotes)
+	api.Tracer = NewTracer(collector.URL, "go-quote-test")
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	handler := api.SetupMiddleware()(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/tags/love?format=csv", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	// The metrics and access log read the route from the original request.
+	if req.Pattern != "/tags/" {
+		t.Errorf("Pattern = %q, want /tags/", req.Pattern)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/quotes/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := api.Tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 {
+		t.Fatalf("got %d exports, want 1", len(requests))
+	}
+	resource := requests[0].ResourceSpans[0]
+	if service := resource.Resource.Attributes[0]; service.Key != "service.name" || *service.Value.StringValue != "go-quote-test" {
+		t.Errorf("resource attribute = %+v", service)
+	}
+
+	spans := map[string]otlpSpan{}
+	for _, span := range resource.ScopeSpans[0].Spans {
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %q has trace id %s", span.Name, span.TraceID)
+		}
+		spans[span.Name] = span
+	}
+	// The unsampled request is not exported.
+	if len(spans) != 4 {
+		t.Errorf("got spans %v, want the server, route, index lookup and format spans", spans)
+	}
+	server, ok := spans["GET /tags/"]
+	if !ok {
+		t.Fatalf("no server span in %v", spans)
+	}
+	if server.ParentSpanID != "00f067aa0ba902b7" || server.Kind != spanKindServer {
+		t.Errorf("server span = %+v", server)
+	}
+	for _, name := range []string{"route", "index lookup", "format"} {
+		if spans[name].ParentSpanID != server.SpanID {
+			t.Errorf("%s span has parent %q, want %q", name, spans[name].ParentSpanID, server.SpanID)
+		}
+	}
+	attributes := map[string]otlpAnyValue{}
+	for _, attribute := range server.Attributes {
+		attributes[attribute.Key] = attribute.Value
+	}
+	if value := attributes["http.response.status_code"]; value.IntValue == nil || *value.IntValue != "200" {
+		t.Errorf("http.response.status_code = %+v", value)
+	}
+	if value := attributes["http.route"]; value.StringValue == nil || *value.StringValue != "/tags/" {
+		t.Errorf("http.route = %+v", value)
+	}
+}
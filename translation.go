@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func BuildTranslationIndex(quotes Quotes) IndexStructure {
+	index := NewIndexStructure()
+	for i, quote := range quotes {
+		index.Add(quote.TranslationGroup, i)
+	}
+	return index
+}
+
+// translationIDs returns the other quotes in the translation group of the
+// quote, in ascending order.
+func (api *API) translationIDs(quoteID int) []int {
+	group := api.Quotes[quoteID].TranslationGroup
+	if group == "" {
+		return []int{}
+	}
+	ids := make([]int, 0, len(api.Translations.NameToQuotes[group]))
+	for _, id := range api.Translations.NameToQuotes[group] {
+		if id != quoteID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// translationIn returns the quote or one of its translations in lang.
+func (api *API) translationIn(quoteID int, lang string) (int, bool) {
+	if normalizeLanguage(api.Quotes[quoteID].Language) == lang {
+		return quoteID, true
+	}
+	for _, id := range api.translationIDs(quoteID) {
+		if normalizeLanguage(api.Quotes[id].Language) == lang {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// negotiateTranslation picks the version of a quote to serve for the lang
+// parameter of /quotes/{id}. When there is no translation in lang the
+// languages of Accept-Language are tried, and the quote itself is the last
+// resort.
+func (api *API) negotiateTranslation(w http.ResponseWriter, r *http.Request, quoteID int) int {
+	if !r.URL.Query().Has("lang") {
+		return quoteID
+	}
+	lang := requestLanguage(r)
+	if lang == "" {
+		return quoteID
+	}
+	if id, ok := api.translationIn(quoteID, lang); ok {
+		return api.servedTranslation(w, quoteID, id)
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	for _, tag := range acceptedLanguages(r.Header.Get("Accept-Language")) {
+		if tag == "*" {
+			break
+		}
+		if id, ok := api.translationIn(quoteID, normalizeLanguage(tag)); ok {
+			return api.servedTranslation(w, quoteID, id)
+		}
+	}
+	return quoteID
+}
+
+// servedTranslation sets the headers for serving translation in place of the
+// requested quote.
+func (api *API) servedTranslation(w http.ResponseWriter, quoteID, translation int) int {
+	if lang := normalizeLanguage(api.Quotes[translation].Language); lang != "" {
+		w.Header().Set("Content-Language", lang)
+	}
+	if translation != quoteID {
+		w.Header().Set("Content-Location", "/quotes/"+strconv.Itoa(translation))
+	}
+	return translation
+}
+
+// TranslationsHandler lists the translations of a quote, for
+// /quotes/{id}/translations.
+func (api *API) TranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	quoteID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || quoteID < 0 || quoteID >= len(api.Quotes) {
+		returnError(w, getOutputFormat(r), http.StatusNotFound, "Quote not found", "Invalid quote ID")
+		return
+	}
+
+	title := fmt.Sprintf("Translations of quote %d", quoteID)
+	api.serveQuoteList(w, r, api.translationIDs(quoteID), title, title, nil)
+}
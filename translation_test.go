@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func newTranslationTestAPI() *API {
+	quotes := Quotes{
+		{Text: "Knowledge is power.", Author: "Ann Example", Language: "en", TranslationGroup: "knowledge"},
+		{Text: "El conocimiento es poder.", Author: "Ann Example", Language: "es", TranslationGroup: "knowledge"},
+		{Text: "Kennis is macht.", Author: "Ann Example", Language: "nl", TranslationGroup: "knowledge"},
+		{Text: "Untranslated quote", Author: "Bob Example", Language: "en"},
+	}
+	return newTestAPI(quotes)
+}
+
+func TestTranslationsHandler(t *testing.T) {
+	api := newTranslationTestAPI()
+
+	tests := []struct {
+		url    string
+		status int
+		want   []int
+	}{
+		{"/quotes/0/translations", http.StatusOK, []int{1, 2}},
+		{"/quotes/2/translations", http.StatusOK, []int{0, 1}},
+		{"/quotes/3/translations", http.StatusOK, []int{}},
+		{"/quotes/9/translations", http.StatusNotFound, nil},
+		{"/quotes/x/translations", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		rec := serveTestRequest(api, tt.url)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.url, rec.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var response PaginatedQuotesResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		got := make([]int, 0, len(response.Quotes))
+		for _, quote := range response.Quotes {
+			got = append(got, quote.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: translations = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteTranslationNegotiation(t *testing.T) {
+	api := newTranslationTestAPI()
+
+	tests := []struct {
+		name            string
+		url             string
+		acceptLanguage  string
+		wantID          int
+		contentLanguage string
+		contentLocation string
+	}{
+		{"no lang", "/quotes/0", "es", 0, "", ""},
+		{"translation", "/quotes/0?lang=es", "", 1, "es", "/quotes/1"},
+		{"same language", "/quotes/1?lang=es-ES", "", 1, "es", ""},
+		{"accept language fallback", "/quotes/0?lang=fr", "fr, nl;q=0.8", 2, "nl", "/quotes/2"},
+		{"original fallback", "/quotes/0?lang=fr", "de", 0, "", ""},
+		{"untranslated", "/quotes/3?lang=es", "", 3, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			api.SetupRoutes(mux)
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			var quote ResponseQuote
+			if err := json.Unmarshal(rec.Body.Bytes(), &quote); err != nil {
+				t.Fatalf("%v\n%s", err, rec.Body)
+			}
+			if quote.ID != tt.wantID {
+				t.Errorf("served quote %d, want %d", quote.ID, tt.wantID)
+			}
+			if got := rec.Header().Get("Content-Language"); got != tt.contentLanguage {
+				t.Errorf("Content-Language = %q, want %q", got, tt.contentLanguage)
+			}
+			if got := rec.Header().Get("Content-Location"); got != tt.contentLocation {
+				t.Errorf("Content-Location = %q, want %q", got, tt.contentLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal RFC 6455 server side, enough for the JSON command protocol of /ws.
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseGoingAway     = 1001
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+
+	wsMaxMessageSize = 4096
+	wsPingInterval   = 30 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsWriteWait      = 10 * time.Second
+
+	// Commands per second a connection may send, with bursts up to
+	// wsRateBurst.
+	wsRateLimit = 5
+	wsRateBurst = 10
+)
+
+var (
+	errWSProtocol = errors.New("websocket protocol error")
+	errWSTooBig   = errors.New("websocket message too big")
+)
+
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// upgradeWebSocket validates the handshake and takes over the connection.
+// On failure the error response has already been written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") {
+		returnError(w, "json", http.StatusBadRequest, "Not a WebSocket handshake", "Expected a GET request with Upgrade: websocket")
+		return nil, errWSProtocol
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		returnError(w, "json", http.StatusUpgradeRequired, "Unsupported WebSocket version", "Only version 13 is supported")
+		return nil, errWSProtocol
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		returnError(w, "json", http.StatusBadRequest, "Invalid WebSocket key", "Sec-WebSocket-Key must be 16 bytes base64 encoded")
+		return nil, errWSProtocol
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		returnError(w, "json", http.StatusInternalServerError, "WebSocket upgrade failed", err.Error())
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if _, err := io.WriteString(conn, response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = binary.BigEndian.AppendUint16(append(header, 126), uint16(n))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 127), uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+func (c *wsConn) writeClose(code int, reason string) error {
+	return c.writeFrame(wsOpClose, append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...))
+}
+
+// readFrame reads a single frame, client frames are always masked.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		return fin, opcode, nil, errWSProtocol
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (length > 125 || !fin) {
+		return fin, opcode, nil, errWSProtocol
+	}
+	if length > wsMaxMessageSize {
+		return fin, opcode, nil, errWSTooBig
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// readMessage returns the next data message, answering control frames on the
+// way. Every frame received counts as a sign of life for the keepalive.
+func (c *wsConn) readMessage() (opcode byte, message []byte, err error) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		fin, frameOpcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frameOpcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.writeFrame(wsOpClose, payload)
+			return 0, nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if message != nil {
+				return 0, nil, errWSProtocol
+			}
+			opcode = frameOpcode
+			message = payload
+		case wsOpContinuation:
+			if message == nil {
+				return 0, nil, errWSProtocol
+			}
+			if len(message)+len(payload) > wsMaxMessageSize {
+				return 0, nil, errWSTooBig
+			}
+			message = append(message, payload...)
+		default:
+			return 0, nil, errWSProtocol
+		}
+
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// tokenBucket limits the commands of a single connection.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (tb *tokenBucket) allow(now time.Time) bool {
+	if tb.last.IsZero() {
+		tb.tokens = wsRateBurst
+	} else {
+		tb.tokens += now.Sub(tb.last).Seconds() * wsRateLimit
+		if tb.tokens > wsRateBurst {
+			tb.tokens = wsRateBurst
+		}
+	}
+	tb.last = now
+
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+type WSCommand struct {
+	Cmd    string `json:"cmd"`
+	ID     *int   `json:"id,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Author string `json:"author,omitempty"`
+}
+
+type WSResponse struct {
+	Cmd   string         `json:"cmd"`
+	Quote *ResponseQuote `json:"quote,omitempty"`
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+func wsError(cmd string, status int, message string, err string) WSResponse {
+	return WSResponse{Cmd: cmd, Error: &ErrorResponse{Status: status, Message: message, Error: err}}
+}
+
+func (api *API) handleWSCommand(command WSCommand) WSResponse {
+	switch command.Cmd {
+	case "next":
+		if command.Tag != "" && command.Author != "" {
+			return wsError(command.Cmd, http.StatusBadRequest, "Invalid command", "Use either tag or author")
+		}
+		quoteID, ok := api.randomQuoteID(command.Tag, command.Author)
+		if !ok {
+			return wsError(command.Cmd, http.StatusNotFound, "No quotes found", "No quotes found for the given tag or author")
+		}
+		quote := api.Quotes[quoteID].CreateResponseQuote(quoteID)
+		return WSResponse{Cmd: command.Cmd, Quote: &quote}
+
+	case "get":
+		if command.ID == nil {
+			return wsError(command.Cmd, http.StatusBadRequest, "Invalid command", "The get command requires an id")
+		}
+		quoteID := *command.ID
+		if quoteID < 0 || quoteID >= len(api.Quotes) {
+			return wsError(command.Cmd, http.StatusNotFound, "Quote not found", "Invalid quote ID")
+		}
+		quote := api.Quotes[quoteID].CreateResponseQuote(quoteID)
+		return WSResponse{Cmd: command.Cmd, Quote: &quote}
+
+	default:
+		return wsError(command.Cmd, http.StatusBadRequest, "Unknown command", fmt.Sprintf("Unknown command %q, expected next or get", command.Cmd))
+	}
+}
+
+// WebSocketHandler serves interactive quote sessions, clients send commands
+// such as {"cmd":"next","tag":"love"} or {"cmd":"get","id":42}.
+func (api *API) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer c.conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-api.stopping:
+				c.writeClose(wsCloseGoingAway, "server shutting down")
+				c.conn.Close()
+				return
+			case <-ticker.C:
+				if err := c.writeFrame(wsOpPing, nil); err != nil {
+					c.conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	var limiter tokenBucket
+	for {
+		opcode, message, err := c.readMessage()
+		switch {
+		case errors.Is(err, errWSTooBig):
+			c.writeClose(wsCloseTooBig, "message too big")
+			return
+		case errors.Is(err, errWSProtocol):
+			c.writeClose(wsCloseProtocolError, "protocol error")
+			return
+		case err != nil:
+			return
+		}
+
+		var response WSResponse
+		var command WSCommand
+		switch {
+		case !limiter.allow(time.Now()):
+			response = wsError("", http.StatusTooManyRequests, "Rate limit exceeded", fmt.Sprintf("At most %d commands per second are allowed", wsRateLimit))
+		case opcode != wsOpText:
+			response = wsError("", http.StatusBadRequest, "Invalid command", "Commands must be sent as text messages")
+		case json.Unmarshal(message, &command) != nil:
+			response = wsError("", http.StatusBadRequest, "Invalid command", "Commands must be JSON objects")
+		default:
+			response = api.handleWSCommand(command)
+		}
+
+		payload, _ := json.Marshal(response)
+		if err := c.writeFrame(wsOpText, payload); err != nil {
+			return
+		}
+	}
+}
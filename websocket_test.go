@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type wsTestClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialTestWebSocket(t *testing.T, api *API) *wsTestClient {
+	t.Helper()
+	mux := http.NewServeMux()
+	api.SetupRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", accept)
+	}
+	return &wsTestClient{conn: conn, reader: reader}
+}
+
+func (c *wsTestClient) send(t *testing.T, opcode byte, payload string) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *wsTestClient) receive(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.reader, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func (c *wsTestClient) command(t *testing.T, command string) WSResponse {
+	t.Helper()
+	c.send(t, wsOpText, command)
+	opcode, payload := c.receive(t)
+	if opcode != wsOpText {
+		t.Fatalf("opcode = %d, want text", opcode)
+	}
+	var response WSResponse
+	if err := json.Unmarshal(payload, &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestWebSocketCommands(t *testing.T) {
+	c := dialTestWebSocket(t, newTestAPI(testQuotes))
+
+	response := c.command(t, `{"cmd":"get","id":1}`)
+	if response.Quote == nil || response.Quote.Text != testQuotes[1].Text || response.Quote.ID != 1 {
+		t.Errorf("get: unexpected response %+v", response)
+	}
+
+	response = c.command(t, `{"cmd":"next","tag":"life"}`)
+	if response.Quote == nil || response.Quote.ID != 2 {
+		t.Errorf("next with tag: unexpected response %+v", response)
+	}
+
+	response = c.command(t, `{"cmd":"next","author":"Bob+Example"}`)
+	if response.Quote == nil || response.Quote.ID != 1 {
+		t.Errorf("next with author: unexpected response %+v", response)
+	}
+
+	for command, status := range map[string]int{
+		`{"cmd":"get","id":99}`:          http.StatusNotFound,
+		`{"cmd":"next","tag":"missing"}`: http.StatusNotFound,
+		`{"cmd":"get"}`:                  http.StatusBadRequest,
+		`{"cmd":"dance"}`:                http.StatusBadRequest,
+		`not json`:                       http.StatusBadRequest,
+	} {
+		response = c.command(t, command)
+		if response.Error == nil || response.Error.Status != status {
+			t.Errorf("%s: unexpected response %+v", command, response)
+		}
+	}
+}
+
+func TestWebSocketPingAndClose(t *testing.T) {
+	c := dialTestWebSocket(t, newTestAPI(testQuotes))
+
+	c.send(t, wsOpPing, "hello")
+	if opcode, payload := c.receive(t); opcode != wsOpPong || string(payload) != "hello" {
+		t.Errorf("got opcode %d payload %q, want pong", opcode, payload)
+	}
+
+	c.send(t, wsOpClose, "\x03\xe8")
+	if opcode, _ := c.receive(t); opcode != wsOpClose {
+		t.Errorf("got opcode %d, want close", opcode)
+	}
+}
+
+func TestWebSocketRateLimit(t *testing.T) {
+	c := dialTestWebSocket(t, newTestAPI(testQuotes))
+
+	limited := 0
+	for i := 0; i < wsRateBurst+5; i++ {
+		response := c.command(t, `{"cmd":"get","id":0}`)
+		if response.Error != nil && response.Error.Status == http.StatusTooManyRequests {
+			limited++
+		}
+	}
+	if limited == 0 {
+		t.Error("expected commands beyond the burst to be rate limited")
+	}
+}
+
+func TestWebSocketHandshakeRequired(t *testing.T) {
+	rec := serveTestRequest(newTestAPI(testQuotes), "/ws")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}